@@ -2,8 +2,11 @@ package callbacks
 
 import (
 	"context"
+	"time"
+
 	"github.com/iflytek/spark-ai-go/sparkai/llms"
 	"github.com/iflytek/spark-ai-go/sparkai/messages"
+	"github.com/iflytek/spark-ai-go/sparkai/retry"
 )
 
 // Handler is the interface that allows for hooking into specific parts of an
@@ -27,6 +30,29 @@ type Handler interface {
 	HandleStreamingFunc(ctx context.Context, chunk []byte)
 }
 
+// RetryHandler is implemented by handlers that want to be notified when a
+// failed LLM call is about to be retried. attempt is the number of the failed
+// attempt, starting at 1, and delay the wait before the next one.
+type RetryHandler interface {
+	HandleLLMRetry(ctx context.Context, attempt int, delay time.Duration, err error)
+}
+
+// WithRetryHandler returns a copy of p also reporting the retries to the
+// handler returned by handler, when it is a RetryHandler. handler is called
+// at every retry, so the handler may be set after the policy is built.
+func WithRetryHandler(p retry.Policy, handler func() Handler) retry.Policy {
+	onRetry := p.OnRetry
+	p.OnRetry = func(ctx context.Context, attempt int, delay time.Duration, err error) {
+		if onRetry != nil {
+			onRetry(ctx, attempt, delay, err)
+		}
+		if h, ok := handler().(RetryHandler); ok {
+			h.HandleLLMRetry(ctx, attempt, delay, err)
+		}
+	}
+	return p
+}
+
 // HandlerHaver is an interface used to get callbacks handler.
 type HandlerHaver interface {
 	GetCallbackHandler() Handler
@@ -0,0 +1,39 @@
+package callbacks
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/iflytek/spark-ai-go/sparkai/retry"
+	"github.com/stretchr/testify/assert"
+)
+
+type retryRecorder struct {
+	Handler
+	attempts []int
+}
+
+func (r *retryRecorder) HandleLLMRetry(_ context.Context, attempt int, _ time.Duration, _ error) {
+	r.attempts = append(r.attempts, attempt)
+}
+
+func TestWithRetryHandler(t *testing.T) {
+	t.Parallel()
+	var onRetry []int
+	p := retry.Policy{OnRetry: func(_ context.Context, attempt int, _ time.Duration, _ error) {
+		onRetry = append(onRetry, attempt)
+	}}
+
+	// 处理器在策略创建之后才设置
+	var handler Handler
+	p = WithRetryHandler(p, func() Handler { return handler })
+	p.OnRetry(context.Background(), 1, time.Second, errors.New("failed"))
+	recorder := &retryRecorder{}
+	handler = recorder
+	p.OnRetry(context.Background(), 2, time.Second, errors.New("failed"))
+
+	assert.Equal(t, []int{1, 2}, onRetry)
+	assert.Equal(t, []int{2}, recorder.attempts)
+}
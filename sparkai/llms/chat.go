@@ -0,0 +1,70 @@
+package llms
+
+import (
+	"context"
+	"sync"
+
+	"github.com/iflytek/spark-ai-go/sparkai/messages"
+)
+
+// DefaultMaxConcurrency is the default number of conversations generated in
+// parallel by the ChatLLM implementations.
+const DefaultMaxConcurrency = 4
+
+// GenerateChats runs generate for every conversation with at most
+// maxConcurrency calls in flight, and returns the generations in the order of
+// the conversations. The first error cancels the context of the calls still
+// running and is returned.
+func GenerateChats(
+	ctx context.Context,
+	conversations [][]messages.ChatMessage,
+	maxConcurrency int,
+	generate func(ctx context.Context, msgs []messages.ChatMessage) (*Generation, error),
+) ([]*Generation, error) {
+	if maxConcurrency <= 0 {
+		maxConcurrency = DefaultMaxConcurrency
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	generations := make([]*Generation, len(conversations))
+	sem := make(chan struct{}, maxConcurrency)
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	for i, msgs := range conversations {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(i int, msgs []messages.ChatMessage) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			g, err := generate(ctx, msgs)
+			if err != nil {
+				errOnce.Do(func() {
+					firstErr = err
+					cancel()
+				})
+				return
+			}
+			generations[i] = g
+		}(i, msgs)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return generations, nil
+}
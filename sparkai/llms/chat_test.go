@@ -0,0 +1,60 @@
+package llms
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/iflytek/spark-ai-go/sparkai/messages"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateChats(t *testing.T) {
+	t.Parallel()
+	conversations := make([][]messages.ChatMessage, 10)
+	for i := range conversations {
+		conversations[i] = []messages.ChatMessage{messages.HumanChatMessage{Content: string(rune('a' + i))}}
+	}
+
+	var inFlight, maxInFlight atomic.Int32
+	generations, err := GenerateChats(context.Background(), conversations, 3,
+		func(ctx context.Context, msgs []messages.ChatMessage) (*Generation, error) {
+			n := inFlight.Add(1)
+			defer inFlight.Add(-1)
+			for {
+				m := maxInFlight.Load()
+				if n <= m || maxInFlight.CompareAndSwap(m, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			return &Generation{Text: msgs[0].GetContent()}, nil
+		})
+	require.NoError(t, err)
+	require.Len(t, generations, len(conversations))
+	for i, g := range generations {
+		assert.Equal(t, string(rune('a'+i)), g.Text)
+	}
+	assert.LessOrEqual(t, maxInFlight.Load(), int32(3))
+}
+
+func TestGenerateChatsError(t *testing.T) {
+	t.Parallel()
+	errFailed := errors.New("failed")
+	conversations := make([][]messages.ChatMessage, 5)
+
+	var calls atomic.Int32
+	_, err := GenerateChats(context.Background(), conversations, 5,
+		func(ctx context.Context, msgs []messages.ChatMessage) (*Generation, error) {
+			// 第一个会话失败, 其余会话应被取消
+			if calls.Add(1) == 1 {
+				return nil, errFailed
+			}
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+	require.ErrorIs(t, err, errFailed)
+}
@@ -1,8 +1,11 @@
 package llms
 
 import (
-	"github.com/pkoukk/tiktoken-go"
 	"log"
+	"strings"
+	"sync"
+
+	"github.com/pkoukk/tiktoken-go"
 )
 
 const (
@@ -37,14 +40,38 @@ var modelToContextSize = map[string]int{
 	"code-cushman-001": _codeCushman1ContextSize,
 }
 
-// ModelContextSize gets the max number of tokens for a language model. If the model
-// name isn't recognized the default value 2048 is returned.
+// registeredContextSizes are the context sizes registered by the providers,
+// by lower case model name.
+//
+// nolint:gochecknoglobals
+var (
+	registeredContextSizesMu sync.RWMutex
+	registeredContextSizes   = map[string]int{}
+)
+
+// RegisterModelContextSize registers the max number of tokens of the model
+// name, matched case insensitively. The providers register their models when
+// their package is imported, e.g. the spark package registers the models of
+// the sparkclient registry by name, alias and domain.
+func RegisterModelContextSize(name string, size int) {
+	registeredContextSizesMu.Lock()
+	defer registeredContextSizesMu.Unlock()
+	registeredContextSizes[strings.ToLower(strings.TrimSpace(name))] = size
+}
+
+// ModelContextSize gets the max number of tokens for a language model, see
+// RegisterModelContextSize for the models of the providers. If the model name
+// isn't recognized the default value 2048 is returned.
 func GetModelContextSize(model string) int {
-	contextSize, ok := modelToContextSize[model]
-	if !ok {
-		return _defaultContextSize
+	if contextSize, ok := modelToContextSize[model]; ok {
+		return contextSize
+	}
+	registeredContextSizesMu.RLock()
+	defer registeredContextSizesMu.RUnlock()
+	if contextSize, ok := registeredContextSizes[strings.ToLower(strings.TrimSpace(model))]; ok {
+		return contextSize
 	}
-	return contextSize
+	return _defaultContextSize
 }
 
 // CountTokens gets the number of tokens the text contains.
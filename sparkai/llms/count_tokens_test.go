@@ -0,0 +1,29 @@
+package llms
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetModelContextSize(t *testing.T) {
+	t.Parallel()
+	cases := map[string]int{
+		"gpt-4":         _gpt4ContextSize,
+		"gpt-3.5-turbo": _gpt35TurboContextSize,
+		"unknown-model": _defaultContextSize,
+	}
+	for model, size := range cases {
+		assert.Equal(t, size, GetModelContextSize(model), model)
+	}
+}
+
+func TestRegisterModelContextSize(t *testing.T) {
+	t.Parallel()
+	RegisterModelContextSize("Test-Model-64K", 65536)
+	assert.Equal(t, 65536, GetModelContextSize("test-model-64k"))
+	assert.Equal(t, 65536, GetModelContextSize(" TEST-MODEL-64K "))
+	// 内置的模型不能被覆盖
+	RegisterModelContextSize("gpt-4", 1)
+	assert.Equal(t, _gpt4ContextSize, GetModelContextSize("gpt-4"))
+}
@@ -32,7 +32,7 @@ type Model interface {
 func GenerateFromSinglePrompt(ctx context.Context, llm Model, prompt string, options ...CallOption) (string, error) {
 	msg := messages.MessageContent{
 		Role:  messages.ChatMessageTypeHuman,
-		Parts: []messages.ContentPart{messages.TextContent{prompt}},
+		Parts: []messages.ContentPart{messages.TextContent{Text: prompt}},
 	}
 
 	resp, err := llm.GenerateContent(ctx, []messages.MessageContent{msg}, options...)
@@ -60,8 +60,34 @@ type Generation struct {
 	StopReason string `json:"stop_reason"`
 }
 
+// Info returns the typed generation info of the generation.
+func (g *Generation) Info() messages.GenerationInfo {
+	info := messages.ParseGenerationInfo(g.GenerationInfo)
+	info.FinishReason = g.StopReason
+	return info
+}
+
 // LLMResult is the class that contains all relevant information for an LLM Result.
 type LLMResult struct {
 	Generations [][]*Generation
 	LLMOutput   map[string]any
 }
+
+// NewLLMResult returns the result of generations reported to the HandleLLMEnd
+// callback, with the token usage of all the generations under the TokenUsage
+// key of LLMOutput.
+func NewLLMResult(generations []*Generation) LLMResult {
+	total := &messages.Usage{}
+	for _, g := range generations {
+		if g == nil || g.Message == nil || g.Message.Usage == nil {
+			continue
+		}
+		total.PromptTokens += g.Message.Usage.PromptTokens
+		total.CompletionTokens += g.Message.Usage.CompletionTokens
+		total.TotalTokens += g.Message.Usage.TotalTokens
+	}
+	return LLMResult{
+		Generations: [][]*Generation{generations},
+		LLMOutput:   map[string]any{"TokenUsage": total},
+	}
+}
@@ -0,0 +1,149 @@
+package openai
+
+import (
+	"context"
+
+	"github.com/iflytek/spark-ai-go/sparkai/llms"
+	"github.com/iflytek/spark-ai-go/sparkai/llms/openai/client/openaiclient"
+	"github.com/iflytek/spark-ai-go/sparkai/messages"
+)
+
+// Chat is an OpenAI chat model. It implements llms.ChatLLM over the client of
+// an LLM.
+type Chat struct {
+	llm *LLM
+}
+
+var _ llms.ChatLLM = (*Chat)(nil)
+
+// NewChat returns a new OpenAI chat model, it accepts the options of New.
+func NewChat(opts ...Option) (*Chat, error) {
+	llm, err := New(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Chat{llm: llm}, nil
+}
+
+// Call requests a chat response for the given messages.
+func (o *Chat) Call(ctx context.Context, msgs []messages.ChatMessage, options ...llms.CallOption) (*messages.AIChatMessage, error) { //nolint: lll
+	r, err := o.Generate(ctx, [][]messages.ChatMessage{msgs}, options...)
+	if err != nil {
+		return nil, err
+	}
+	if len(r) == 0 {
+		return nil, ErrEmptyResponse
+	}
+	return r[0].Message, nil
+}
+
+// Generate requests a chat response for each conversation. Up to
+// WithMaxConcurrency conversations are generated in parallel.
+func (o *Chat) Generate(ctx context.Context, conversations [][]messages.ChatMessage, options ...llms.CallOption) ([]*llms.Generation, error) { //nolint: lll
+	handler := o.llm.CallbacksHandler
+	if handler != nil {
+		prompts := make([]string, 0, len(conversations))
+		for _, msgs := range conversations {
+			prompt, _ := messages.GetBufferString(msgs, "Human", "AI")
+			prompts = append(prompts, prompt)
+		}
+		handler.HandleLLMStart(ctx, prompts)
+	}
+
+	opts := llms.CallOptions{}
+	for _, opt := range options {
+		opt(&opts)
+	}
+	if err := o.llm.checkOptions(opts); err != nil {
+		return nil, err
+	}
+
+	generations, err := llms.GenerateChats(ctx, conversations, o.llm.maxConcurrency,
+		func(ctx context.Context, msgs []messages.ChatMessage) (*llms.Generation, error) {
+			result, err := o.llm.createChat(ctx, toChatMessages(msgs), opts)
+			if err != nil {
+				return nil, err
+			}
+			if len(result.Choices) == 0 {
+				return nil, ErrEmptyResponse
+			}
+			c := result.Choices[0]
+			usage := &messages.Usage{
+				PromptTokens:     int(result.Usage.PromptTokens),
+				CompletionTokens: int(result.Usage.CompletionTokens),
+				TotalTokens:      int(result.Usage.TotalTokens),
+			}
+			msg := &messages.AIChatMessage{
+				Content:   c.Message.Content,
+				ToolCalls: fromToolCalls(c.Message.ToolCalls),
+				Usage:     usage,
+			}
+			if c.Message.FunctionCall != nil {
+				msg.FunctionCall = &messages.FunctionCall{
+					Name:      c.Message.FunctionCall.Name,
+					Arguments: c.Message.FunctionCall.Arguments,
+				}
+			}
+			return &llms.Generation{
+				Text:    msg.Content,
+				Message: msg,
+				GenerationInfo: map[string]any{
+					messages.InfoCompletionTokens: usage.CompletionTokens,
+					messages.InfoPromptTokens:     usage.PromptTokens,
+					messages.InfoTotalTokens:      usage.TotalTokens,
+				},
+				StopReason: c.FinishReason,
+			}, nil
+		})
+	if err != nil {
+		if handler != nil {
+			handler.HandleLLMError(ctx, err)
+		}
+		return nil, err
+	}
+
+	if handler != nil {
+		handler.HandleLLMEnd(ctx, llms.NewLLMResult(generations))
+	}
+	return generations, nil
+}
+
+// toChatMessages converts the messages to the OpenAI format, generic
+// messages keep their role.
+func toChatMessages(msgs []messages.ChatMessage) []*ChatMessage {
+	chatMsgs := make([]*ChatMessage, 0, len(msgs))
+	for _, m := range msgs {
+		msg := &ChatMessage{Content: m.GetContent()}
+		switch m.GetType() {
+		case messages.ChatMessageTypeSystem:
+			msg.Role = RoleSystem
+		case messages.ChatMessageTypeAI:
+			msg.Role = RoleAssistant
+		case messages.ChatMessageTypeHuman:
+			msg.Role = RoleUser
+		case messages.ChatMessageTypeFunction:
+			msg.Role = RoleFunction
+		case messages.ChatMessageTypeTool:
+			msg.Role = RoleTool
+		default:
+			msg.Role = string(m.GetType())
+		}
+		if named, ok := m.(messages.Named); ok {
+			msg.Name = named.GetName()
+		}
+		if fc, ok := m.(interface{ GetFunctionCall() *messages.FunctionCall }); ok && fc.GetFunctionCall() != nil {
+			msg.FunctionCall = &openaiclient.FunctionCall{
+				Name:      fc.GetFunctionCall().Name,
+				Arguments: fc.GetFunctionCall().Arguments,
+			}
+		}
+		if tc, ok := m.(interface{ GetToolCalls() []messages.ToolCall }); ok {
+			msg.ToolCalls = toToolCalls(tc.GetToolCalls())
+		}
+		if tr, ok := m.(interface{ GetToolCallID() string }); ok {
+			msg.ToolCallID = tr.GetToolCallID()
+		}
+		chatMsgs = append(chatMsgs, msg)
+	}
+	return chatMsgs
+}
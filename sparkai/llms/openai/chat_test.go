@@ -0,0 +1,119 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/iflytek/spark-ai-go/sparkai/callbacks"
+	"github.com/iflytek/spark-ai-go/sparkai/llms"
+	"github.com/iflytek/spark-ai-go/sparkai/messages"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// endRecorder records the results of the HandleLLMEnd callbacks.
+type endRecorder struct {
+	callbacks.Handler
+	results []llms.LLMResult
+}
+
+func (r *endRecorder) HandleLLMStart(context.Context, []string) {}
+
+func (r *endRecorder) HandleLLMEnd(_ context.Context, output llms.LLMResult) {
+	r.results = append(r.results, output)
+}
+
+func TestChatCall(t *testing.T) {
+	t.Parallel()
+	var req map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"choices":[{"index":0,"finish_reason":"function_call","message":{"role":"assistant","content":"",` +
+			`"function_call":{"name":"get_weather","arguments":"{\"location\":\"Hefei\"}"}}}],` +
+			`"usage":{"prompt_tokens":5,"completion_tokens":9,"total_tokens":14}}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	handler := &endRecorder{}
+	chat, err := NewChat(WithToken("token"), WithModel("gpt-3.5-turbo"), WithBaseURL(srv.URL), WithCallback(handler))
+	require.NoError(t, err)
+	msg, err := chat.Call(context.Background(), []messages.ChatMessage{
+		messages.SystemChatMessage{Content: "You are a weather assistant"},
+		messages.HumanChatMessage{Content: "Weather in Hefei?"},
+		messages.AIChatMessage{Content: "Let me check."},
+		messages.FunctionChatMessage{Name: "get_weather", Content: `{"weather":"sunny"}`},
+	})
+	require.NoError(t, err)
+	require.NotNil(t, msg.FunctionCall)
+	assert.Equal(t, "get_weather", msg.FunctionCall.Name)
+	assert.Equal(t, `{"location":"Hefei"}`, msg.FunctionCall.Arguments)
+	assert.Equal(t, &messages.Usage{PromptTokens: 5, CompletionTokens: 9, TotalTokens: 14}, msg.Usage)
+	require.Len(t, handler.results, 1)
+	assert.Equal(t, msg.Usage, handler.results[0].LLMOutput["TokenUsage"])
+
+	got, err := json.Marshal(req["messages"])
+	require.NoError(t, err)
+	assert.JSONEq(t, `[
+		{"role":"system","content":"You are a weather assistant"},
+		{"role":"user","content":"Weather in Hefei?"},
+		{"role":"assistant","content":"Let me check."},
+		{"role":"function","content":"{\"weather\":\"sunny\"}","name":"get_weather"}
+	]`, string(got))
+}
+
+func TestGenerateContentOptions(t *testing.T) {
+	t.Parallel()
+	var req map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"Hello"}}]}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	llm, err := New(WithToken("token"), WithModel("gpt-3.5-turbo"), WithBaseURL(srv.URL), WithStrictOptions(true))
+	require.NoError(t, err)
+	_, err = llm.Call(context.Background(), "hi",
+		llms.WithTopP(0.9), llms.WithSeed(42), llms.WithUserID("user-1"), llms.WithStopWords([]string{"\n"}))
+	require.NoError(t, err)
+	assert.InDelta(t, 0.9, req["top_p"], 1e-9)
+	assert.InDelta(t, 42, req["seed"], 1e-9)
+	assert.Equal(t, "user-1", req["user"])
+	assert.Equal(t, []any{"\n"}, req["stop"])
+
+	_, err = llm.Call(context.Background(), "hi", llms.WithTopK(3), llms.WithAuditing("strict"))
+	require.ErrorIs(t, err, llms.ErrUnsupportedOption)
+	assert.Contains(t, err.Error(), "top_k, auditing")
+}
+
+func TestGenerateJSONResponseFormat(t *testing.T) {
+	t.Parallel()
+	var req map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant",` +
+			`"content":"{\"city\":\"Hefei\"}"}}]}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	llm, err := New(WithToken("token"), WithModel("gpt-4o"), WithBaseURL(srv.URL), WithStrictOptions(true))
+	require.NoError(t, err)
+	schema := &llms.Schema{Type: "object", Properties: map[string]*llms.Schema{"city": {Type: "string"}}}
+	got, err := llms.GenerateJSON[struct {
+		City string `json:"city"`
+	}](context.Background(), llm,
+		[]messages.MessageContent{messages.TextParts(messages.ChatMessageTypeHuman, "Where is the weather sunny?")}, 0,
+		llms.WithJSONSchema("city", schema))
+	require.NoError(t, err)
+	assert.Equal(t, "Hefei", got.City)
+
+	format, err := json.Marshal(req["response_format"])
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type":"json_schema","json_schema":{"name":"city",`+
+		`"schema":{"type":"object","properties":{"city":{"type":"string"}}}}}`, string(format))
+}
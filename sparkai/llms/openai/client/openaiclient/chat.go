@@ -1,16 +1,16 @@
 package openaiclient
 
 import (
-	"bufio"
-	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"github.com/iflytek/spark-ai-go/sparkai/messages"
-	"log"
+	"io"
+	"math"
 	"net/http"
 	"strings"
+
+	"github.com/iflytek/spark-ai-go/sparkai/messages"
 )
 
 const (
@@ -31,6 +31,14 @@ type ChatRequest struct {
 	Stream           bool           `json:"stream,omitempty"`
 	FrequencyPenalty float64        `json:"frequency_penalty,omitempty"`
 	PresencePenalty  float64        `json:"presence_penalty,omitempty"`
+	Seed             int            `json:"seed,omitempty"`
+	// User is the end user identifier.
+	User string `json:"user,omitempty"`
+	// StreamOptions is set by the client when streaming from OpenAI, to
+	// receive the usage at the end of the stream.
+	StreamOptions *StreamOptions `json:"stream_options,omitempty"`
+	// ResponseFormat asks for a JSON answer.
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
 
 	// Function definitions to include in the request.
 	Functions []FunctionDefinition `json:"functions,omitempty"`
@@ -40,11 +48,38 @@ type ChatRequest struct {
 	// `{"name": "my_function"}`
 	FunctionCallBehavior FunctionCallBehavior `json:"function_call,omitempty"`
 
+	// Tools are the tools the model may call, they replace Functions.
+	Tools []Tool `json:"tools,omitempty"`
+	// ToolChoice is ToolChoiceAuto, ToolChoiceNone, ToolChoiceRequired or a
+	// ToolChoice naming the function to call.
+	ToolChoice any `json:"tool_choice,omitempty"`
+
 	// StreamingFunc is a function to be called for each chunk of a streaming response.
 	// Return an error to stop streaming early.
 	StreamingFunc func(ctx context.Context, chunk []byte) error `json:"-"`
 }
 
+// ResponseFormat is the format of the answer: text, json_object or
+// json_schema.
+type ResponseFormat struct {
+	Type       string                    `json:"type"`
+	JSONSchema *ResponseFormatJSONSchema `json:"json_schema,omitempty"`
+}
+
+// ResponseFormatJSONSchema is the schema of a json_schema answer.
+type ResponseFormatJSONSchema struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Schema      any    `json:"schema"`
+	Strict      bool   `json:"strict,omitempty"`
+}
+
+// StreamOptions are the options of a streamed response.
+type StreamOptions struct {
+	// IncludeUsage requests a last chunk carrying the usage of the request.
+	IncludeUsage bool `json:"include_usage"`
+}
+
 // ChatMessage is a message in a chat request.
 type ChatMessage struct { //nolint:musttag
 	// The role of the author of this message. One of system, user, or assistant.
@@ -60,6 +95,12 @@ type ChatMessage struct { //nolint:musttag
 
 	// FunctionCall represents a function call to be made in the message.
 	FunctionCall *FunctionCall
+
+	// ToolCalls are the tool calls made by the model.
+	ToolCalls []ToolCall
+
+	// ToolCallID is the id of the tool call answered by a tool message.
+	ToolCallID string
 }
 
 func (m ChatMessage) MarshalJSON() ([]byte, error) {
@@ -73,6 +114,8 @@ func (m ChatMessage) MarshalJSON() ([]byte, error) {
 			MultiContent []messages.ContentPart `json:"content,omitempty"`
 			Name         string                 `json:"name,omitempty"`
 			FunctionCall *FunctionCall          `json:"function_call,omitempty"`
+			ToolCalls    []ToolCall             `json:"tool_calls,omitempty"`
+			ToolCallID   string                 `json:"tool_call_id,omitempty"`
 		}(m)
 		return json.Marshal(msg)
 	}
@@ -82,6 +125,8 @@ func (m ChatMessage) MarshalJSON() ([]byte, error) {
 		MultiContent []messages.ContentPart `json:"-"`
 		Name         string                 `json:"name,omitempty"`
 		FunctionCall *FunctionCall          `json:"function_call,omitempty"`
+		ToolCalls    []ToolCall             `json:"tool_calls,omitempty"`
+		ToolCallID   string                 `json:"tool_call_id,omitempty"`
 	}(m)
 	return json.Marshal(msg)
 }
@@ -93,6 +138,8 @@ func (m *ChatMessage) UnmarshalJSON(data []byte) error {
 		MultiContent []messages.ContentPart `json:"-"` // not expected in response
 		Name         string                 `json:"name,omitempty"`
 		FunctionCall *FunctionCall          `json:"function_call,omitempty"`
+		ToolCalls    []ToolCall             `json:"tool_calls,omitempty"`
+		ToolCallID   string                 `json:"tool_call_id,omitempty"`
 	}{}
 	err := json.Unmarshal(data, &msg)
 	if err != nil {
@@ -139,12 +186,67 @@ type StreamedChatResponsePayload struct {
 	Choices []struct {
 		Index float64 `json:"index,omitempty"`
 		Delta struct {
-			Role         string        `json:"role,omitempty"`
-			Content      string        `json:"content,omitempty"`
-			FunctionCall *FunctionCall `json:"function_call,omitempty"`
+			Role         string             `json:"role,omitempty"`
+			Content      string             `json:"content,omitempty"`
+			FunctionCall *FunctionCall      `json:"function_call,omitempty"`
+			ToolCalls    []StreamedToolCall `json:"tool_calls,omitempty"`
 		} `json:"delta,omitempty"`
 		FinishReason string `json:"finish_reason,omitempty"`
 	} `json:"choices,omitempty"`
+	// Usage is only set in the last chunk, when requested with StreamOptions.
+	Usage *ChatUsage `json:"usage,omitempty"`
+	// Error is set when the stream fails after it started.
+	Error *StreamError `json:"error,omitempty"`
+}
+
+// StreamedToolCall is a fragment of a tool call, the fragments sharing an
+// index make up one call.
+type StreamedToolCall struct {
+	Index int `json:"index"`
+	ToolCall
+}
+
+// ToolTypeFunction is the type of the function tools.
+const ToolTypeFunction = "function"
+
+// Tool is a tool the model may call.
+type Tool struct {
+	// Type is the type of the tool, ToolTypeFunction.
+	Type string `json:"type"`
+	// Function is the definition of the function.
+	Function FunctionDefinition `json:"function"`
+}
+
+const (
+	// ToolChoiceNone will not call any tools.
+	ToolChoiceNone = "none"
+	// ToolChoiceAuto lets the model choose whether to call tools.
+	ToolChoiceAuto = "auto"
+	// ToolChoiceRequired makes the model call at least one tool.
+	ToolChoiceRequired = "required"
+)
+
+// ToolChoice forces the model to call a specific function.
+type ToolChoice struct {
+	// Type is the type of the tool, ToolTypeFunction.
+	Type string `json:"type"`
+	// Function names the function to call.
+	Function ToolFunction `json:"function"`
+}
+
+// ToolFunction names a function.
+type ToolFunction struct {
+	Name string `json:"name"`
+}
+
+// ToolCall is a call to a tool made by the model.
+type ToolCall struct {
+	// ID identifies the call, the tool result refers to it.
+	ID string `json:"id,omitempty"`
+	// Type is the type of the tool, only function is supported.
+	Type string `json:"type,omitempty"`
+	// Function is the function to call.
+	Function FunctionCall `json:"function"`
 }
 
 // FunctionDefinition is a definition of a function that can be called by the model.
@@ -180,6 +282,9 @@ type FunctionCall struct {
 func (c *Client) createChat(ctx context.Context, payload *ChatRequest) (*ChatResponse, error) {
 	if payload.StreamingFunc != nil {
 		payload.Stream = true
+		if payload.StreamOptions == nil && c.apiType == APITypeOpenAI {
+			payload.StreamOptions = &StreamOptions{IncludeUsage: true}
+		}
 	}
 	// Build request payload
 
@@ -189,36 +294,17 @@ func (c *Client) createChat(ctx context.Context, payload *ChatRequest) (*ChatRes
 	}
 
 	// Build request
-	body := bytes.NewReader(payloadBytes)
 	if c.baseURL == "" {
 		c.baseURL = defaultBaseURL
 	}
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.buildURL("/chat/completions", c.Model), body)
-	if err != nil {
-		return nil, err
-	}
-
-	c.setHeaders(req)
 
 	// Send request
-	r, err := c.httpClient.Do(req)
+	r, err := c.post(ctx, c.buildURL("/chat/completions", c.Model), payloadBytes)
 	if err != nil {
 		return nil, err
 	}
 	defer r.Body.Close()
 
-	if r.StatusCode != http.StatusOK {
-		msg := fmt.Sprintf("API returned unexpected status code: %d", r.StatusCode)
-
-		// No need to check the error here: if it fails, we'll just return the
-		// status code.
-		var errResp errorMessage
-		if err := json.NewDecoder(r.Body).Decode(&errResp); err != nil {
-			return nil, errors.New(msg) // nolint:goerr113
-		}
-
-		return nil, fmt.Errorf("%s: %s", msg, errResp.Error.Message) // nolint:goerr113
-	}
 	if payload.StreamingFunc != nil {
 		return parseStreamingChatResponse(ctx, r, payload)
 	}
@@ -227,63 +313,148 @@ func (c *Client) createChat(ctx context.Context, payload *ChatRequest) (*ChatRes
 	return &response, json.NewDecoder(r.Body).Decode(&response)
 }
 
-func parseStreamingChatResponse(ctx context.Context, r *http.Response, payload *ChatRequest) (*ChatResponse, error) { //nolint:cyclop,lll
-	scanner := bufio.NewScanner(r.Body)
-	responseChan := make(chan StreamedChatResponsePayload)
-	go func() {
-		defer close(responseChan)
-		for scanner.Scan() {
-			line := scanner.Text()
-			if line == "" {
-				continue
-			}
-			if !strings.HasPrefix(line, "data:") {
-				log.Fatalf("unexpected line: %v", line)
-			}
-			data := strings.TrimPrefix(line, "data: ")
-			if data == "[DONE]" {
-				return
-			}
-			var streamPayload StreamedChatResponsePayload
-			err := json.NewDecoder(bytes.NewReader([]byte(data))).Decode(&streamPayload)
-			if err != nil {
-				log.Fatalf("failed to decode stream payload: %v", err)
+// parseStreamingChatResponse assembles the chunks of a streamed response. The
+// streaming function receives the content deltas of the first choice, or the
+// function or tool calls accumulated so far while they are streamed.
+func parseStreamingChatResponse(ctx context.Context, r *http.Response, payload *ChatRequest) (*ChatResponse, error) { //nolint:lll
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		// 服务端没有按流式返回, 通常是错误
+		return parseUnstreamedChatResponse(ctx, r.Body, payload)
+	}
+	response := &ChatResponse{}
+	events := newSSEReader(r.Body)
+	for {
+		event, err := events.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read stream: %w", err)
+		}
+		if event.Data == "[DONE]" {
+			break
+		}
+		var streamPayload StreamedChatResponsePayload
+		if err := json.Unmarshal([]byte(event.Data), &streamPayload); err != nil {
+			if event.Event == "error" {
+				return nil, &StreamError{Message: event.Data}
 			}
-			responseChan <- streamPayload
+			return nil, fmt.Errorf("decode stream payload %q: %w", event.Data, err)
 		}
-		if err := scanner.Err(); err != nil {
-			log.Println("issue scanning response:", err)
+		if streamPayload.Error != nil {
+			return nil, streamPayload.Error
+		}
+		chunk, ok, err := accumulateChunk(response, &streamPayload)
+		if err != nil {
+			return nil, err
+		}
+		if !ok || payload.StreamingFunc == nil {
+			continue
+		}
+		if err := payload.StreamingFunc(ctx, chunk); err != nil {
+			return nil, fmt.Errorf("streaming func returned an error: %w", err)
 		}
-	}()
-	// Parse response
-	response := ChatResponse{
-		Choices: []*ChatChoice{
-			{},
-		},
 	}
+	return response, nil
+}
 
-	for streamResponse := range responseChan {
-		if len(streamResponse.Choices) == 0 {
-			continue
+// parseUnstreamedChatResponse reads a response sent as a single JSON document
+// although streaming was requested.
+func parseUnstreamedChatResponse(ctx context.Context, body io.Reader, payload *ChatRequest) (*ChatResponse, error) { //nolint:lll
+	var response struct {
+		ChatResponse
+		Error *StreamError `json:"error,omitempty"`
+	}
+	if err := json.NewDecoder(body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+	if response.Error != nil {
+		return nil, response.Error
+	}
+	if len(response.Choices) > 0 && response.Choices[0].Message.Content != "" && payload.StreamingFunc != nil {
+		if err := payload.StreamingFunc(ctx, []byte(response.Choices[0].Message.Content)); err != nil {
+			return nil, fmt.Errorf("streaming func returned an error: %w", err)
+		}
+	}
+	return &response.ChatResponse, nil
+}
+
+// Bounds of the choice and tool call indexes of a stream, the indexes come
+// from the server and size the accumulated response.
+const (
+	maxStreamChoices   = 128
+	maxStreamToolCalls = 128
+)
+
+// accumulateChunk merges a chunk into response. It returns the chunk to pass
+// to the streaming function, ok is false when the chunk has nothing to stream.
+// An index out of bounds is an error.
+func accumulateChunk(response *ChatResponse, streamPayload *StreamedChatResponsePayload) (chunk []byte, ok bool, err error) { //nolint:lll
+	if streamPayload.ID != "" {
+		response.ID = streamPayload.ID
+		response.Created = streamPayload.Created
+		response.Model = streamPayload.Model
+	}
+	if u := streamPayload.Usage; u != nil {
+		response.Usage.PromptTokens = float64(u.PromptTokens)
+		response.Usage.CompletionTokens = float64(u.CompletionTokens)
+		response.Usage.TotalTokens = float64(u.TotalTokens)
+	}
+	for _, c := range streamPayload.Choices {
+		if c.Index < 0 || c.Index >= maxStreamChoices || c.Index != math.Trunc(c.Index) {
+			return nil, false, fmt.Errorf("invalid choice index %v in stream", c.Index) //nolint:goerr113
+		}
+		index := int(c.Index)
+		for len(response.Choices) <= index {
+			response.Choices = append(response.Choices, &ChatChoice{Index: len(response.Choices)})
+		}
+		choice := response.Choices[index]
+		msg := &choice.Message
+		if c.Delta.Role != "" {
+			msg.Role = c.Delta.Role
 		}
-		chunk := []byte(streamResponse.Choices[0].Delta.Content)
-		response.Choices[0].Message.Content += streamResponse.Choices[0].Delta.Content
-		response.Choices[0].FinishReason = streamResponse.Choices[0].FinishReason
-		if streamResponse.Choices[0].Delta.FunctionCall != nil {
-			if response.Choices[0].Message.FunctionCall == nil {
-				response.Choices[0].Message.FunctionCall = streamResponse.Choices[0].Delta.FunctionCall
-			} else {
-				response.Choices[0].Message.FunctionCall.Arguments += streamResponse.Choices[0].Delta.FunctionCall.Arguments
+		msg.Content += c.Delta.Content
+		if c.FinishReason != "" {
+			choice.FinishReason = c.FinishReason
+		}
+		if fc := c.Delta.FunctionCall; fc != nil {
+			if msg.FunctionCall == nil {
+				msg.FunctionCall = &FunctionCall{}
 			}
-			chunk, _ = json.Marshal(response.Choices[0].Message.FunctionCall) // nolint:errchkjson
+			msg.FunctionCall.Name += fc.Name
+			msg.FunctionCall.Arguments += fc.Arguments
 		}
-
-		if payload.StreamingFunc != nil {
-			err := payload.StreamingFunc(ctx, chunk)
-			if err != nil {
-				return nil, fmt.Errorf("streaming func returned an error: %w", err)
+		for _, tc := range c.Delta.ToolCalls {
+			if tc.Index < 0 || tc.Index >= maxStreamToolCalls {
+				return nil, false, fmt.Errorf("invalid tool call index %d in stream", tc.Index) //nolint:goerr113
+			}
+			for len(msg.ToolCalls) <= tc.Index {
+				msg.ToolCalls = append(msg.ToolCalls, ToolCall{})
+			}
+			call := &msg.ToolCalls[tc.Index]
+			if tc.ID != "" {
+				call.ID = tc.ID
+			}
+			if tc.Type != "" {
+				call.Type = tc.Type
 			}
+			call.Function.Name += tc.Function.Name
+			call.Function.Arguments += tc.Function.Arguments
+		}
+
+		if index != 0 {
+			continue
+		}
+		switch {
+		case c.Delta.FunctionCall != nil:
+			chunk, _ = json.Marshal(msg.FunctionCall) // nolint:errchkjson
+			ok = true
+		case len(c.Delta.ToolCalls) > 0:
+			chunk, _ = json.Marshal(msg.ToolCalls) // nolint:errchkjson
+			ok = true
+		case c.Delta.Content != "":
+			chunk, ok = []byte(c.Delta.Content), true
 		}
 	}
-	return &response, nil
+	return chunk, ok, nil
 }
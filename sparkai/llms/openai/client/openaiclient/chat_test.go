@@ -6,7 +6,14 @@ import (
 	"encoding/json"
 	"io"
 	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/iflytek/spark-ai-go/sparkai/retry"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -33,6 +40,173 @@ func TestParseStreamingChatResponse_FinishReason(t *testing.T) {
 	assert.Equal(t, "stop", resp.Choices[0].FinishReason)
 }
 
+func TestParseStreamingChatResponseInvalidIndex(t *testing.T) {
+	t.Parallel()
+	for _, data := range []string{
+		`{"choices":[{"index":-1,"delta":{"content":"hello"}}]}`,
+		`{"choices":[{"index":1e12,"delta":{"content":"hello"}}]}`,
+		`{"choices":[{"index":0.5,"delta":{"content":"hello"}}]}`,
+		`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":-2,"function":{"name":"f"}}]}}]}`,
+		`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":1000000000,"function":{"name":"f"}}]}}]}`,
+	} {
+		r := &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(bytes.NewBufferString("data: " + data + "\n\n")),
+		}
+		_, err := parseStreamingChatResponse(context.Background(), r, &ChatRequest{})
+		require.ErrorContains(t, err, "index", data)
+	}
+}
+
+// newReplayServer starts a server answering every request with the recorded
+// stream testdata/name.
+func newReplayServer(t *testing.T, name string) (*httptest.Server, *atomic.Value) {
+	t.Helper()
+	stream, err := os.ReadFile(filepath.Join("testdata", name))
+	require.NoError(t, err)
+	var lastRequest atomic.Value
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		lastRequest.Store(body)
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write(stream)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &lastRequest
+}
+
+func newTestClient(t *testing.T, srv *httptest.Server) *Client {
+	t.Helper()
+	c, err := New("token", "gpt-3.5-turbo", srv.URL, "", APITypeOpenAI, "", http.DefaultClient, "")
+	require.NoError(t, err)
+	return c
+}
+
+// streamChat sends a streamed chat request and returns the streamed chunks.
+func streamChat(t *testing.T, c *Client) (*ChatResponse, []string, error) {
+	t.Helper()
+	var chunks []string
+	resp, err := c.CreateChat(context.Background(), &ChatRequest{
+		Messages: []*ChatMessage{{Role: "user", Content: "hi"}},
+		StreamingFunc: func(_ context.Context, chunk []byte) error {
+			chunks = append(chunks, string(chunk))
+			return nil
+		},
+	})
+	return resp, chunks, err
+}
+
+func TestCreateChatStreamContent(t *testing.T) {
+	t.Parallel()
+	srv, lastRequest := newReplayServer(t, "stream_content.txt")
+
+	resp, chunks, err := streamChat(t, newTestClient(t, srv))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Hel", "lo"}, chunks)
+	assert.Equal(t, "chatcmpl-1", resp.ID)
+	assert.Equal(t, "gpt-3.5-turbo-0125", resp.Model)
+	require.Len(t, resp.Choices, 1)
+	assert.Equal(t, "assistant", resp.Choices[0].Message.Role)
+	assert.Equal(t, "Hello", resp.Choices[0].Message.Content)
+	assert.Equal(t, "stop", resp.Choices[0].FinishReason)
+	assert.InDelta(t, 8, resp.Usage.PromptTokens, 0)
+	assert.InDelta(t, 2, resp.Usage.CompletionTokens, 0)
+	assert.InDelta(t, 10, resp.Usage.TotalTokens, 0)
+
+	var req map[string]any
+	require.NoError(t, json.Unmarshal(lastRequest.Load().([]byte), &req))
+	assert.Equal(t, true, req["stream"])
+	assert.Equal(t, map[string]any{"include_usage": true}, req["stream_options"])
+}
+
+func TestCreateChatStreamFunctionCall(t *testing.T) {
+	t.Parallel()
+	srv, _ := newReplayServer(t, "stream_function_call.txt")
+
+	resp, chunks, err := streamChat(t, newTestClient(t, srv))
+	require.NoError(t, err)
+	assert.Equal(t, &FunctionCall{Name: "get_weather", Arguments: `{"location":"合肥"}`}, resp.Choices[0].Message.FunctionCall)
+	assert.Equal(t, "function_call", resp.Choices[0].FinishReason)
+	require.Len(t, chunks, 3)
+	assert.JSONEq(t, `{"name":"get_weather","arguments":"{\"location\":\"合肥\"}"}`, chunks[2])
+}
+
+func TestCreateChatStreamToolCalls(t *testing.T) {
+	t.Parallel()
+	srv, _ := newReplayServer(t, "stream_tool_calls.txt")
+
+	resp, _, err := streamChat(t, newTestClient(t, srv))
+	require.NoError(t, err)
+	assert.Equal(t, []ToolCall{
+		{ID: "call_hf", Type: "function", Function: FunctionCall{Name: "get_weather", Arguments: `{"location": "合肥"}`}},
+		{ID: "call_bj", Type: "function", Function: FunctionCall{Name: "get_weather", Arguments: `{"location": "北京"}`}},
+	}, resp.Choices[0].Message.ToolCalls)
+	assert.Equal(t, "tool_calls", resp.Choices[0].FinishReason)
+}
+
+func TestCreateChatStreamError(t *testing.T) {
+	t.Parallel()
+	srv, _ := newReplayServer(t, "stream_error.txt")
+
+	_, chunks, err := streamChat(t, newTestClient(t, srv))
+	var streamErr *StreamError
+	require.ErrorAs(t, err, &streamErr)
+	assert.Equal(t, "server_error", streamErr.Type)
+	assert.Equal(t, "The server had an error while processing your request.", streamErr.Message)
+	assert.Equal(t, []string{"Hel"}, chunks)
+}
+
+func TestCreateChatStreamJSONError(t *testing.T) {
+	t.Parallel()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"error":{"message":"model overloaded","type":"server_error"}}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	_, _, err := streamChat(t, newTestClient(t, srv))
+	require.EqualError(t, err, "stream error: server_error: model overloaded")
+}
+
+func TestParseStreamingChatResponse_Choices(t *testing.T) {
+	t.Parallel()
+	mockBody := "data: {\"choices\":[{\"index\":1,\"delta\":{\"content\":\"b\"}}]}\n\n" +
+		"data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"a\"},\"finish_reason\":\"stop\"}]}\n\n" +
+		"data: {\"choices\":[{\"index\":1,\"delta\":{},\"finish_reason\":\"length\"}]}\n\n" +
+		"data: [DONE]\n\n" +
+		"data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"ignored\"}}]}\n\n"
+	r := &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(bytes.NewBufferString(mockBody)),
+	}
+
+	var chunks []string
+	resp, err := parseStreamingChatResponse(context.Background(), r, &ChatRequest{
+		StreamingFunc: func(_ context.Context, chunk []byte) error {
+			chunks = append(chunks, string(chunk))
+			return nil
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.Choices, 2)
+	assert.Equal(t, "a", resp.Choices[0].Message.Content)
+	assert.Equal(t, "stop", resp.Choices[0].FinishReason)
+	assert.Equal(t, 1, resp.Choices[1].Index)
+	assert.Equal(t, "b", resp.Choices[1].Message.Content)
+	assert.Equal(t, "length", resp.Choices[1].FinishReason)
+	assert.Equal(t, []string{"a"}, chunks)
+}
+
+func TestParseStreamingChatResponse_InvalidPayload(t *testing.T) {
+	t.Parallel()
+	r := &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(bytes.NewBufferString("data: {not json\n\n")),
+	}
+	_, err := parseStreamingChatResponse(context.Background(), r, &ChatRequest{})
+	require.ErrorContains(t, err, "decode stream payload")
+}
+
 func TestChatMessage_MarshalUnmarshal(t *testing.T) {
 	t.Parallel()
 	msg := ChatMessage{
@@ -52,3 +226,61 @@ func TestChatMessage_MarshalUnmarshal(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, msg, msg2)
 }
+
+func TestCreateChatRetry(t *testing.T) {
+	t.Parallel()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = io.WriteString(w, `{"error":{"message":"Rate limit reached","type":"requests"}}`)
+			return
+		}
+		_, _ = io.WriteString(w, `{"choices":[{"index":0,"message":{"role":"assistant","content":"hello"},"finish_reason":"stop"}]}`)
+	}))
+	defer srv.Close()
+
+	var retryErr error
+	c, err := New("token", "gpt-3.5-turbo", srv.URL, "", APITypeOpenAI, "", http.DefaultClient, "",
+		WithRetryPolicy(retry.Policy{
+			MaxAttempts:    2,
+			InitialBackoff: time.Millisecond,
+			OnRetry: func(_ context.Context, _ int, _ time.Duration, err error) {
+				retryErr = err
+			},
+		}))
+	require.NoError(t, err)
+
+	resp, err := c.CreateChat(context.Background(), &ChatRequest{
+		Messages: []*ChatMessage{{Role: "user", Content: "hi"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "hello", resp.Choices[0].Message.Content)
+	assert.Equal(t, int32(2), calls.Load())
+
+	var apiErr *APIError
+	require.ErrorAs(t, retryErr, &apiErr)
+	assert.Equal(t, http.StatusTooManyRequests, apiErr.StatusCode)
+	assert.Equal(t, "Rate limit reached", apiErr.Message)
+}
+
+func TestCreateChatNoRetryOnBadRequest(t *testing.T) {
+	t.Parallel()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	c, err := New("token", "gpt-3.5-turbo", srv.URL, "", APITypeOpenAI, "", http.DefaultClient, "",
+		WithRetryPolicy(retry.Policy{MaxAttempts: 3, InitialBackoff: time.Millisecond}))
+	require.NoError(t, err)
+
+	_, err = c.CreateChat(context.Background(), &ChatRequest{
+		Messages: []*ChatMessage{{Role: "user", Content: "hi"}},
+	})
+	require.EqualError(t, err, "API returned unexpected status code: 400")
+	assert.Equal(t, int32(1), calls.Load())
+}
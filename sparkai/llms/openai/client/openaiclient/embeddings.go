@@ -1,12 +1,9 @@
 package openaiclient
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"net/http"
 )
 
 const (
@@ -41,32 +38,12 @@ func (c *Client) createEmbedding(ctx context.Context, payload *embeddingPayload)
 	if c.baseURL == "" {
 		c.baseURL = defaultBaseURL
 	}
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.buildURL("/embeddings", c.embeddingsModel), bytes.NewReader(payloadBytes))
-	if err != nil {
-		return nil, fmt.Errorf("create request: %w", err)
-	}
-
-	c.setHeaders(req)
-
-	r, err := c.httpClient.Do(req)
+	r, err := c.post(ctx, c.buildURL("/embeddings", c.embeddingsModel), payloadBytes)
 	if err != nil {
 		return nil, fmt.Errorf("send request: %w", err)
 	}
 	defer r.Body.Close()
 
-	if r.StatusCode != http.StatusOK {
-		msg := fmt.Sprintf("API returned unexpected status code: %d", r.StatusCode)
-
-		// No need to check the error here: if it fails, we'll just return the
-		// status code.
-		var errResp errorMessage
-		if err := json.NewDecoder(r.Body).Decode(&errResp); err != nil {
-			return nil, errors.New(msg) // nolint:goerr113
-		}
-
-		return nil, fmt.Errorf("%s: %s", msg, errResp.Error.Message) // nolint:goerr113
-	}
-
 	var response embeddingResponsePayload
 
 	if err := json.NewDecoder(r.Body).Decode(&response); err != nil {
@@ -0,0 +1,74 @@
+package openaiclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/iflytek/spark-ai-go/sparkai/retry"
+)
+
+// APIError is returned when the OpenAI API answers with an unexpected status code.
+type APIError struct {
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+	// Message is the error message returned by the API, if any.
+	Message string
+	// Type is the error type returned by the API, if any.
+	Type string
+
+	retryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("API returned unexpected status code: %d", e.StatusCode)
+	if e.Message == "" {
+		return msg
+	}
+	return fmt.Sprintf("%s: %s", msg, e.Message)
+}
+
+// Retryable reports whether the request may succeed if retried.
+func (e *APIError) Retryable() bool {
+	return e.StatusCode == http.StatusTooManyRequests ||
+		e.StatusCode == http.StatusRequestTimeout ||
+		e.StatusCode >= http.StatusInternalServerError
+}
+
+// RetryAfter returns the delay requested by the Retry-After header, or zero.
+func (e *APIError) RetryAfter() time.Duration {
+	return e.retryAfter
+}
+
+// StreamError is returned when the API reports an error in the middle of a
+// streamed response.
+type StreamError struct {
+	// Message is the error message returned by the API.
+	Message string `json:"message"`
+	// Type is the error type returned by the API, if any.
+	Type string `json:"type"`
+}
+
+func (e *StreamError) Error() string {
+	if e.Type == "" {
+		return "stream error: " + e.Message
+	}
+	return fmt.Sprintf("stream error: %s: %s", e.Type, e.Message)
+}
+
+// newAPIError builds an APIError from a response with an unexpected status code.
+func newAPIError(r *http.Response) *APIError {
+	apiErr := &APIError{
+		StatusCode: r.StatusCode,
+		retryAfter: retry.ParseRetryAfter(r.Header.Get("Retry-After")),
+	}
+	// No need to check the error here: if it fails, we'll just return the
+	// status code.
+	var errResp errorMessage
+	if err := json.NewDecoder(r.Body).Decode(&errResp); err == nil {
+		apiErr.Message = errResp.Error.Message
+		apiErr.Type = errResp.Error.Type
+	}
+	return apiErr
+}
@@ -1,11 +1,14 @@
 package openaiclient
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"net/http"
 	"strings"
+
+	"github.com/iflytek/spark-ai-go/sparkai/retry"
 )
 
 const (
@@ -36,11 +39,23 @@ type Client struct {
 	// required when APIType is APITypeAzure or APITypeAzureAD
 	apiVersion      string
 	embeddingsModel string
+
+	// retryPolicy is applied to API requests, the zero value disables retries.
+	retryPolicy retry.Policy
 }
 
 // Option is an option for the OpenAI client.
 type Option func(*Client) error
 
+// WithRetryPolicy sets the policy used to retry failed requests. Streamed
+// responses are only retried until the response headers are received.
+func WithRetryPolicy(policy retry.Policy) Option {
+	return func(c *Client) error {
+		c.retryPolicy = policy
+		return nil
+	}
+}
+
 // Doer performs a HTTP request.
 type Doer interface {
 	Do(req *http.Request) (*http.Response, error)
@@ -160,6 +175,31 @@ func (c *Client) setHeaders(req *http.Request) {
 	}
 }
 
+// post sends payload to url, retrying according to the client retry policy.
+// The returned response has a 200 status code, the caller must close its body.
+func (c *Client) post(ctx context.Context, url string, payload []byte) (*http.Response, error) {
+	var r *http.Response
+	err := retry.Do(ctx, c.retryPolicy, func(int) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+		if err != nil {
+			return retry.Permanent(err)
+		}
+		c.setHeaders(req)
+
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK {
+			defer resp.Body.Close()
+			return newAPIError(resp)
+		}
+		r = resp
+		return nil
+	})
+	return r, err
+}
+
 func (c *Client) buildURL(suffix string, model string) string {
 	if IsAzure(c.apiType) {
 		return c.buildAzureURL(suffix, model)
@@ -0,0 +1,70 @@
+package openaiclient
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+)
+
+// sseEvent is a server-sent event.
+type sseEvent struct {
+	// Event is the event type, empty for the default message type.
+	Event string
+	// Data is the data of the event, the lines of multi-line data are joined
+	// with a newline.
+	Data string
+}
+
+// sseReader reads server-sent events following
+// https://html.spec.whatwg.org/multipage/server-sent-events.html#event-stream-interpretation.
+// Unlike the specification, an event not followed by a blank line at the end
+// of the stream is still dispatched.
+type sseReader struct {
+	r *bufio.Reader
+}
+
+func newSSEReader(r io.Reader) *sseReader {
+	return &sseReader{r: bufio.NewReader(r)}
+}
+
+// next returns the next event, io.EOF at the end of the stream. Lines are
+// read whole, so events are not limited in size.
+func (s *sseReader) next() (sseEvent, error) {
+	var (
+		event sseEvent
+		data  []string
+	)
+	for {
+		line, err := s.r.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return sseEvent{}, err
+		}
+		eof := err != nil
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+
+		if line != "" {
+			field, value, _ := strings.Cut(line, ":")
+			value = strings.TrimPrefix(value, " ")
+			switch field {
+			case "":
+				// 注释, 常用作心跳
+			case "event":
+				event.Event = value
+			case "data":
+				data = append(data, value)
+			}
+		}
+		if line == "" || eof {
+			if len(data) > 0 {
+				event.Data = strings.Join(data, "\n")
+				return event, nil
+			}
+			if eof {
+				return sseEvent{}, io.EOF
+			}
+			// 没有数据的事件被忽略
+			event = sseEvent{}
+		}
+	}
+}
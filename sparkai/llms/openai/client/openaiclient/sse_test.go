@@ -0,0 +1,51 @@
+package openaiclient
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSSEReader(t *testing.T) {
+	t.Parallel()
+	stream := ": ping\r\n\r\n" +
+		"data: first\r\n\r\n" +
+		"event: error\n" +
+		"data: line 1\n" +
+		"data:line 2\n" +
+		"id: 3\n\n" +
+		"event: ignored\n\n" +
+		"data: last"
+	r := newSSEReader(strings.NewReader(stream))
+
+	var events []sseEvent
+	for {
+		event, err := r.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		events = append(events, event)
+	}
+	assert.Equal(t, []sseEvent{
+		{Data: "first"},
+		{Event: "error", Data: "line 1\nline 2"},
+		{Data: "last"},
+	}, events)
+}
+
+func TestSSEReaderLongLine(t *testing.T) {
+	t.Parallel()
+	long := strings.Repeat("x", 1<<20)
+	r := newSSEReader(strings.NewReader("data: " + long + "\n\n"))
+
+	event, err := r.next()
+	require.NoError(t, err)
+	assert.Equal(t, long, event.Data)
+	_, err = r.next()
+	assert.ErrorIs(t, err, io.EOF)
+}
@@ -42,8 +42,14 @@ func NewClient(opts ...Option) (*options, *openaiclient.Client, error) {
 		return options, nil, ErrMissingToken
 	}
 
+	var clientOptions []openaiclient.Option
+	if options.retryPolicy != nil {
+		clientOptions = append(clientOptions, openaiclient.WithRetryPolicy(*options.retryPolicy))
+	}
+
 	cli, err := openaiclient.New(options.token, options.model, options.baseURL, options.organization,
-		openaiclient.APIType(options.apiType), options.apiVersion, options.httpClient, options.embeddingModel)
+		openaiclient.APIType(options.apiType), options.apiVersion, options.httpClient, options.embeddingModel,
+		clientOptions...)
 	return options, cli, err
 }
 
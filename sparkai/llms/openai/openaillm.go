@@ -2,7 +2,8 @@ package openai
 
 import (
 	"context"
-	"fmt"
+
+	"github.com/iflytek/spark-ai-go/log"
 	"github.com/iflytek/spark-ai-go/sparkai/callbacks"
 	"github.com/iflytek/spark-ai-go/sparkai/llms"
 	"github.com/iflytek/spark-ai-go/sparkai/llms/openai/client/openaiclient"
@@ -14,6 +15,8 @@ type ChatMessage = openaiclient.ChatMessage
 type LLM struct {
 	CallbacksHandler callbacks.Handler
 	client           *openaiclient.Client
+	maxConcurrency   int
+	strictOptions    bool
 }
 
 const (
@@ -21,20 +24,33 @@ const (
 	RoleAssistant = "assistant"
 	RoleUser      = "user"
 	RoleFunction  = "function"
+	RoleTool      = "tool"
 )
 
 var _ llms.Model = (*LLM)(nil)
 
 // New returns a new OpenAI LLM.
 func New(opts ...Option) (*LLM, error) {
-	opt, c, err := NewClient(opts...)
+	llm := &LLM{}
+	opt, c, err := NewClient(append(opts[:len(opts):len(opts)], withRetryCallback(llm))...)
 	if err != nil {
 		return nil, err
 	}
-	return &LLM{
-		client:           c,
-		CallbacksHandler: opt.callbackHandler,
-	}, err
+	llm.client = c
+	llm.CallbacksHandler = opt.callbackHandler
+	llm.maxConcurrency = opt.maxConcurrency
+	llm.strictOptions = opt.strictOptions
+	return llm, err
+}
+
+// withRetryCallback reports the retries of the client to the LLM callbacks handler.
+func withRetryCallback(llm *LLM) Option {
+	return func(opts *options) {
+		if opts.retryPolicy != nil {
+			policy := callbacks.WithRetryHandler(*opts.retryPolicy, func() callbacks.Handler { return llm.CallbacksHandler })
+			opts.retryPolicy = &policy
+		}
+	}
 }
 
 // Call requests a completion for the given prompt.
@@ -43,8 +59,6 @@ func (o *LLM) Call(ctx context.Context, prompt string, options ...llms.CallOptio
 }
 
 // GenerateContent implements the Model interface.
-//
-//nolint:goerr113
 func (o *LLM) GenerateContent(ctx context.Context, msgs []messages.MessageContent, options ...llms.CallOption) (*messages.ContentResponse, error) { //nolint: lll, cyclop
 	if o.CallbacksHandler != nil {
 		o.CallbacksHandler.HandleLLMGenerateContentStart(ctx, msgs)
@@ -54,49 +68,20 @@ func (o *LLM) GenerateContent(ctx context.Context, msgs []messages.MessageConten
 	for _, opt := range options {
 		opt(&opts)
 	}
+	if err := o.checkOptions(opts); err != nil {
+		return nil, err
+	}
 
 	chatMsgs := make([]*ChatMessage, 0, len(msgs))
 	for _, mc := range msgs {
-		msg := &ChatMessage{MultiContent: mc.Parts}
-		switch mc.Role {
-		case messages.ChatMessageTypeSystem:
-			msg.Role = RoleSystem
-		case messages.ChatMessageTypeAI:
-			msg.Role = RoleAssistant
-		case messages.ChatMessageTypeHuman:
-			msg.Role = RoleUser
-		case messages.ChatMessageTypeGeneric:
-			msg.Role = RoleUser
-		case messages.ChatMessageTypeFunction:
-			fallthrough
-		default:
-			return nil, fmt.Errorf("role %v not supported", mc.Role)
+		msg, err := toContentMessage(mc)
+		if err != nil {
+			return nil, err
 		}
-
 		chatMsgs = append(chatMsgs, msg)
 	}
 
-	req := &openaiclient.ChatRequest{
-		Model:                opts.Model,
-		StopWords:            opts.StopWords,
-		Messages:             chatMsgs,
-		StreamingFunc:        opts.StreamingFunc,
-		Temperature:          opts.Temperature,
-		MaxTokens:            opts.MaxTokens,
-		N:                    opts.N,
-		FrequencyPenalty:     opts.FrequencyPenalty,
-		PresencePenalty:      opts.PresencePenalty,
-		FunctionCallBehavior: openaiclient.FunctionCallBehavior(opts.FunctionCallBehavior),
-	}
-
-	for _, fn := range opts.Functions {
-		req.Functions = append(req.Functions, openaiclient.FunctionDefinition{
-			Name:        fn.Name,
-			Description: fn.Description,
-			Parameters:  fn.Parameters,
-		})
-	}
-	result, err := o.client.CreateChat(ctx, req)
+	result, err := o.createChat(ctx, chatMsgs, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -110,21 +95,32 @@ func (o *LLM) GenerateContent(ctx context.Context, msgs []messages.MessageConten
 			Content:    c.Message.Content,
 			StopReason: c.FinishReason,
 			GenerationInfo: map[string]any{
-				"CompletionTokens": result.Usage.CompletionTokens,
-				"PromptTokens":     result.Usage.PromptTokens,
-				"TotalTokens":      result.Usage.TotalTokens,
+				messages.InfoCompletionTokens: result.Usage.CompletionTokens,
+				messages.InfoPromptTokens:     result.Usage.PromptTokens,
+				messages.InfoTotalTokens:      result.Usage.TotalTokens,
 			},
 		}
 
-		if c.FinishReason == "function_call" {
+		if c.Message.FunctionCall != nil {
 			choices[i].FuncCall = &messages.FunctionCall{
 				Name:      c.Message.FunctionCall.Name,
 				Arguments: c.Message.FunctionCall.Arguments,
 			}
 		}
+		choices[i].ToolCalls = fromToolCalls(c.Message.ToolCalls)
+		if len(choices[i].ToolCalls) > 0 && choices[i].FuncCall == nil {
+			choices[i].FuncCall = choices[i].ToolCalls[0].FunctionCall
+		}
 	}
 
-	response := &messages.ContentResponse{Choices: choices}
+	response := &messages.ContentResponse{
+		Choices: choices,
+		Usage: &messages.Usage{
+			PromptTokens:     int(result.Usage.PromptTokens),
+			CompletionTokens: int(result.Usage.CompletionTokens),
+			TotalTokens:      int(result.Usage.TotalTokens),
+		},
+	}
 
 	if o.CallbacksHandler != nil {
 		o.CallbacksHandler.HandleLLMGenerateContentEnd(ctx, response)
@@ -133,6 +129,105 @@ func (o *LLM) GenerateContent(ctx context.Context, msgs []messages.MessageConten
 	return response, nil
 }
 
+// createChat requests a chat completion for msgs.
+func (o *LLM) createChat(ctx context.Context, msgs []*ChatMessage, opts llms.CallOptions) (*openaiclient.ChatResponse, error) { //nolint: lll
+	req := &openaiclient.ChatRequest{
+		Model:                opts.Model,
+		StopWords:            opts.StopWords,
+		Messages:             msgs,
+		StreamingFunc:        opts.StreamingFunc,
+		Temperature:          opts.Temperature,
+		TopP:                 opts.TopP,
+		MaxTokens:            opts.MaxTokens,
+		N:                    opts.N,
+		FrequencyPenalty:     opts.FrequencyPenalty,
+		PresencePenalty:      opts.PresencePenalty,
+		Seed:                 opts.Seed,
+		User:                 opts.UserID,
+		FunctionCallBehavior: openaiclient.FunctionCallBehavior(opts.FunctionCallBehavior),
+	}
+
+	for _, fn := range opts.Functions {
+		req.Functions = append(req.Functions, openaiclient.FunctionDefinition{
+			Name:        fn.Name,
+			Description: fn.Description,
+			Parameters:  fn.Parameters,
+		})
+	}
+	for _, tool := range opts.Tools {
+		t := openaiclient.Tool{Type: tool.Type}
+		if tool.Function != nil {
+			t.Function = openaiclient.FunctionDefinition{
+				Name:        tool.Function.Name,
+				Description: tool.Function.Description,
+				Parameters:  tool.Function.Parameters,
+			}
+		}
+		req.Tools = append(req.Tools, t)
+	}
+	if f := opts.ResponseFormat; f != nil {
+		req.ResponseFormat = &openaiclient.ResponseFormat{Type: f.Type}
+		if f.JSONSchema != nil {
+			req.ResponseFormat.JSONSchema = &openaiclient.ResponseFormatJSONSchema{
+				Name:        f.JSONSchema.Name,
+				Description: f.JSONSchema.Description,
+				Schema:      f.JSONSchema.Schema,
+				Strict:      f.JSONSchema.Strict,
+			}
+		}
+	}
+	switch choice := opts.ToolChoice.(type) {
+	case messages.ToolChoice:
+		req.ToolChoice = toToolChoice(choice)
+	case *messages.ToolChoice:
+		req.ToolChoice = toToolChoice(*choice)
+	default:
+		req.ToolChoice = choice
+	}
+	return o.client.CreateChat(ctx, req)
+}
+
+func toToolChoice(choice messages.ToolChoice) openaiclient.ToolChoice {
+	c := openaiclient.ToolChoice{Type: choice.Type}
+	if choice.Function != nil {
+		c.Function.Name = choice.Function.Name
+	}
+	return c
+}
+
+// checkOptions reports the options OpenAI does not support, as an error in
+// strict mode and as a warning otherwise.
+func (o *LLM) checkOptions(opts llms.CallOptions) error {
+	err := llms.CheckOptions("openai", opts, o.SupportedOptions())
+	if err == nil || o.strictOptions {
+		return err
+	}
+	log.Logger.Warn(err.Error())
+	return nil
+}
+
+// SupportedOptions implements llms.OptionSupporter.
+func (o *LLM) SupportedOptions() []llms.OptionName {
+	return []llms.OptionName{
+		llms.OptionModel,
+		llms.OptionMaxTokens,
+		llms.OptionTemperature,
+		llms.OptionStopWords,
+		llms.OptionStreamingFunc,
+		llms.OptionTopP,
+		llms.OptionSeed,
+		llms.OptionN,
+		llms.OptionFrequencyPenalty,
+		llms.OptionPresencePenalty,
+		llms.OptionFunctions,
+		llms.OptionFunctionCallBehavior,
+		llms.OptionTools,
+		llms.OptionToolChoice,
+		llms.OptionUserID,
+		llms.OptionResponseFormat,
+	}
+}
+
 // CreateEmbedding creates embeddings for the given input texts.
 func (o *LLM) CreateEmbedding(ctx context.Context, inputTexts []string) ([][]float32, error) {
 	embeddings, err := o.client.CreateEmbedding(ctx, &openaiclient.EmbeddingRequest{
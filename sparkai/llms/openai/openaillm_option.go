@@ -3,6 +3,7 @@ package openai
 import (
 	"github.com/iflytek/spark-ai-go/sparkai/callbacks"
 	"github.com/iflytek/spark-ai-go/sparkai/llms/openai/client/openaiclient"
+	"github.com/iflytek/spark-ai-go/sparkai/retry"
 )
 
 const (
@@ -38,6 +39,9 @@ type options struct {
 	embeddingModel string
 
 	callbackHandler callbacks.Handler
+	retryPolicy     *retry.Policy
+	maxConcurrency  int
+	strictOptions   bool
 }
 
 type Option func(*options)
@@ -112,3 +116,29 @@ func WithCallback(callbackHandler callbacks.Handler) Option {
 		opts.callbackHandler = callbackHandler
 	}
 }
+
+// WithRetryPolicy sets the policy used to retry failed requests, see
+// retry.DefaultPolicy. Retries are reported to the callback handler when it
+// implements callbacks.RetryHandler.
+func WithRetryPolicy(policy retry.Policy) Option {
+	return func(opts *options) {
+		opts.retryPolicy = &policy
+	}
+}
+
+// WithMaxConcurrency bounds the number of conversations Chat.Generate runs in
+// parallel, llms.DefaultMaxConcurrency when not set.
+func WithMaxConcurrency(n int) Option {
+	return func(opts *options) {
+		opts.maxConcurrency = n
+	}
+}
+
+// WithStrictOptions makes the calls fail with llms.ErrUnsupportedOption when
+// they set call options OpenAI does not support, such as TopK. By default
+// these options are ignored and logged.
+func WithStrictOptions(strict bool) Option {
+	return func(opts *options) {
+		opts.strictOptions = strict
+	}
+}
@@ -0,0 +1,102 @@
+package openai
+
+import (
+	"fmt"
+
+	"github.com/iflytek/spark-ai-go/sparkai/llms/openai/client/openaiclient"
+	"github.com/iflytek/spark-ai-go/sparkai/messages"
+)
+
+// toContentMessage converts a MessageContent to the OpenAI format. The tool
+// calls are parts of the AI messages, the tool and function messages hold a
+// ToolCallResponse or text parts.
+//
+//nolint:goerr113
+func toContentMessage(mc messages.MessageContent) (*ChatMessage, error) {
+	msg := &ChatMessage{}
+	switch mc.Role {
+	case messages.ChatMessageTypeSystem:
+		msg.Role = RoleSystem
+	case messages.ChatMessageTypeAI:
+		msg.Role = RoleAssistant
+	case messages.ChatMessageTypeHuman, messages.ChatMessageTypeGeneric:
+		msg.Role = RoleUser
+	case messages.ChatMessageTypeFunction:
+		msg.Role = RoleFunction
+	case messages.ChatMessageTypeTool:
+		msg.Role = RoleTool
+	default:
+		return nil, fmt.Errorf("role %v not supported", mc.Role)
+	}
+	result := msg.Role == RoleFunction || msg.Role == RoleTool
+
+	for _, part := range mc.Parts {
+		switch p := part.(type) {
+		case messages.ToolCall:
+			if msg.Role != RoleAssistant {
+				return nil, fmt.Errorf("tool calls not supported in %v messages", mc.Role)
+			}
+			msg.ToolCalls = append(msg.ToolCalls, toToolCalls([]messages.ToolCall{p})...)
+		case messages.ToolCallResponse:
+			if !result {
+				return nil, fmt.Errorf("tool call responses not supported in %v messages", mc.Role)
+			}
+			if msg.Role == RoleTool {
+				msg.ToolCallID = p.ToolCallID
+			} else {
+				msg.Name = p.Name
+			}
+			msg.Content += p.Content
+		case messages.TextContent:
+			if result {
+				msg.Content += p.Text
+			} else {
+				msg.MultiContent = append(msg.MultiContent, part)
+			}
+		default:
+			if result {
+				return nil, fmt.Errorf("content part %T not supported in %v messages", part, mc.Role)
+			}
+			msg.MultiContent = append(msg.MultiContent, part)
+		}
+	}
+	return msg, nil
+}
+
+// toToolCalls converts tool calls to the OpenAI format.
+func toToolCalls(calls []messages.ToolCall) []openaiclient.ToolCall {
+	if len(calls) == 0 {
+		return nil
+	}
+	toolCalls := make([]openaiclient.ToolCall, 0, len(calls))
+	for _, call := range calls {
+		tc := openaiclient.ToolCall{ID: call.ID, Type: call.Type}
+		if call.FunctionCall != nil {
+			tc.Function = openaiclient.FunctionCall{
+				Name:      call.FunctionCall.Name,
+				Arguments: call.FunctionCall.Arguments,
+			}
+		}
+		toolCalls = append(toolCalls, tc)
+	}
+	return toolCalls
+}
+
+// fromToolCalls converts the tool calls of a response.
+func fromToolCalls(toolCalls []openaiclient.ToolCall) []messages.ToolCall {
+	if len(toolCalls) == 0 {
+		return nil
+	}
+	calls := make([]messages.ToolCall, 0, len(toolCalls))
+	for _, tc := range toolCalls {
+		calls = append(calls, messages.ToolCall{
+			ID:   tc.ID,
+			Type: tc.Type,
+			FunctionCall: &messages.FunctionCall{
+				Name:      tc.Function.Name,
+				Arguments: tc.Function.Arguments,
+			},
+		})
+	}
+	return calls
+}
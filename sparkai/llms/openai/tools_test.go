@@ -0,0 +1,144 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/iflytek/spark-ai-go/sparkai/llms"
+	"github.com/iflytek/spark-ai-go/sparkai/messages"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const toolCallsResponse = `{"choices":[{"index":0,"finish_reason":"tool_calls","message":{"role":"assistant","content":null,` +
+	`"tool_calls":[` +
+	`{"id":"call_hf","type":"function","function":{"name":"get_weather","arguments":"{\"location\":\"Hefei\"}"}},` +
+	`{"id":"call_bj","type":"function","function":{"name":"get_weather","arguments":"{\"location\":\"Beijing\"}"}}]}}],` +
+	`"usage":{"prompt_tokens":5,"completion_tokens":9,"total_tokens":14}}`
+
+// newToolsServer starts a server answering with toolCallsResponse and
+// recording the last request in req.
+func newToolsServer(t *testing.T, req *map[string]any) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(req))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(toolCallsResponse))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+var weatherTool = messages.Tool{
+	Type: messages.ToolTypeFunction,
+	Function: &messages.FunctionDefinition{
+		Name:        "get_weather",
+		Description: "Get the weather of a city",
+		Parameters:  map[string]any{"type": "object"},
+	},
+}
+
+func TestGenerateContentTools(t *testing.T) {
+	t.Parallel()
+	var req map[string]any
+	srv := newToolsServer(t, &req)
+	llm, err := New(WithToken("token"), WithModel("gpt-4o"), WithBaseURL(srv.URL), WithStrictOptions(true))
+	require.NoError(t, err)
+
+	resp, err := llm.GenerateContent(context.Background(), []messages.MessageContent{
+		messages.TextParts(messages.ChatMessageTypeHuman, "Weather in Hefei and Beijing?"),
+		{Role: messages.ChatMessageTypeAI, Parts: []messages.ContentPart{
+			messages.ToolCall{ID: "call_1", Type: messages.ToolTypeFunction,
+				FunctionCall: &messages.FunctionCall{Name: "get_weather", Arguments: `{"location":"Hefei"}`}},
+		}},
+		{Role: messages.ChatMessageTypeTool, Parts: []messages.ContentPart{
+			messages.ToolCallResponse{ToolCallID: "call_1", Name: "get_weather", Content: "sunny"},
+		}},
+	},
+		llms.WithTools([]messages.Tool{weatherTool}),
+		llms.WithToolChoice(messages.ToolChoice{
+			Type:     messages.ToolTypeFunction,
+			Function: &messages.FunctionReference{Name: "get_weather"},
+		}))
+	require.NoError(t, err)
+
+	choice := resp.Choices[0]
+	assert.Equal(t, messages.FinishReasonToolCalls, choice.StopReason)
+	assert.Equal(t, []messages.ToolCall{
+		{ID: "call_hf", Type: "function", FunctionCall: &messages.FunctionCall{Name: "get_weather", Arguments: `{"location":"Hefei"}`}},
+		{ID: "call_bj", Type: "function", FunctionCall: &messages.FunctionCall{Name: "get_weather", Arguments: `{"location":"Beijing"}`}},
+	}, choice.ToolCalls)
+	assert.Equal(t, choice.ToolCalls[0].FunctionCall, choice.FuncCall)
+
+	got, err := json.Marshal(map[string]any{"messages": req["messages"], "tools": req["tools"], "tool_choice": req["tool_choice"]})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"messages":[
+			{"role":"user","content":[{"type":"text","text":"Weather in Hefei and Beijing?"}]},
+			{"role":"assistant","content":"","tool_calls":[
+				{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"location\":\"Hefei\"}"}}]},
+			{"role":"tool","content":"sunny","tool_call_id":"call_1"}
+		],
+		"tools":[{"type":"function","function":{"name":"get_weather","description":"Get the weather of a city","parameters":{"type":"object"}}}],
+		"tool_choice":{"type":"function","function":{"name":"get_weather"}}
+	}`, string(got))
+}
+
+func TestGenerateContentToolChoiceString(t *testing.T) {
+	t.Parallel()
+	var req map[string]any
+	srv := newToolsServer(t, &req)
+	llm, err := New(WithToken("token"), WithModel("gpt-4o"), WithBaseURL(srv.URL))
+	require.NoError(t, err)
+
+	_, err = llm.Call(context.Background(), "Weather in Hefei?",
+		llms.WithTools([]messages.Tool{weatherTool}), llms.WithToolChoice(messages.ToolChoiceRequired))
+	require.NoError(t, err)
+	assert.Equal(t, "required", req["tool_choice"])
+}
+
+func TestGenerateContentToolMessageErrors(t *testing.T) {
+	t.Parallel()
+	llm, err := New(WithToken("token"), WithModel("gpt-4o"), WithBaseURL("http://127.0.0.1:0"))
+	require.NoError(t, err)
+
+	_, err = llm.GenerateContent(context.Background(), []messages.MessageContent{
+		{Role: messages.ChatMessageTypeHuman, Parts: []messages.ContentPart{messages.ToolCall{ID: "call_1"}}},
+	})
+	require.EqualError(t, err, "tool calls not supported in human messages")
+
+	_, err = llm.GenerateContent(context.Background(), []messages.MessageContent{
+		{Role: messages.ChatMessageTypeTool, Parts: []messages.ContentPart{messages.ImageURLPart("https://example.com/a.png")}},
+	})
+	require.EqualError(t, err, "content part messages.ImageURLContent not supported in tool messages")
+}
+
+func TestChatCallTools(t *testing.T) {
+	t.Parallel()
+	var req map[string]any
+	srv := newToolsServer(t, &req)
+	chat, err := NewChat(WithToken("token"), WithModel("gpt-4o"), WithBaseURL(srv.URL))
+	require.NoError(t, err)
+
+	msg, err := chat.Call(context.Background(), []messages.ChatMessage{
+		messages.HumanChatMessage{Content: "Weather in Hefei?"},
+		messages.AIChatMessage{ToolCalls: []messages.ToolCall{{ID: "call_1", Type: messages.ToolTypeFunction,
+			FunctionCall: &messages.FunctionCall{Name: "get_weather", Arguments: `{}`}}}},
+		messages.ToolChatMessage{ID: "call_1", Content: "sunny"},
+	}, llms.WithTools([]messages.Tool{weatherTool}))
+	require.NoError(t, err)
+	require.Len(t, msg.ToolCalls, 2)
+	assert.Equal(t, "call_bj", msg.ToolCalls[1].ID)
+
+	got, err := json.Marshal(req["messages"])
+	require.NoError(t, err)
+	assert.JSONEq(t, `[
+		{"role":"user","content":"Weather in Hefei?"},
+		{"role":"assistant","content":"","tool_calls":[
+			{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{}"}}]},
+		{"role":"tool","content":"sunny","tool_call_id":"call_1"}
+	]`, string(got))
+}
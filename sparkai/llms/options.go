@@ -47,6 +47,35 @@ type CallOptions struct {
 	// If a specific function should be invoked, use the format:
 	// `{"name": "my_function"}`
 	FunctionCallBehavior messages.FunctionCallBehavior `json:"function_call"`
+
+	// Tools are the tools the model may call.
+	Tools []messages.Tool `json:"tools"`
+	// ToolChoice controls which tool is called: messages.ToolChoiceNone,
+	// messages.ToolChoiceAuto, messages.ToolChoiceRequired or a
+	// messages.ToolChoice naming the function.
+	ToolChoice any `json:"tool_choice"`
+
+	// UserID identifies the end user, sent as the Spark header uid.
+	UserID string `json:"user_id"`
+	// ChatID correlates the requests of a conversation, sent as the Spark chat_id.
+	ChatID string `json:"chat_id"`
+	// Auditing is the content audit strategy: default, strict or moderate.
+	Auditing string `json:"auditing"`
+	// WebSearch configures the web search tool of the providers supporting it.
+	WebSearch *WebSearch `json:"web_search,omitempty"`
+
+	// ResponseFormat asks for a JSON answer, see GenerateJSON.
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
+}
+
+// WebSearch configures the web search tool.
+type WebSearch struct {
+	// Enable turns the web search on or off.
+	Enable bool `json:"enable"`
+	// ShowRefLabel asks for the reference labels of the search results.
+	ShowRefLabel bool `json:"show_ref_label"`
+	// SearchMode is the search depth, e.g. normal or deep.
+	SearchMode string `json:"search_mode,omitempty"`
 }
 
 // WithModel is an option for LLM.Call.
@@ -167,3 +196,61 @@ func WithFunctions(functions []messages.FunctionDefinition) CallOption {
 		o.Functions = functions
 	}
 }
+
+// WithTools will add an option to set the tools the model may call.
+func WithTools(tools []messages.Tool) CallOption {
+	return func(o *CallOptions) {
+		o.Tools = tools
+	}
+}
+
+// WithToolChoice will add an option to set which tool is called, see
+// CallOptions.ToolChoice.
+func WithToolChoice(choice any) CallOption {
+	return func(o *CallOptions) {
+		o.ToolChoice = choice
+	}
+}
+
+// WithResponseFormat will add an option to set the format of the answer.
+func WithResponseFormat(format ResponseFormat) CallOption {
+	return func(o *CallOptions) {
+		o.ResponseFormat = &format
+	}
+}
+
+// WithJSONSchema will add an option to ask for a JSON answer matching schema.
+func WithJSONSchema(name string, schema *Schema) CallOption {
+	return WithResponseFormat(ResponseFormat{
+		Type:       ResponseFormatJSONSchema,
+		JSONSchema: &JSONSchema{Name: name, Schema: schema},
+	})
+}
+
+// WithUserID will add an option to set the end user identifier.
+func WithUserID(userID string) CallOption {
+	return func(o *CallOptions) {
+		o.UserID = userID
+	}
+}
+
+// WithChatID will add an option to set the conversation identifier.
+func WithChatID(chatID string) CallOption {
+	return func(o *CallOptions) {
+		o.ChatID = chatID
+	}
+}
+
+// WithAuditing will add an option to set the content audit strategy.
+func WithAuditing(auditing string) CallOption {
+	return func(o *CallOptions) {
+		o.Auditing = auditing
+	}
+}
+
+// WithWebSearch will add an option to configure the web search tool.
+func WithWebSearch(webSearch WebSearch) CallOption {
+	return func(o *CallOptions) {
+		o.WebSearch = &webSearch
+	}
+}
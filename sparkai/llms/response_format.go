@@ -0,0 +1,157 @@
+package llms
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/iflytek/spark-ai-go/sparkai/messages"
+)
+
+// Response format types, see ResponseFormat.
+const (
+	ResponseFormatText       = "text"
+	ResponseFormatJSONObject = "json_object"
+	ResponseFormatJSONSchema = "json_schema"
+)
+
+// ResponseFormat asks the model for a JSON answer. OpenAI receives it as
+// response_format, Spark as a system instruction.
+type ResponseFormat struct {
+	// Type is ResponseFormatText, ResponseFormatJSONObject or
+	// ResponseFormatJSONSchema.
+	Type string `json:"type"`
+	// JSONSchema is the schema of the answer, for ResponseFormatJSONSchema.
+	JSONSchema *JSONSchema `json:"json_schema,omitempty"`
+}
+
+// JSONSchema is a named schema.
+type JSONSchema struct {
+	// Name identifies the schema, it may contain a-z, A-Z, 0-9, _ and -.
+	Name        string  `json:"name"`
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+	// Strict asks OpenAI to follow the schema exactly, the schema must then
+	// require every property and disallow additional properties.
+	Strict bool `json:"strict,omitempty"`
+}
+
+// IsJSON reports whether the format asks for a JSON answer.
+func (f *ResponseFormat) IsJSON() bool {
+	return f != nil && (f.Type == ResponseFormatJSONObject || f.Type == ResponseFormatJSONSchema)
+}
+
+// schema returns the schema of the format, nil when there is none.
+func (f *ResponseFormat) schema() *Schema {
+	if f == nil || f.JSONSchema == nil {
+		return nil
+	}
+	return f.JSONSchema.Schema
+}
+
+// ErrInvalidJSONResponse is wrapped by the errors of ParseJSON.
+var ErrInvalidJSONResponse = errors.New("invalid JSON response")
+
+// ValidationError is returned when an answer is not valid JSON or does not
+// match the schema of the response format.
+type ValidationError struct {
+	// Content is the answer of the model.
+	Content string
+	// Err describes the problem.
+	Err error
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrInvalidJSONResponse, e.Err)
+}
+
+func (e *ValidationError) Unwrap() []error {
+	return []error{ErrInvalidJSONResponse, e.Err}
+}
+
+// ExtractJSON returns the JSON document of an answer, models often wrap it
+// in a Markdown code block or in a sentence. The content is returned trimmed
+// when no JSON document is found.
+func ExtractJSON(content string) string {
+	s := strings.TrimSpace(content)
+	if json.Valid([]byte(s)) {
+		return s
+	}
+	if _, block, ok := strings.Cut(s, "```"); ok {
+		// 跳过代码块的语言标记
+		if _, rest, ok := strings.Cut(block, "\n"); ok {
+			block = rest
+		}
+		block, _, _ = strings.Cut(block, "```")
+		if block = strings.TrimSpace(block); json.Valid([]byte(block)) {
+			return block
+		}
+	}
+	start, end := strings.IndexAny(s, "{["), strings.LastIndexAny(s, "}]")
+	if start >= 0 && end > start && json.Valid([]byte(s[start:end+1])) {
+		return s[start : end+1]
+	}
+	return s
+}
+
+// ParseJSON extracts the JSON document of content, validates it against the
+// schema of format, if any, and decodes it into v. The errors are
+// ValidationErrors, but for the decoding into v.
+func ParseJSON(content string, format *ResponseFormat, v any) error {
+	doc := ExtractJSON(content)
+	var value any
+	if err := json.Unmarshal([]byte(doc), &value); err != nil {
+		return &ValidationError{Content: content, Err: err}
+	}
+	if err := format.schema().Validate(value); err != nil {
+		return &ValidationError{Content: content, Err: err}
+	}
+	if err := json.Unmarshal([]byte(doc), v); err != nil {
+		return fmt.Errorf("decode JSON response: %w", err)
+	}
+	return nil
+}
+
+// retryPrompt asks the model to fix an invalid answer.
+const retryPrompt = "你上一次的回答不符合要求: %s\n请修正后重新回答, 只输出 JSON."
+
+// GenerateJSON asks the model for a JSON answer and decodes it into a T. The
+// response format is set with WithResponseFormat or WithJSONSchema, it
+// defaults to a JSON object. An invalid answer is retried up to maxRetries
+// times, the validation error being fed back to the model.
+func GenerateJSON[T any](ctx context.Context, model Model, msgs []messages.MessageContent, maxRetries int, options ...CallOption) (T, error) { //nolint:lll
+	var result T
+	opts := CallOptions{}
+	for _, opt := range options {
+		opt(&opts)
+	}
+	if !opts.ResponseFormat.IsJSON() {
+		// 复制后再追加, 不写入调用方切片的底层数组
+		options = append(append([]CallOption(nil), options...),
+			WithResponseFormat(ResponseFormat{Type: ResponseFormatJSONObject}))
+		opts.ResponseFormat = &ResponseFormat{Type: ResponseFormatJSONObject}
+	}
+
+	msgs = append([]messages.MessageContent(nil), msgs...)
+	for attempt := 0; ; attempt++ {
+		resp, err := model.GenerateContent(ctx, msgs, options...)
+		if err != nil {
+			return result, err
+		}
+		if len(resp.Choices) == 0 {
+			return result, errors.New("empty response from model") //nolint:goerr113
+		}
+		content := resp.Choices[0].Content
+		err = ParseJSON(content, opts.ResponseFormat, &result)
+		var validationErr *ValidationError
+		if err == nil || !errors.As(err, &validationErr) || attempt >= maxRetries {
+			return result, err
+		}
+		msgs = append(msgs,
+			messages.TextParts(messages.ChatMessageTypeAI, content),
+			messages.TextParts(messages.ChatMessageTypeHuman, fmt.Sprintf(retryPrompt, validationErr.Err)),
+		)
+	}
+}
@@ -0,0 +1,122 @@
+package llms
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/iflytek/spark-ai-go/sparkai/messages"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeModel answers with the given contents in turn and records the calls.
+type fakeModel struct {
+	answers []string
+	calls   [][]messages.MessageContent
+	options []CallOptions
+}
+
+func (m *fakeModel) GenerateContent(_ context.Context, msgs []messages.MessageContent, options ...CallOption) (*messages.ContentResponse, error) { //nolint:lll
+	opts := CallOptions{}
+	for _, opt := range options {
+		opt(&opts)
+	}
+	m.calls = append(m.calls, msgs)
+	m.options = append(m.options, opts)
+	answer := m.answers[min(len(m.calls), len(m.answers))-1]
+	return &messages.ContentResponse{Choices: []*messages.ContentChoice{{Content: answer}}}, nil
+}
+
+func TestExtractJSON(t *testing.T) {
+	t.Parallel()
+	tests := map[string]string{
+		` {"a":1} `:               `{"a":1}`,
+		"```json\n{\"a\":1}\n```": `{"a":1}`,
+		"结果如下:\n```\n[1,2]\n```\n以上.": `[1,2]`,
+		`答案是 {"a":{"b":2}}, 请查收`:      `{"a":{"b":2}}`,
+		"not json":                    "not json",
+		"```json\n{\"a\":\n```":       "```json\n{\"a\":\n```",
+	}
+	for content, want := range tests {
+		assert.Equal(t, want, ExtractJSON(content), content)
+	}
+}
+
+type weather struct {
+	City string `json:"city"`
+	Days int    `json:"days"`
+}
+
+var weatherSchema = &Schema{
+	Type: "object",
+	Properties: map[string]*Schema{
+		"city": {Type: "string"},
+		"days": {Type: "integer"},
+	},
+	Required: []string{"city", "days"},
+}
+
+func TestGenerateJSON(t *testing.T) {
+	t.Parallel()
+	model := &fakeModel{answers: []string{"```json\n{\"city\":\"合肥\",\"days\":3}\n```"}}
+
+	got, err := GenerateJSON[weather](context.Background(), model,
+		[]messages.MessageContent{messages.TextParts(messages.ChatMessageTypeHuman, "合肥未来三天天气")}, 0,
+		WithJSONSchema("weather", weatherSchema))
+	require.NoError(t, err)
+	assert.Equal(t, weather{City: "合肥", Days: 3}, got)
+	assert.Equal(t, ResponseFormatJSONSchema, model.options[0].ResponseFormat.Type)
+	assert.Equal(t, "weather", model.options[0].ResponseFormat.JSONSchema.Name)
+}
+
+func TestGenerateJSONRetry(t *testing.T) {
+	t.Parallel()
+	model := &fakeModel{answers: []string{`{"city":"合肥"}`, `{"city":"合肥","days":3}`}}
+	msgs := []messages.MessageContent{messages.TextParts(messages.ChatMessageTypeHuman, "合肥未来三天天气")}
+
+	got, err := GenerateJSON[weather](context.Background(), model, msgs, 1, WithJSONSchema("weather", weatherSchema))
+	require.NoError(t, err)
+	assert.Equal(t, weather{City: "合肥", Days: 3}, got)
+	require.Len(t, model.calls, 2)
+	require.Len(t, model.calls[1], 3)
+	assert.Equal(t, messages.TextParts(messages.ChatMessageTypeAI, `{"city":"合肥"}`), model.calls[1][1])
+	assert.Contains(t, model.calls[1][2].Parts[0].(messages.TextContent).Text, "$.days: required")
+	assert.Len(t, msgs, 1, "the messages of the caller are not modified")
+}
+
+func TestGenerateJSONInvalid(t *testing.T) {
+	t.Parallel()
+	model := &fakeModel{answers: []string{"抱歉, 我无法回答"}}
+
+	_, err := GenerateJSON[map[string]any](context.Background(), model,
+		[]messages.MessageContent{messages.TextParts(messages.ChatMessageTypeHuman, "hi")}, 2)
+	require.ErrorIs(t, err, ErrInvalidJSONResponse)
+	var validationErr *ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, "抱歉, 我无法回答", validationErr.Content)
+	assert.Len(t, model.calls, 3)
+	assert.Equal(t, ResponseFormatJSONObject, model.options[0].ResponseFormat.Type)
+}
+
+func TestGenerateJSONSharedOptions(t *testing.T) {
+	t.Parallel()
+	// 调用方的选项切片有剩余容量, 并发调用不能写入其底层数组
+	shared := make([]CallOption, 1, 4)
+	shared[0] = WithTemperature(0.5)
+	msgs := []messages.MessageContent{messages.TextParts(messages.ChatMessageTypeHuman, "hi")}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			model := &fakeModel{answers: []string{`{"a":1}`}}
+			_, err := GenerateJSON[map[string]any](context.Background(), model, msgs, 0, shared...)
+			assert.NoError(t, err)
+			assert.Equal(t, ResponseFormatJSONObject, model.options[0].ResponseFormat.Type)
+		}()
+	}
+	wg.Wait()
+	assert.Nil(t, shared[:2][1])
+}
@@ -0,0 +1,136 @@
+package llms
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Schema is the subset of JSON Schema used to describe structured output:
+// types, object properties, required properties, array items and enums.
+type Schema struct {
+	// Type is object, array, string, number, integer, boolean or null.
+	Type        string             `json:"type,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	// AdditionalProperties rejects the properties missing from Properties
+	// when set to false.
+	AdditionalProperties *bool   `json:"additionalProperties,omitempty"`
+	Items                *Schema `json:"items,omitempty"`
+	Enum                 []any   `json:"enum,omitempty"`
+}
+
+// Validate checks v, a value decoded by encoding/json into an any, against
+// the schema. The error lists every problem found with its JSON path.
+func (s *Schema) Validate(v any) error {
+	var problems []string
+	s.validate("$", v, &problems)
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s", strings.Join(problems, "; ")) //nolint:goerr113
+}
+
+func (s *Schema) validate(path string, v any, problems *[]string) {
+	if s == nil {
+		return
+	}
+	if s.Type != "" && !hasType(v, s.Type) {
+		*problems = append(*problems, fmt.Sprintf("%s: expected %s, got %s", path, s.Type, typeOf(v)))
+		return
+	}
+	if len(s.Enum) > 0 && !inEnum(v, s.Enum) {
+		*problems = append(*problems, fmt.Sprintf("%s: %v is not one of %v", path, v, s.Enum))
+	}
+
+	switch v := v.(type) {
+	case map[string]any:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				*problems = append(*problems, fmt.Sprintf("%s.%s: required", path, name))
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			prop, ok := s.Properties[name]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					*problems = append(*problems, fmt.Sprintf("%s.%s: unexpected property", path, name))
+				}
+				continue
+			}
+			prop.validate(path+"."+name, v[name], problems)
+		}
+	case []any:
+		for i, item := range v {
+			s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item, problems)
+		}
+	}
+}
+
+func hasType(v any, typ string) bool {
+	switch typ {
+	case "integer":
+		f, ok := v.(float64)
+		return ok && f == math.Trunc(f)
+	default:
+		return typeOf(v) == typ
+	}
+}
+
+// typeOf returns the JSON type of a value decoded by encoding/json.
+func typeOf(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+func inEnum(v any, enum []any) bool {
+	for _, e := range enum {
+		// 枚举值可能是 Go 的整数, 与解码得到的 float64 比较
+		if n, ok := toFloat(e); ok {
+			if f, ok := v.(float64); ok && f == n {
+				return true
+			}
+			continue
+		}
+		if reflect.DeepEqual(v, e) {
+			return true
+		}
+	}
+	return false
+}
+
+func toFloat(v any) (float64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() { //nolint:exhaustive
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	default:
+		return 0, false
+	}
+}
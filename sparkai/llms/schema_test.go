@@ -0,0 +1,56 @@
+package llms
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchemaValidate(t *testing.T) {
+	t.Parallel()
+	closed := false
+	schema := &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"city":  {Type: "string"},
+			"days":  {Type: "integer"},
+			"level": {Type: "string", Enum: []any{"low", "high"}},
+			"tags":  {Type: "array", Items: &Schema{Type: "string"}},
+		},
+		Required:             []string{"city", "days"},
+		AdditionalProperties: &closed,
+	}
+	tests := []struct {
+		doc  string
+		want string
+	}{
+		{`{"city":"合肥","days":3,"level":"low","tags":["a"]}`, ""},
+		{`{"city":"合肥"}`, "$.days: required"},
+		{`{"city":1,"days":1.5}`, "$.city: expected string, got number; $.days: expected integer, got number"},
+		{`{"city":"合肥","days":3,"level":"medium"}`, "$.level: medium is not one of [low high]"},
+		{`{"city":"合肥","days":3,"tags":["a",2]}`, "$.tags[1]: expected string, got number"},
+		{`{"city":"合肥","days":3,"extra":true}`, "$.extra: unexpected property"},
+		{`[]`, "$: expected object, got array"},
+	}
+	for _, tt := range tests {
+		var v any
+		require.NoError(t, json.Unmarshal([]byte(tt.doc), &v))
+		err := schema.Validate(v)
+		if tt.want == "" {
+			assert.NoError(t, err, tt.doc)
+		} else {
+			assert.EqualError(t, err, tt.want, tt.doc)
+		}
+	}
+}
+
+func TestSchemaValidateIntegerEnum(t *testing.T) {
+	t.Parallel()
+	schema := &Schema{Type: "integer", Enum: []any{1, 2}}
+	require.NoError(t, schema.Validate(float64(2)))
+	require.Error(t, schema.Validate(float64(3)))
+	var nilSchema *Schema
+	require.NoError(t, nilSchema.Validate("anything"))
+}
@@ -0,0 +1,101 @@
+package spark
+
+import (
+	"context"
+
+	"github.com/iflytek/spark-ai-go/sparkai/llms"
+	"github.com/iflytek/spark-ai-go/sparkai/messages"
+)
+
+// Chat is a Spark chat model. It implements llms.ChatLLM over the client of
+// an LLM, the multi-turn history is sent as is to Spark.
+type Chat struct {
+	llm *LLM
+}
+
+var _ llms.ChatLLM = (*Chat)(nil)
+
+// NewChat returns a new Spark chat model, it accepts the options of New.
+func NewChat(opts ...Option) (*Chat, error) {
+	llm, err := New(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Chat{llm: llm}, nil
+}
+
+// SupportedOptions implements llms.OptionSupporter. Unlike LLM, N is not
+// supported since a single message is returned per conversation.
+func (o *Chat) SupportedOptions() []llms.OptionName {
+	supported := make([]llms.OptionName, 0)
+	for _, name := range o.llm.SupportedOptions() {
+		if name != llms.OptionN {
+			supported = append(supported, name)
+		}
+	}
+	return supported
+}
+
+// Call requests a chat response for the given messages.
+func (o *Chat) Call(ctx context.Context, msgs []messages.ChatMessage, options ...llms.CallOption) (*messages.AIChatMessage, error) { //nolint: lll
+	r, err := o.Generate(ctx, [][]messages.ChatMessage{msgs}, options...)
+	if err != nil {
+		return nil, err
+	}
+	if len(r) == 0 {
+		return nil, ErrEmptyResponse
+	}
+	return r[0].Message, nil
+}
+
+// Generate requests a chat response for each conversation. Up to
+// WithMaxConcurrency conversations are generated in parallel.
+func (o *Chat) Generate(ctx context.Context, conversations [][]messages.ChatMessage, options ...llms.CallOption) ([]*llms.Generation, error) { //nolint: lll
+	handler := o.llm.CallbacksHandler
+	if handler != nil {
+		prompts := make([]string, 0, len(conversations))
+		for _, msgs := range conversations {
+			prompt, _ := messages.GetBufferString(msgs, "Human", "AI")
+			prompts = append(prompts, prompt)
+		}
+		handler.HandleLLMStart(ctx, prompts)
+	}
+
+	opts := llms.CallOptions{}
+	for _, opt := range options {
+		opt(&opts)
+	}
+	if err := o.llm.checkOptions(opts, o.SupportedOptions()); err != nil {
+		return nil, err
+	}
+
+	generations, err := llms.GenerateChats(ctx, conversations, o.llm.maxConcurrency,
+		func(ctx context.Context, msgs []messages.ChatMessage) (*llms.Generation, error) {
+			chatRes, err := o.llm.createChat(ctx, msgs, opts)
+			if err != nil {
+				return nil, err
+			}
+			usage := usageOf(chatRes)
+			return &llms.Generation{
+				Text: chatRes.GetContent(),
+				Message: &messages.AIChatMessage{
+					Content:      chatRes.GetContent(),
+					FunctionCall: chatRes.FunctionCall,
+					Usage:        usage,
+				},
+				GenerationInfo: generationInfo(chatRes),
+				StopReason:     chatRes.FinishReason,
+			}, nil
+		})
+	if err != nil {
+		if handler != nil {
+			handler.HandleLLMError(ctx, err)
+		}
+		return nil, err
+	}
+
+	if handler != nil {
+		handler.HandleLLMEnd(ctx, llms.NewLLMResult(generations))
+	}
+	return generations, nil
+}
@@ -0,0 +1,107 @@
+package spark
+
+import (
+	"context"
+	"sync"
+
+	"github.com/iflytek/spark-ai-go/sparkai/llms"
+	"github.com/iflytek/spark-ai-go/sparkai/llms/spark/client/sparkclient"
+	"github.com/iflytek/spark-ai-go/sparkai/messages"
+)
+
+// createChats generates opts.N choices for msgs. Spark has no n parameter,
+// so every choice is a session of its own, run in parallel; the first failure
+// cancels the other sessions. Only the first choice is streamed, the
+// temperatures set by WithChoiceTemperatures are applied in turn.
+func (o *LLM) createChats(ctx context.Context, msgs []messages.ChatMessage, opts llms.CallOptions) ([]*sparkclient.ChatResponse, error) { //nolint: lll
+	if opts.N <= 1 {
+		chatRes, err := o.createChat(ctx, msgs, opts)
+		if err != nil {
+			return nil, err
+		}
+		return []*sparkclient.ChatResponse{chatRes}, nil
+	}
+	n := opts.N
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make([]*sparkclient.ChatResponse, n)
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	for i := 0; i < n; i++ {
+		choiceOpts := opts
+		if i > 0 {
+			choiceOpts.StreamingFunc = nil
+		}
+		if len(o.choiceTemperatures) > 0 {
+			choiceOpts.Temperature = o.choiceTemperatures[i%len(o.choiceTemperatures)]
+		}
+		wg.Add(1)
+		go func(i int, opts llms.CallOptions) {
+			defer wg.Done()
+			chatRes, err := o.createChat(ctx, msgs, opts)
+			if err != nil {
+				errOnce.Do(func() {
+					firstErr = err
+					cancel()
+				})
+				return
+			}
+			results[i] = chatRes
+		}(i, choiceOpts)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return results, nil
+}
+
+// usageOf returns the token usage of a session.
+func usageOf(chatRes *sparkclient.ChatResponse) *messages.Usage {
+	return &messages.Usage{
+		PromptTokens:     int(chatRes.Usage.PromptTokens),
+		CompletionTokens: int(chatRes.Usage.CompletionTokens),
+		TotalTokens:      int(chatRes.Usage.TotalTokens),
+	}
+}
+
+// totalUsage returns the usage of all the sessions of a call.
+func totalUsage(results []*sparkclient.ChatResponse) *messages.Usage {
+	total := &messages.Usage{}
+	for _, chatRes := range results {
+		u := usageOf(chatRes)
+		total.PromptTokens += u.PromptTokens
+		total.CompletionTokens += u.CompletionTokens
+		total.TotalTokens += u.TotalTokens
+	}
+	return total
+}
+
+// toolCalls returns the function call of a response as a tool call, nil when
+// there is none. Spark has no call ids, the id is derived from the session id.
+func toolCalls(chatRes *sparkclient.ChatResponse) []messages.ToolCall {
+	if chatRes.FunctionCall == nil {
+		return nil
+	}
+	return []messages.ToolCall{{
+		ID:           "call_" + chatRes.Sid,
+		Type:         messages.ToolTypeFunction,
+		FunctionCall: chatRes.FunctionCall,
+	}}
+}
+
+// generationInfo returns the usage and the session of a response as
+// generation info, see messages.ParseGenerationInfo.
+func generationInfo(chatRes *sparkclient.ChatResponse) map[string]any {
+	usage := usageOf(chatRes)
+	return map[string]any{
+		messages.InfoCompletionTokens: usage.CompletionTokens,
+		messages.InfoPromptTokens:     usage.PromptTokens,
+		messages.InfoTotalTokens:      usage.TotalTokens,
+		messages.InfoSid:              chatRes.Sid,
+		messages.InfoStatus:           chatRes.Status,
+	}
+}
@@ -7,9 +7,10 @@ import (
 	"fmt"
 	"github.com/gorilla/websocket"
 	"github.com/iflytek/spark-ai-go/log"
+	"github.com/iflytek/spark-ai-go/sparkai/llms/spark/client/sparkclient/protocol"
 	"github.com/iflytek/spark-ai-go/sparkai/messages"
-	"io/ioutil"
-	"net/http"
+	"github.com/iflytek/spark-ai-go/sparkai/retry"
+	"net"
 	"time"
 )
 
@@ -18,19 +19,33 @@ var (
 	defaultTemperature float64 = float64(0.8)
 	defaultTopK                = int64(6)
 	defaultMaxTokens           = int64(2048)
+	defaultAuditing            = AuditingDefault
 )
 
 var ErrContentExclusive = errors.New("only one of Content / MultiContent allowed in message")
 
+// ErrReadTimeout is returned when no frame is received within the client read timeout.
+var ErrReadTimeout = errors.New("timed out waiting for the next frame")
+
+// closeGracePeriod bounds the write of the close frame sent when a session is aborted.
+const closeGracePeriod = time.Second
+
 // ChatRequest is a request to complete a chat completion..
+// See https://www.xfyun.cn/doc/spark/Web.html#_1-%E6%8E%A5%E5%8F%A3%E8%AF%B4%E6%98%8E for the parameter ranges.
 type ChatRequest struct {
 	Domain      *string                       `json:"domain"`
 	Messages    []messages.ChatMessage        `json:"messages"`
 	Temperature *float64                      `json:"temperature,omitempty"`
-	TopK        *int64                        `json:"top_p,omitempty"`
+	TopK        *int64                        `json:"top_k,omitempty"`
 	MaxTokens   *int64                        `json:"max_tokens,omitempty"`
-	Audit       *string                       `json:"audit,omitempty"`
+	Audit       *string                       `json:"auditing,omitempty"`
 	Functions   []messages.FunctionDefinition `json:"functions,omitempty"`
+	// Uid 用户 id, 放在 header 中, 最长 32 个字符
+	Uid *string `json:"uid,omitempty"`
+	// ChatId 会话 id, 用于关联用户会话
+	ChatId *string `json:"chat_id,omitempty"`
+	// WebSearch 联网搜索工具配置, 仅部分版本支持
+	WebSearch *WebSearch `json:"web_search,omitempty"`
 
 	//// Function definitions to include in the request.
 	//// FunctionCallBehavior is the behavior to use when calling functions.
@@ -44,6 +59,22 @@ type ChatRequest struct {
 	//StreamingFunc func(ctx context.Context, chunk []byte) error `json:"-"`
 }
 
+// Auditing strategies, see ChatRequest.Audit.
+const (
+	AuditingDefault  = "default"
+	AuditingStrict   = "strict"
+	AuditingModerate = "moderate"
+)
+
+// Web search modes, see WebSearch.SearchMode.
+const (
+	SearchModeNormal = "normal"
+	SearchModeDeep   = "deep"
+)
+
+// WebSearch configures the Spark web search tool.
+type WebSearch = protocol.WebSearch
+
 // ChatMessage is a message in a chat request.
 type ChatMessage struct { //nolint:musttag
 	// The role of the author of this message. One of system, user, or assistant.
@@ -68,6 +99,11 @@ func (m ChatMessage) GetType() messages.ChatMessageType {
 func (m ChatMessage) GetContent() string {
 	return m.Content
 }
+
+func (m ChatMessage) GetFunctionCall() *messages.FunctionCall {
+	return m.FunctionCall
+}
+
 func (m ChatMessage) MarshalJSON() ([]byte, error) {
 	msg := struct {
 		Role         string                 `json:"role"`
@@ -116,6 +152,14 @@ type ChatResponse struct {
 	Role         string                 `json:"role"`
 	Content      string                 `json:"content,omitempty"`
 	FunctionCall *messages.FunctionCall `json:"function_call"`
+	// Sid is the session id, useful when reporting issues to iFlytek.
+	Sid string `json:"sid,omitempty"`
+	// Status is the status of the last frame received.
+	Status int `json:"status"`
+	// FinishReason is the normalized reason the generation stopped, see
+	// messages.FinishReasonStop. Spark does not report it, it is derived from
+	// the response and the request.
+	FinishReason string `json:"finish_reason,omitempty"`
 	Usage        struct {
 		CompletionTokens float64 `json:"completion_tokens,omitempty"`
 		PromptTokens     float64 `json:"prompt_tokens,omitempty"`
@@ -139,6 +183,10 @@ func (c *ChatResponse) UpdateContent(msg string) {
 	c.Content = msg
 }
 
+func (c *ChatResponse) GetFunctionCall() *messages.FunctionCall {
+	return c.FunctionCall
+}
+
 // StreamedChatResponsePayload is a chunk from the stream.
 type StreamedChatResponsePayload struct {
 	ID      string  `json:"id,omitempty"`
@@ -186,142 +234,339 @@ type FunctionCall struct {
 	Arguments string `json:"arguments"`
 }
 
-func (c *Client) createChat(ctx context.Context, payload *ChatRequest, cb func(msg messages.ChatMessage) error) (messages.ChatMessage, error) {
+func (c *Client) createChat(ctx context.Context, payload *ChatRequest, cb func(msg messages.ChatMessage) error) (*ChatResponse, error) {
+	return c.chat(ctx, payload, nil, func(event *ChatStreamEvent) error {
+		// 处理 cb, 回调返回错误时中断本次会话
+		if cb == nil {
+			return nil
+		}
+		if err := cb(event.message()); err != nil {
+			return fmt.Errorf("callback returned an error: %w", err)
+		}
+		return nil
+	})
+}
+
+// chat runs a chat session over sess, or over a new session when sess is nil.
+// Failed sessions are retried according to the client retry policy as long as
+// no frame has been delivered to onEvent.
+func (c *Client) chat(ctx context.Context, payload *ChatRequest, sess *chatSession, onEvent func(event *ChatStreamEvent) error) (*ChatResponse, error) {
+	var response *ChatResponse
+	delivered := false
+	read := func(s *chatSession) (*ChatResponse, error) {
+		defer s.Close()
+		resp, err := c.readChat(ctx, s.conn, func(event *ChatStreamEvent) error {
+			delivered = true
+			return onEvent(event)
+		})
+		if err != nil {
+			return nil, err
+		}
+		resp.FinishReason = finishReason(resp, payload)
+		s.usedTokens = int(resp.Usage.TotalTokens)
+		if c.connManager != nil {
+			c.connManager.observeSession(time.Since(s.sent))
+		}
+		return resp, nil
+	}
+	err := retry.Do(ctx, c.retryPolicy, func(attempt int) error {
+		s := sess
+		sess = nil
+		if s == nil {
+			var err error
+			if s, err = c.openChat(ctx, payload, true); err != nil {
+				return err
+			}
+		}
+		resp, err := read(s)
+		if err != nil && s.warm && !delivered && isStaleConnErr(ctx, err) {
+			// 预热连接可能在空闲期间已被服务端关闭, 换一条新连接重发请求
+			c.connManager.warmDiscarded.Add(1)
+			if s, err = c.openChat(ctx, payload, false); err != nil {
+				return err
+			}
+			resp, err = read(s)
+		}
+		if err != nil && delivered {
+			return retry.Permanent(err)
+		}
+		response = resp
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// finishReason derives the reason the generation stopped: a function call,
+// the max_tokens limit or the natural end of the answer.
+func finishReason(resp *ChatResponse, payload *ChatRequest) string {
+	switch {
+	case resp.FunctionCall != nil:
+		return messages.FinishReasonFunctionCall
+	case payload.MaxTokens != nil && *payload.MaxTokens > 0 && int64(resp.Usage.CompletionTokens) >= *payload.MaxTokens:
+		return messages.FinishReasonLength
+	default:
+		return messages.FinishReasonStop
+	}
+}
 
-	// Build request payload
+// isStaleConnErr reports whether err may be caused by a connection closed by
+// the server before the request was handled.
+func isStaleConnErr(ctx context.Context, err error) bool {
+	var apiErr *APIError
+	return ctx.Err() == nil && !errors.As(err, &apiErr) && !errors.Is(err, ErrReadTimeout)
+}
 
-	// Build request
+// openChat 获取 websocket 连接并发送请求帧. useWarm 为 true 时优先使用连接管理器中预热的连接.
+func (c *Client) openChat(ctx context.Context, payload *ChatRequest, useWarm bool) (*chatSession, error) {
 	if c.baseURL == "" {
 		return nil, errors.New("No API Url set")
 	}
-	d := websocket.Dialer{
-		HandshakeTimeout: 5 * time.Second,
+	req := c.constructSparkReq(c.appId, payload)
+	if c.debug {
+		if v, ok := c.protocolVersion(); ok {
+			if err := req.Validate(v); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	s := &chatSession{release: func() {}}
+	if c.limiter != nil {
+		done, err := c.limiter.Acquire(ctx)
+		if err != nil {
+			return nil, err
+		}
+		s.release = func() { done(s.usedTokens) }
+	}
+	if m := c.connManager; m != nil {
+		release, err := m.acquire(ctx, c.appId)
+		if err != nil {
+			s.release()
+			return nil, err
+		}
+		limiterRelease := s.release
+		s.release = func() {
+			release()
+			limiterRelease()
+		}
+		if useWarm {
+			s.conn = m.take(c.poolKey())
+			s.warm = s.conn != nil
+		}
+		if m.warmConns > 0 {
+			// 后台补充预热连接, 失败时下次会话再补充
+			go func() {
+				_ = m.fill(c.poolKey(), func() (*websocket.Conn, error) {
+					return c.dial(context.WithoutCancel(ctx))
+				})
+			}()
+		}
+	}
+
+	if !s.warm {
+		conn, err := c.dial(ctx)
+		if err != nil {
+			s.release()
+			return nil, err
+		}
+		s.conn = conn
+	}
+
+	err := c.writeRequest(ctx, s.conn, req)
+	if err != nil && s.warm && ctx.Err() == nil {
+		// 预热连接已失效, 重新建连
+		s.conn.Close()
+		c.connManager.warmDiscarded.Add(1)
+		s.warm = false
+		if s.conn, err = c.dial(ctx); err == nil {
+			err = c.writeRequest(ctx, s.conn, req)
+		}
+	}
+	if err != nil {
+		if s.conn != nil {
+			s.conn.Close()
+		}
+		s.release()
+		return nil, err
 	}
+	s.sent = time.Now()
+	return s, nil
+}
+
+// dial 握手建立 websocket 连接.
+func (c *Client) dial(ctx context.Context) (*websocket.Conn, error) {
+	d := c.websocketDialer()
 	ua_str := ""
-	user_agent := ctx.Value("user_agent")
-	if user_agent != nil {
-		ua_str = user_agent.(string)
+	if user_agent, ok := ctx.Value("user_agent").(string); ok {
+		ua_str = user_agent
+	}
+	authUrl, err := c.authURL()
+	if err != nil {
+		return nil, err
 	}
 	//握手并建立websocket 连接
-	conn, resp, err := d.Dial(c.assembleAuthUrl1(c.baseURL, c.apiKey, c.apiSecret), map[string][]string{"User-Agent": []string{fmt.Sprintf("SparkAISdk/golang %s", ua_str)}})
+	start := time.Now()
+	conn, resp, err := d.DialContext(ctx, authUrl, map[string][]string{"User-Agent": []string{fmt.Sprintf("SparkAISdk/golang %s", ua_str)}})
+	if c.connManager != nil {
+		c.connManager.observeDial(time.Since(start), err)
+	}
 	if err != nil {
-		return nil, errors.New(err.Error())
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if errors.Is(err, websocket.ErrBadHandshake) && resp != nil {
+			if c.connManager != nil {
+				// 签名可能因时间偏差被拒绝, 下次重新签名
+				c.connManager.invalidateURL(c.poolKey())
+			}
+			return nil, newHandshakeError(resp)
+		}
+		return nil, fmt.Errorf("dial spark: %w", err)
+	}
+	return conn, nil
+}
 
-	} else if resp.StatusCode != 101 {
-		panic(readResp(resp) + err.Error())
+// authURL returns the signed URL, cached by the connection manager if any.
+func (c *Client) authURL() (string, error) {
+	sign := func(now time.Time) (string, error) {
+		return c.assembleAuthUrl1(c.baseURL, c.apiKey, c.apiSecret, now)
 	}
+	if c.connManager == nil {
+		return sign(time.Now())
+	}
+	return c.connManager.signedURL(c.poolKey(), sign)
+}
+
+// writeRequest 发送请求帧.
+func (c *Client) writeRequest(ctx context.Context, conn *websocket.Conn, req *protocol.Request) error {
+	if err := conn.SetWriteDeadline(deadline(ctx, c.writeTimeout)); err != nil {
+		return err
+	}
+	if err := conn.WriteJSON(req); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+	return conn.SetWriteDeadline(time.Time{})
+}
+
+// deadline returns the earliest of now+timeout and the context deadline.
+// The zero time is returned when neither applies.
+func deadline(ctx context.Context, timeout time.Duration) time.Time {
+	var d time.Time
+	if timeout > 0 {
+		d = time.Now().Add(timeout)
+	}
+	if ctxDeadline, ok := ctx.Deadline(); ok && (d.IsZero() || ctxDeadline.Before(d)) {
+		d = ctxDeadline
+	}
+	return d
+}
 
-	go func() {
+// closeOnDone aborts the session once ctx is done: a close frame is sent to
+// the server and the connection is closed, which unblocks pending reads.
+// The returned func stops watching ctx.
+func closeOnDone(ctx context.Context, conn *websocket.Conn) func() bool {
+	return context.AfterFunc(ctx, func() {
+		msg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+		_ = conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(closeGracePeriod))
+		conn.Close()
+	})
+}
 
-		data := c.constructSparkReq(c.appId, payload)
-		conn.WriteJSON(data)
+// readChat 读取返回帧直到 status == 2, 每一帧都会以 ChatStreamEvent 的形式交给 onEvent,
+// onEvent 返回错误时中断读取. 返回聚合后的 ChatResponse.
+func (c *Client) readChat(ctx context.Context, conn *websocket.Conn, onEvent func(event *ChatStreamEvent) error) (*ChatResponse, error) {
+	response := &ChatResponse{}
 
-	}()
-	var answer = ""
-	var code int
-	// Parse response
-	var response messages.ChatMessage
+	stop := closeOnDone(ctx, conn)
+	defer stop()
 
 	//获取返回的数据
 	for {
+		if err := conn.SetReadDeadline(deadline(ctx, c.readTimeout)); err != nil {
+			return nil, err
+		}
 		_, msg, err := conn.ReadMessage()
 		if err != nil {
-			fmt.Println("read message error:", err)
-			break
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				if d, ok := ctx.Deadline(); ok && !time.Now().Before(d) {
+					return nil, context.DeadlineExceeded
+				}
+				return nil, ErrReadTimeout
+			}
+			return nil, fmt.Errorf("read message error: %w", err)
 		}
 
-		var sparkResp = messages.SparkResponse{}
-		err1 := json.Unmarshal(msg, &sparkResp)
-		if err1 != nil {
-			return nil, errors.New(err1.Error())
+		sparkResp, err := protocol.DecodeResponse(msg, c.debug)
+		if err != nil {
+			return nil, err
 		}
-		//fmt.Println(string(msg))
 		//解析数据
-		//header := data["header"].(map[string]interface{})
-		code = sparkResp.Header.Code
-
-		if code != 0 {
-			//fmt.Println(data["payload"])
-			return nil, errors.New(fmt.Sprintf("code != 0 %f", code))
-
+		header := sparkResp.Header
+		if header.Code != 0 {
+			return nil, &APIError{
+				Code:    header.Code,
+				Message: header.Message,
+				Sid:     header.Sid,
+				Raw:     msg,
+			}
 		}
-		payload := &sparkResp.Payload
-		//choices := payload["choices"].(map[string]interface{})
+		if sparkResp.Payload == nil {
+			return nil, &protocol.DecodeError{Frame: msg, Err: errors.New("missing payload")}
+		}
+		payload := sparkResp.Payload
 		choices := payload.Choices
-		status := choices.Status
-		text := choices.Text
-		content := text[0].Content
-		role := text[0].Role
-		fc := text[0].FunctionCall
-		if fc != nil {
-			response = messages.AIChatMessage{
-				Content:      fc.GetContent(),
-				FunctionCall: fc,
+		event := &ChatStreamEvent{
+			Seq:    choices.Seq,
+			Status: choices.Status,
+		}
+		if len(choices.Text) > 0 {
+			event.Role = choices.Text[0].Role
+			event.Content = choices.Text[0].Content
+			if fc := choices.Text[0].FunctionCall; fc != nil {
+				event.FunctionCall = &messages.FunctionCall{Name: fc.Name, Arguments: fc.Arguments}
 			}
-		} else {
-			response = messages.GenericChatMessage{
-				Content: content,
-				Role:    role,
+		}
+		if choices.Status == protocol.StatusLast && payload.Usage != nil {
+			usage := payload.Usage.Text
+			event.Usage = &ChatUsage{
+				PromptTokens:     usage.PromptTokens,
+				CompletionTokens: usage.CompletionTokens,
+				TotalTokens:      usage.TotalTokens,
 			}
 		}
-		// 处理 cb todo cb 规范化
-		if cb != nil {
 
-			err = cb(response)
-			if err != nil {
-				fmt.Println("callback error ")
+		response.Role = event.Role
+		response.Content += event.Content
+		response.Sid = header.Sid
+		response.Status = choices.Status
+		if event.FunctionCall != nil {
+			response.FunctionCall = event.FunctionCall
+		}
+
+		if onEvent != nil {
+			if err := onEvent(event); err != nil {
+				return nil, err
 			}
 		}
 
-		if status != 2 {
-			answer += content
-		} else {
-			answer += content
-			usage := payload.Usage
-			//totalTokens = temp["total_tokens"].(float64)
-			//fmt.Println("total_tokens:", totalTokens)
-			if fc == nil {
-				response = &ChatResponse{
-					Role:         role,
-					Content:      answer,
-					FunctionCall: fc,
-					Usage: struct {
-						CompletionTokens float64 `json:"completion_tokens,omitempty"`
-						PromptTokens     float64 `json:"prompt_tokens,omitempty"`
-						TotalTokens      float64 `json:"total_tokens,omitempty"`
-					}{
-						CompletionTokens: usage.CompletionTokens,
-						PromptTokens:     usage.PromptTokens,
-						TotalTokens:      usage.TotalTokens,
-					},
-				}
+		if choices.Status == protocol.StatusLast {
+			if event.Usage != nil {
+				response.Usage.CompletionTokens = float64(event.Usage.CompletionTokens)
+				response.Usage.PromptTokens = float64(event.Usage.PromptTokens)
+				response.Usage.TotalTokens = float64(event.Usage.TotalTokens)
 			}
 			log.GetLogger().Info("Sid: ", sparkResp.Header.Sid)
-
-			conn.Close()
-			break
+			return response, nil
 		}
-
-	}
-	if code != 0 {
-		msg := fmt.Sprintf("API returned unexpected status code: %f", code)
-
-		// No need to check the error here: if it fails, we'll just return the
-		// status code.
-
-		return nil, fmt.Errorf("%s", msg) // nolint:goerr113
-	}
-
-	return response, nil
-}
-
-func readResp(resp *http.Response) string {
-	if resp == nil {
-		return ""
-	}
-	b, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		panic(err)
 	}
-	return fmt.Sprintf("code=%d,body=%s", resp.StatusCode, string(b))
 }
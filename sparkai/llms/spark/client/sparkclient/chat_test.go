@@ -1 +1,433 @@
 package sparkclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/iflytek/spark-ai-go/sparkai/llms/spark/client/sparkclient/protocol"
+	"github.com/iflytek/spark-ai-go/sparkai/messages"
+	"github.com/iflytek/spark-ai-go/sparkai/retry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sparkFrame builds a Spark response frame.
+func sparkFrame(seq, status int, content string, extra string) string {
+	return fmt.Sprintf(`{"header":{"code":0,"message":"Success","sid":"cht000test","status":%d},`+
+		`"payload":{"choices":{"status":%d,"seq":%d,"text":[{"content":%q,"role":"assistant","index":0}]}%s}}`,
+		status, status, seq, content, extra)
+}
+
+const sparkUsage = `,"usage":{"text":{"question_tokens":4,"prompt_tokens":5,"completion_tokens":9,"total_tokens":14}}`
+
+// newFakeSparkServer starts a local websocket server which reads one request
+// frame and replies with the given frames.
+func newFakeSparkServer(t *testing.T, frames ...string) *httptest.Server {
+	t.Helper()
+	return newFakeSparkSessionsServer(t, frames)
+}
+
+// newFakeSparkSessionsServer starts a local websocket server replying to the
+// n-th connection with the n-th list of frames, the last list is reused for
+// extra connections.
+func newFakeSparkSessionsServer(t *testing.T, sessions ...[]string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(fakeSparkHandler(sessions...))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func fakeSparkHandler(sessions ...[]string) http.Handler {
+	upgrader := websocket.Upgrader{}
+	var mu sync.Mutex
+	n := 0
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		mu.Lock()
+		frames := sessions[min(n, len(sessions)-1)]
+		n++
+		mu.Unlock()
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+		for _, f := range frames {
+			if err := conn.WriteMessage(websocket.TextMessage, []byte(f)); err != nil {
+				return
+			}
+		}
+		// 等待客户端关闭连接
+		_, _, _ = conn.ReadMessage()
+	})
+}
+
+func newTestClient(t *testing.T, srv *httptest.Server, opts ...Option) *Client {
+	t.Helper()
+	c, err := New("generalv3.5", "key", "secret", "appid",
+		"ws"+strings.TrimPrefix(srv.URL, "http")+"/v3.5/chat", "", "", "", opts...)
+	require.NoError(t, err)
+	return c
+}
+
+func testChatRequest() *ChatRequest {
+	return &ChatRequest{
+		Messages: []messages.ChatMessage{
+			messages.GenericChatMessage{Role: "user", Content: "hello"},
+		},
+	}
+}
+
+func TestCreateChatStream(t *testing.T) {
+	t.Parallel()
+	srv := newFakeSparkServer(t,
+		sparkFrame(0, 0, "Hel", ""),
+		sparkFrame(1, 1, "lo", ""),
+		sparkFrame(2, 2, "!", sparkUsage),
+	)
+	c := newTestClient(t, srv)
+
+	stream, err := c.CreateChatStream(context.Background(), testChatRequest())
+	require.NoError(t, err)
+
+	var deltas []string
+	var last ChatStreamEvent
+	for {
+		event, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		deltas = append(deltas, event.Content)
+		last = event
+	}
+	assert.Equal(t, []string{"Hel", "lo", "!"}, deltas)
+	assert.True(t, last.IsLast())
+	assert.Equal(t, 2, last.Seq)
+	require.NotNil(t, last.Usage)
+	assert.Equal(t, 14, last.Usage.TotalTokens)
+
+	resp, err := stream.Response()
+	require.NoError(t, err)
+	assert.Equal(t, "Hello!", resp.Content)
+	assert.Equal(t, "assistant", resp.Role)
+	assert.InDelta(t, 9, resp.Usage.CompletionTokens, 0)
+}
+
+func TestCreateChatStreamCancel(t *testing.T) {
+	t.Parallel()
+	// 不发送最后一帧, 模拟长时间生成
+	srv := newFakeSparkServer(t, sparkFrame(0, 0, "Hel", ""))
+	c := newTestClient(t, srv)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := c.CreateChatStream(ctx, testChatRequest())
+	require.NoError(t, err)
+
+	event, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, "Hel", event.Content)
+
+	cancel()
+	_, err = stream.Recv()
+	require.ErrorIs(t, err, context.Canceled)
+	_, err = stream.Response()
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestCreateChatWithCallBackAbort(t *testing.T) {
+	t.Parallel()
+	srv := newFakeSparkServer(t,
+		sparkFrame(0, 0, "Hel", ""),
+		sparkFrame(1, 2, "lo", sparkUsage),
+	)
+	c := newTestClient(t, srv)
+
+	errStop := errors.New("stop")
+	calls := 0
+	_, err := c.CreateChatWithCallBack(context.Background(), testChatRequest(), func(msg messages.ChatMessage) error {
+		calls++
+		return errStop
+	})
+	require.ErrorIs(t, err, errStop)
+	assert.Equal(t, 1, calls)
+}
+
+func TestCreateChatReadTimeout(t *testing.T) {
+	t.Parallel()
+	srv := newFakeSparkServer(t, sparkFrame(0, 0, "Hel", ""))
+	c := newTestClient(t, srv, WithReadTimeout(50*time.Millisecond))
+
+	_, err := c.CreateChatWithCallBack(context.Background(), testChatRequest(), nil)
+	require.ErrorIs(t, err, ErrReadTimeout)
+}
+
+func TestCreateChatContextDeadline(t *testing.T) {
+	t.Parallel()
+	srv := newFakeSparkServer(t, sparkFrame(0, 0, "Hel", ""))
+	c := newTestClient(t, srv, WithReadTimeout(0))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := c.CreateChatWithCallBack(ctx, testChatRequest(), nil)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestCreateChatCancelSendsCloseFrame(t *testing.T) {
+	t.Parallel()
+	closeCode := make(chan int, 1)
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _, _ = conn.ReadMessage()
+		_ = conn.WriteMessage(websocket.TextMessage, []byte(sparkFrame(0, 0, "Hel", "")))
+		_, _, err = conn.ReadMessage()
+		var closeErr *websocket.CloseError
+		if errors.As(err, &closeErr) {
+			closeCode <- closeErr.Code
+		}
+		close(closeCode)
+	}))
+	defer srv.Close()
+	c := newTestClient(t, srv)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	_, err := c.CreateChatWithCallBack(ctx, testChatRequest(), func(msg messages.ChatMessage) error {
+		cancel()
+		return nil
+	})
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, websocket.CloseNormalClosure, <-closeCode)
+}
+
+func TestCreateChatAPIError(t *testing.T) {
+	t.Parallel()
+	frame := `{"header":{"code":11202,"message":"AppIdQpsOverFlowError","sid":"cht000bad","status":2}}`
+	srv := newFakeSparkServer(t, frame)
+	c := newTestClient(t, srv)
+
+	_, err := c.CreateChat(context.Background(), testChatRequest())
+	require.Error(t, err)
+
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, 11202, apiErr.Code)
+	assert.Equal(t, "AppIdQpsOverFlowError", apiErr.Message)
+	assert.Equal(t, "cht000bad", apiErr.Sid)
+	assert.JSONEq(t, frame, string(apiErr.Raw))
+	assert.Equal(t, ErrorCategoryRateLimit, apiErr.Category())
+	require.ErrorIs(t, err, ErrRateLimit)
+	assert.NotErrorIs(t, err, ErrAuth)
+}
+
+func TestCreateChatFinishReason(t *testing.T) {
+	t.Parallel()
+	functionCall := `,"function_call":{"name":"get_weather","arguments":"{}"}`
+	tests := []struct {
+		name      string
+		frame     string
+		maxTokens int64
+		want      string
+	}{
+		{"stop", sparkFrame(0, 2, "Hello", sparkUsage), 0, messages.FinishReasonStop},
+		{"below max tokens", sparkFrame(0, 2, "Hello", sparkUsage), 10, messages.FinishReasonStop},
+		{"length", sparkFrame(0, 2, "Hello", sparkUsage), 9, messages.FinishReasonLength},
+		{"function call", strings.Replace(sparkFrame(0, 2, "", sparkUsage), `"index":0`, `"index":0`+functionCall, 1),
+			0, messages.FinishReasonFunctionCall},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			c := newTestClient(t, newFakeSparkServer(t, tt.frame))
+			req := testChatRequest()
+			if tt.maxTokens > 0 {
+				req.MaxTokens = &tt.maxTokens
+			}
+			msg, err := c.CreateChat(context.Background(), req)
+			require.NoError(t, err)
+			resp, ok := msg.(*ChatResponse)
+			require.True(t, ok)
+			assert.Equal(t, tt.want, resp.FinishReason)
+			assert.Equal(t, "cht000test", resp.Sid)
+			assert.Equal(t, 2, resp.Status)
+		})
+	}
+}
+
+func TestCreateChatDebugStrictDecoding(t *testing.T) {
+	t.Parallel()
+	frame := sparkFrame(0, 2, "Hello", `,"plugins":{}`)
+	srv := newFakeSparkServer(t, frame)
+
+	resp, err := newTestClient(t, srv).CreateChat(context.Background(), testChatRequest())
+	require.NoError(t, err)
+	assert.Equal(t, "Hello", resp.GetContent())
+
+	_, err = newTestClient(t, srv, WithDebug(true)).CreateChat(context.Background(), testChatRequest())
+	var decodeErr *protocol.DecodeError
+	require.ErrorAs(t, err, &decodeErr)
+	assert.Contains(t, err.Error(), "plugins")
+}
+
+func TestCreateChatDebugValidation(t *testing.T) {
+	t.Parallel()
+	var dials atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		dials.Add(1)
+	}))
+	defer srv.Close()
+	c, err := New("general", "key", "secret", "appid",
+		"ws"+strings.TrimPrefix(srv.URL, "http")+"/v1.1/chat", "", "", "", WithDebug(true))
+	require.NoError(t, err)
+
+	req := testChatRequest()
+	req.Functions = []messages.FunctionDefinition{{Name: "get_weather"}}
+	_, err = c.CreateChat(context.Background(), req)
+	var unsupported *protocol.UnsupportedError
+	require.ErrorAs(t, err, &unsupported)
+	assert.Equal(t, protocol.V1_1, unsupported.Version)
+	assert.Equal(t, int32(0), dials.Load())
+}
+
+func TestCategoryOf(t *testing.T) {
+	t.Parallel()
+	cases := map[int]ErrorCategory{
+		10013: ErrorCategoryContentAudit,
+		10016: ErrorCategoryAuth,
+		10907: ErrorCategoryInvalidParams,
+		10012: ErrorCategoryServer,
+		11201: ErrorCategoryRateLimit,
+		99999: ErrorCategoryUnknown,
+	}
+	for code, category := range cases {
+		assert.Equal(t, category, CategoryOf(code), code)
+	}
+	assert.NotErrorIs(t, &APIError{Code: 99999}, ErrServer)
+}
+
+func TestCreateChatRetry(t *testing.T) {
+	t.Parallel()
+	rateLimited := `{"header":{"code":11202,"message":"AppIdQpsOverFlowError","sid":"cht000bad","status":2}}`
+	srv := newFakeSparkSessionsServer(t,
+		[]string{rateLimited},
+		[]string{sparkFrame(0, 2, "Hello", sparkUsage)},
+	)
+	var retries []int
+	c := newTestClient(t, srv, WithRetryPolicy(retry.Policy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		OnRetry: func(_ context.Context, attempt int, _ time.Duration, err error) {
+			assert.ErrorIs(t, err, ErrRateLimit)
+			retries = append(retries, attempt)
+		},
+	}))
+
+	resp, err := c.CreateChat(context.Background(), testChatRequest())
+	require.NoError(t, err)
+	assert.Equal(t, "Hello", resp.GetContent())
+	assert.Equal(t, []int{1}, retries)
+}
+
+func TestCreateChatNoRetryAfterDelivery(t *testing.T) {
+	t.Parallel()
+	serverErr := `{"header":{"code":10012,"message":"EngineInternalError","sid":"cht000bad","status":2}}`
+	srv := newFakeSparkSessionsServer(t,
+		[]string{sparkFrame(0, 0, "Hel", ""), serverErr},
+		[]string{sparkFrame(0, 2, "Hello", sparkUsage)},
+	)
+	c := newTestClient(t, srv, WithRetryPolicy(retry.Policy{MaxAttempts: 3, InitialBackoff: time.Millisecond}))
+
+	_, err := c.CreateChatWithCallBack(context.Background(), testChatRequest(), func(msg messages.ChatMessage) error {
+		return nil
+	})
+	require.ErrorIs(t, err, ErrServer)
+}
+
+func TestCreateChatHandshakeError(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name       string
+		status     int
+		body       string
+		message    string
+		target     error
+		retryable  bool
+		retryAfter string
+	}{
+		{
+			name:    "signature",
+			status:  http.StatusUnauthorized,
+			body:    `{"message":"HMAC signature does not match"}`,
+			message: "HMAC signature does not match",
+			target:  ErrAuth,
+		},
+		{
+			name:    "ip whitelist",
+			status:  http.StatusForbidden,
+			body:    `{"message":"IP address not allowed"}`,
+			message: "IP address not allowed",
+			target:  ErrAuth,
+		},
+		{
+			name:       "rate limit",
+			status:     http.StatusTooManyRequests,
+			body:       "Too Many Requests",
+			message:    "Too Many Requests",
+			target:     ErrRateLimit,
+			retryable:  true,
+			retryAfter: "2",
+		},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tc.retryAfter != "" {
+					w.Header().Set("Retry-After", tc.retryAfter)
+				}
+				w.WriteHeader(tc.status)
+				_, _ = io.WriteString(w, tc.body)
+			}))
+			defer srv.Close()
+			c := newTestClient(t, srv)
+
+			_, err := c.CreateChat(context.Background(), testChatRequest())
+			var hsErr *HandshakeError
+			require.ErrorAs(t, err, &hsErr)
+			assert.Equal(t, tc.status, hsErr.StatusCode)
+			assert.Equal(t, tc.message, hsErr.Message)
+			assert.Equal(t, tc.body, hsErr.Body)
+			require.ErrorIs(t, err, tc.target)
+			assert.Equal(t, tc.retryable, hsErr.Retryable())
+			if tc.retryAfter != "" {
+				assert.Equal(t, 2*time.Second, hsErr.RetryAfter())
+			}
+		})
+	}
+}
+
+func TestCreateChatInvalidURL(t *testing.T) {
+	t.Parallel()
+	c, err := New("generalv3.5", "key", "secret", "appid", "ws://[::1", "", "", "")
+	require.NoError(t, err)
+	_, err = c.CreateChat(context.Background(), testChatRequest())
+	require.Error(t, err)
+}
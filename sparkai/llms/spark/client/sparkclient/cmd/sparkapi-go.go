@@ -41,10 +41,8 @@ func main() {
 	//握手并建立websocket 连接
 	conn, resp, err := d.Dial(assembleAuthUrl1(hostUrl, apiKey, apiSecret), nil)
 	if err != nil {
-		panic(readResp(resp) + err.Error())
+		fmt.Println(readResp(resp) + err.Error())
 		return
-	} else if resp.StatusCode != 101 {
-		panic(readResp(resp) + err.Error())
 	}
 
 	go func() {
@@ -178,7 +176,7 @@ func readResp(resp *http.Response) string {
 	}
 	b, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		panic(err)
+		return fmt.Sprintf("code=%d,read body error=%s,", resp.StatusCode, err.Error())
 	}
 	return fmt.Sprintf("code=%d,body=%s", resp.StatusCode, string(b))
 }
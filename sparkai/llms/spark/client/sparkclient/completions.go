@@ -2,6 +2,8 @@ package sparkclient
 
 import (
 	"context"
+
+	"github.com/iflytek/spark-ai-go/sparkai/llms/spark/client/sparkclient/protocol"
 	"github.com/iflytek/spark-ai-go/sparkai/messages"
 )
 
@@ -13,6 +15,10 @@ type CompletionRequest struct {
 	N           int                           `json:"n,omitempty"`
 	TopK        int64                         `json:"top_k,omitempty"`
 	Functions   []messages.FunctionDefinition `json:"functions"`
+	Uid         string                        `json:"uid,omitempty"`
+	ChatId      string                        `json:"chat_id,omitempty"`
+	Audit       string                        `json:"auditing,omitempty"`
+	WebSearch   *WebSearch                    `json:"web_search,omitempty"`
 }
 
 type CompletionResponse struct {
@@ -42,9 +48,7 @@ type errorMessage struct {
 }
 
 // 生成参数
-func (c *Client) constructSparkReq(appid string, req *ChatRequest) map[string]interface{} { // 根据实际情况修改返回的数据结构和字段名
-
-	msgs := req.Messages
+func (c *Client) constructSparkReq(appid string, req *ChatRequest) *protocol.Request {
 	if req.Domain == nil || *req.Domain == "" {
 		req.Domain = &defaultDomain
 	}
@@ -57,29 +61,96 @@ func (c *Client) constructSparkReq(appid string, req *ChatRequest) map[string]in
 	if req.MaxTokens == nil || *req.MaxTokens == 0 {
 		req.MaxTokens = &defaultMaxTokens
 	}
-	data := map[string]interface{}{ // 根据实际情况修改返回的数据结构和字段名
-		"header": map[string]interface{}{ // 根据实际情况修改返回的数据结构和字段名
-			"app_id": appid, // 根据实际情况修改返回的数据结构和字段名
+	if req.Audit == nil || *req.Audit == "" {
+		req.Audit = &defaultAuditing
+	}
+	data := &protocol.Request{
+		Header: protocol.RequestHeader{
+			AppID: appid,
 		},
-		"parameter": map[string]interface{}{ // 根据实际情况修改返回的数据结构和字段名
-			"chat": map[string]interface{}{ // 根据实际情况修改返回的数据结构和字段名
-				"domain":      req.Domain,      // 根据实际情况修改返回的数据结构和字段名
-				"temperature": req.Temperature, // 根据实际情况修改返回的数据结构和字段名
-				"top_k":       req.TopK,        // 根据实际情况修改返回的数据结构和字段名
-				"max_tokens":  req.MaxTokens,   // 根据实际情况修改返回的数据结构和字段名
-				"auditing":    "default",       // 根据实际情况修改返回的数据结构和字段名
+		Parameter: protocol.Parameter{
+			Chat: protocol.ChatParameter{
+				Domain:      *req.Domain,
+				Temperature: *req.Temperature,
+				TopK:        *req.TopK,
+				MaxTokens:   *req.MaxTokens,
+				Auditing:    *req.Audit,
 			},
 		},
-		"payload": map[string]interface{}{ // 根据实际情况修改返回的数据结构和字段名
-			"message": map[string]interface{}{ // 根据实际情况修改返回的数据结构和字段名
-				"text": msgs, // 根据实际情况修改返回的数据结构和字段名
-			},
-			"functions": map[string][]messages.FunctionDefinition{
-				"text": req.Functions,
+		Payload: protocol.RequestPayload{
+			Message: protocol.MessagePayload{
+				Text: toProtocolMessages(req.Messages),
 			},
 		},
 	}
-	return data // 根据实际情况修改返回的数据结构和字段名
+	if req.Uid != nil {
+		data.Header.UID = *req.Uid
+	}
+	if req.ChatId != nil {
+		data.Parameter.Chat.ChatID = *req.ChatId
+	}
+	if req.WebSearch != nil {
+		data.Parameter.Chat.Tools = []protocol.Tool{
+			{Type: protocol.ToolWebSearch, WebSearch: req.WebSearch},
+		}
+	}
+	if len(req.Functions) > 0 {
+		functions := make([]protocol.Function, 0, len(req.Functions))
+		for _, fn := range req.Functions {
+			functions = append(functions, protocol.Function{
+				Name:        fn.Name,
+				Description: fn.Description,
+				Parameters:  fn.Parameters,
+			})
+		}
+		data.Payload.Functions = &protocol.FunctionsPayload{Text: functions}
+	}
+	return data
+}
+
+// toProtocolMessages 将消息转换为星火协议的消息格式, 消息类型映射为星火角色.
+func toProtocolMessages(msgs []messages.ChatMessage) []protocol.Message {
+	text := make([]protocol.Message, 0, len(msgs))
+	for _, m := range msgs {
+		msg := protocol.Message{
+			Role:    sparkRole(m.GetType()),
+			Content: m.GetContent(),
+		}
+		if resp, ok := m.(*ChatResponse); ok && resp.Role != "" {
+			// 回传的应答消息, GetType 在有函数调用时返回 function, 这里保留原始角色
+			msg.Role = resp.Role
+		}
+		if named, ok := m.(messages.Named); ok {
+			msg.Name = named.GetName()
+		}
+		if ct, ok := m.(interface{ GetContentType() string }); ok {
+			msg.ContentType = ct.GetContentType()
+		}
+		if fc, ok := m.(interface{ GetFunctionCall() *messages.FunctionCall }); ok && fc.GetFunctionCall() != nil {
+			msg.FunctionCall = &protocol.FunctionCall{
+				Name:      fc.GetFunctionCall().Name,
+				Arguments: fc.GetFunctionCall().Arguments,
+			}
+		}
+		text = append(text, msg)
+	}
+	return text
+}
+
+// sparkRole maps a message type to the Spark role, generic messages keep their role.
+func sparkRole(t messages.ChatMessageType) string {
+	switch t {
+	case messages.ChatMessageTypeAI:
+		return protocol.RoleAssistant
+	case messages.ChatMessageTypeHuman:
+		return protocol.RoleUser
+	case messages.ChatMessageTypeSystem:
+		return protocol.RoleSystem
+	case messages.ChatMessageTypeFunction:
+		return protocol.RoleFunction
+	default:
+		return string(t)
+	}
 }
 
 // nolint:lll
@@ -93,5 +164,9 @@ func (c *Client) createCompletion(ctx context.Context, payload *CompletionReques
 		TopK:        &payload.TopK,
 		MaxTokens:   &payload.MaxTokens,
 		Functions:   payload.Functions,
+		Uid:         &payload.Uid,
+		ChatId:      &payload.ChatId,
+		Audit:       &payload.Audit,
+		WebSearch:   payload.WebSearch,
 	}, nil)
 }
@@ -0,0 +1,144 @@
+package sparkclient
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/iflytek/spark-ai-go/sparkai/messages"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestConstructSparkReq(t *testing.T) {
+	t.Parallel()
+	c, err := New("generalv3.5", "key", "secret", "appid", "wss://spark-api.xf-yun.com/v3.5/chat", "", "", "")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		req  *ChatRequest
+	}{
+		{
+			name: "defaults",
+			req: &ChatRequest{
+				Messages: []messages.ChatMessage{
+					messages.GenericChatMessage{Role: "user", Content: "你好"},
+				},
+			},
+		},
+		{
+			name: "all_params",
+			req: &ChatRequest{
+				Domain: ptr("generalv3.5"),
+				Messages: []messages.ChatMessage{
+					messages.GenericChatMessage{Role: "system", Content: "你是一个助手"},
+					messages.GenericChatMessage{Role: "user", Content: "今天的新闻"},
+				},
+				Temperature: ptr(0.5),
+				TopK:        ptr(int64(4)),
+				MaxTokens:   ptr(int64(1024)),
+				Audit:       ptr(AuditingStrict),
+				Uid:         ptr("user-1"),
+				ChatId:      ptr("chat-1"),
+				WebSearch: &WebSearch{
+					Enable:       true,
+					ShowRefLabel: true,
+					SearchMode:   SearchModeDeep,
+				},
+			},
+		},
+		{
+			name: "functions",
+			req: &ChatRequest{
+				Domain: ptr("generalv3.5"),
+				Messages: []messages.ChatMessage{
+					messages.GenericChatMessage{Role: "user", Content: "合肥天气怎么样"},
+				},
+				Functions: []messages.FunctionDefinition{
+					{
+						Name:        "get_weather",
+						Description: "查询天气",
+						Parameters: map[string]any{
+							"type": "object",
+							"properties": map[string]any{
+								"city": map[string]any{"type": "string"},
+							},
+							"required": []string{"city"},
+						},
+					},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := json.MarshalIndent(c.constructSparkReq("appid", tt.req), "", "  ")
+			require.NoError(t, err)
+
+			golden := filepath.Join("testdata", "request_"+tt.name+".golden.json")
+			if *update {
+				require.NoError(t, os.WriteFile(golden, append(got, '\n'), 0o600))
+			}
+			want, err := os.ReadFile(golden)
+			require.NoError(t, err)
+			assert.JSONEq(t, string(want), string(got))
+		})
+	}
+}
+
+func TestConstructSparkReqOmitsEmpty(t *testing.T) {
+	t.Parallel()
+	c, err := New("generalv3.5", "key", "secret", "appid", "wss://spark-api.xf-yun.com/v3.5/chat", "", "", "")
+	require.NoError(t, err)
+
+	// 空字段不发送
+	data, err := json.Marshal(c.constructSparkReq("appid", &ChatRequest{
+		Uid:    ptr(""),
+		ChatId: ptr(""),
+	}))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"header":{"app_id":"appid"},
+		"parameter":{"chat":{"domain":"general","temperature":0.8,"top_k":6,"max_tokens":2048,"auditing":"default"}},
+		"payload":{"message":{"text":[]}}
+	}`, string(data))
+}
+
+func TestConstructSparkReqRoles(t *testing.T) {
+	t.Parallel()
+	c, err := New("generalv3.5", "key", "secret", "appid", "wss://spark-api.xf-yun.com/v3.5/chat", "", "", "")
+	require.NoError(t, err)
+
+	call := &messages.FunctionCall{Name: "get_weather", Arguments: `{"city":"合肥"}`}
+	data := c.constructSparkReq("appid", &ChatRequest{
+		Messages: []messages.ChatMessage{
+			messages.SystemChatMessage{Content: "system"},
+			messages.HumanChatMessage{Content: "human"},
+			messages.AIChatMessage{Content: "ai", FunctionCall: call},
+			messages.FunctionChatMessage{Name: "get_weather", Content: "晴"},
+			&messages.GenericChatMessage{Role: "User", Content: "generic"},
+			&ChatResponse{Role: "assistant", FunctionCall: call},
+		},
+	})
+	text := data.Payload.Message.Text
+	require.Len(t, text, 6)
+	roles := make([]string, 0, len(text))
+	for _, m := range text {
+		roles = append(roles, m.Role)
+	}
+	assert.Equal(t, []string{"system", "user", "assistant", "function", "user", "assistant"}, roles)
+	assert.Equal(t, "get_weather", text[2].FunctionCall.Name)
+	assert.Equal(t, "get_weather", text[3].Name)
+	assert.Equal(t, "get_weather", text[5].FunctionCall.Name)
+}
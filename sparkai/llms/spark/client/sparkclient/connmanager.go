@@ -0,0 +1,378 @@
+package sparkclient
+
+import (
+	"context"
+	"crypto/sha256"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	defaultSignedURLTTL = time.Minute
+	// Spark 拒绝 date 与服务端时间相差超过 300s 的鉴权 url
+	maxSignedURLTTL        = 4 * time.Minute
+	defaultWarmIdleTimeout = 15 * time.Second
+)
+
+// ConnManager manages the websocket connections of one or more clients: it
+// caches signed URLs, keeps pre-dialed connections warm and bounds the number
+// of concurrent sessions per app_id. A ConnManager may be shared by clients
+// with different credentials, it is safe for concurrent use.
+//
+// Spark serves a single chat session per websocket connection, so a warm
+// connection is one whose handshake is already done and which is used for
+// exactly one session. Warm connections count towards the app_id concurrency
+// quota enforced by Spark.
+type ConnManager struct {
+	warmConns       int
+	warmIdleTimeout time.Duration
+	maxSessions     int
+	signedURLTTL    time.Duration
+	// now returns the current time, replaced by the tests.
+	now func() time.Time
+
+	mu     sync.Mutex
+	pools  map[poolKey]*connPool
+	slots  map[string]chan struct{}
+	closed bool
+
+	dials             atomic.Int64
+	dialErrors        atomic.Int64
+	warmHits          atomic.Int64
+	warmDiscarded     atomic.Int64
+	sessions          atomic.Int64
+	activeSessions    atomic.Int64
+	dialLatency       atomic.Int64
+	generationLatency atomic.Int64
+}
+
+// ConnManagerOption is an option for the connection manager.
+type ConnManagerOption func(*ConnManager)
+
+// WithWarmConns sets the number of pre-dialed connections kept per endpoint
+// and credentials. Zero, the default, disables warm connections.
+func WithWarmConns(n int) ConnManagerOption {
+	return func(m *ConnManager) {
+		m.warmConns = n
+	}
+}
+
+// WithWarmIdleTimeout sets how long a warm connection may stay unused before
+// it is discarded. It should stay below the idle timeout of the Spark server.
+func WithWarmIdleTimeout(timeout time.Duration) ConnManagerOption {
+	return func(m *ConnManager) {
+		m.warmIdleTimeout = timeout
+	}
+}
+
+// WithMaxSessionsPerApp bounds the number of concurrent chat sessions per
+// app_id, extra sessions wait for a free slot or for their context to be done.
+// Zero, the default, means unbounded.
+func WithMaxSessionsPerApp(n int) ConnManagerOption {
+	return func(m *ConnManager) {
+		m.maxSessions = n
+	}
+}
+
+// WithSignedURLTTL sets how long a signed URL is reused. It is capped at 4
+// minutes since Spark rejects signatures dated more than 300s away.
+func WithSignedURLTTL(ttl time.Duration) ConnManagerOption {
+	return func(m *ConnManager) {
+		m.signedURLTTL = min(ttl, maxSignedURLTTL)
+	}
+}
+
+// NewConnManager returns a new connection manager.
+func NewConnManager(opts ...ConnManagerOption) *ConnManager {
+	m := &ConnManager{
+		warmIdleTimeout: defaultWarmIdleTimeout,
+		signedURLTTL:    defaultSignedURLTTL,
+		now:             time.Now,
+		pools:           make(map[poolKey]*connPool),
+		slots:           make(map[string]chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// WithConnManager makes the client dial through the given connection manager.
+func WithConnManager(m *ConnManager) Option {
+	return func(c *Client) error {
+		c.connManager = m
+		return nil
+	}
+}
+
+// ConnStats is a snapshot of the connection manager metrics.
+type ConnStats struct {
+	// Dials is the number of successful handshakes, warm connections included.
+	Dials int64
+	// DialErrors is the number of failed handshakes.
+	DialErrors int64
+	// WarmHits is the number of sessions served by a warm connection.
+	WarmHits int64
+	// WarmDiscarded is the number of warm connections dropped as expired or closed by the server.
+	WarmDiscarded int64
+	// Sessions is the number of completed chat sessions.
+	Sessions int64
+	// ActiveSessions is the number of sessions in progress.
+	ActiveSessions int64
+	// DialLatency is the cumulative duration of the successful handshakes.
+	DialLatency time.Duration
+	// GenerationLatency is the cumulative duration of the completed sessions,
+	// from the request frame being sent to the last frame being received.
+	GenerationLatency time.Duration
+}
+
+// AvgDialLatency returns the mean handshake duration.
+func (s ConnStats) AvgDialLatency() time.Duration {
+	if s.Dials == 0 {
+		return 0
+	}
+	return s.DialLatency / time.Duration(s.Dials)
+}
+
+// AvgGenerationLatency returns the mean session duration.
+func (s ConnStats) AvgGenerationLatency() time.Duration {
+	if s.Sessions == 0 {
+		return 0
+	}
+	return s.GenerationLatency / time.Duration(s.Sessions)
+}
+
+// Stats returns a snapshot of the metrics.
+func (m *ConnManager) Stats() ConnStats {
+	return ConnStats{
+		Dials:             m.dials.Load(),
+		DialErrors:        m.dialErrors.Load(),
+		WarmHits:          m.warmHits.Load(),
+		WarmDiscarded:     m.warmDiscarded.Load(),
+		Sessions:          m.sessions.Load(),
+		ActiveSessions:    m.activeSessions.Load(),
+		DialLatency:       time.Duration(m.dialLatency.Load()),
+		GenerationLatency: time.Duration(m.generationLatency.Load()),
+	}
+}
+
+// Close discards the warm connections and stops dialing new ones. Clients
+// using the manager keep working with fresh connections.
+func (m *ConnManager) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed = true
+	for _, p := range m.pools {
+		for _, w := range p.idle {
+			w.conn.Close()
+		}
+		p.idle = nil
+	}
+	return nil
+}
+
+// poolKey identifies the connections which can be used interchangeably.
+type poolKey struct {
+	url    string
+	appId  string
+	apiKey string
+	// secret is the hash of the API secret: the URLs signed with a rotated
+	// secret are not shared with the old one.
+	secret [sha256.Size]byte
+}
+
+type connPool struct {
+	signedURL string
+	signedAt  time.Time
+	idle      []warmConn
+	filling   bool
+}
+
+type warmConn struct {
+	conn     *websocket.Conn
+	dialedAt time.Time
+}
+
+// pool returns the pool of key, m.mu must be held.
+func (m *ConnManager) pool(key poolKey) *connPool {
+	p, ok := m.pools[key]
+	if !ok {
+		p = &connPool{}
+		m.pools[key] = p
+	}
+	return p
+}
+
+// signedURL returns the cached signed URL of key, signing a new one when the
+// cached one is too old.
+func (m *ConnManager) signedURL(key poolKey, sign func(now time.Time) (string, error)) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p := m.pool(key)
+	now := m.now()
+	if p.signedURL != "" && now.Sub(p.signedAt) < m.signedURLTTL {
+		return p.signedURL, nil
+	}
+	u, err := sign(now)
+	if err != nil {
+		return "", err
+	}
+	p.signedURL, p.signedAt = u, now
+	return u, nil
+}
+
+// invalidateURL drops the cached signed URL of key, e.g. after the server rejected it.
+func (m *ConnManager) invalidateURL(key poolKey) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pool(key).signedURL = ""
+}
+
+// acquire waits for a session slot of appId. The returned func releases it.
+func (m *ConnManager) acquire(ctx context.Context, appId string) (func(), error) {
+	if m.maxSessions <= 0 {
+		m.activeSessions.Add(1)
+		return m.release(nil), nil
+	}
+	m.mu.Lock()
+	slots, ok := m.slots[appId]
+	if !ok {
+		slots = make(chan struct{}, m.maxSessions)
+		m.slots[appId] = slots
+	}
+	m.mu.Unlock()
+
+	select {
+	case slots <- struct{}{}:
+		m.activeSessions.Add(1)
+		return m.release(slots), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (m *ConnManager) release(slots chan struct{}) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			m.activeSessions.Add(-1)
+			if slots != nil {
+				<-slots
+			}
+		})
+	}
+}
+
+// take pops a warm connection of key, expired ones are discarded. It returns
+// nil when no warm connection is available.
+func (m *ConnManager) take(key poolKey) *websocket.Conn {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p := m.pool(key)
+	for len(p.idle) > 0 {
+		// 优先使用最新建立的连接
+		w := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+		if m.now().Sub(w.dialedAt) < m.warmIdleTimeout {
+			m.warmHits.Add(1)
+			return w.conn
+		}
+		w.conn.Close()
+		m.warmDiscarded.Add(1)
+	}
+	return nil
+}
+
+// fill dials connections until key has the configured number of warm
+// connections. It returns immediately when another fill is in progress.
+func (m *ConnManager) fill(key poolKey, dial func() (*websocket.Conn, error)) error {
+	m.mu.Lock()
+	p := m.pool(key)
+	if m.closed || p.filling || m.warmConns <= 0 {
+		m.mu.Unlock()
+		return nil
+	}
+	p.filling = true
+	m.mu.Unlock()
+
+	defer func() {
+		m.mu.Lock()
+		p.filling = false
+		m.mu.Unlock()
+	}()
+	for {
+		m.mu.Lock()
+		full := m.closed || len(p.idle) >= m.warmConns
+		m.mu.Unlock()
+		if full {
+			return nil
+		}
+
+		conn, err := dial()
+		if err != nil {
+			return err
+		}
+		m.mu.Lock()
+		if m.closed {
+			m.mu.Unlock()
+			conn.Close()
+			return nil
+		}
+		p.idle = append(p.idle, warmConn{conn: conn, dialedAt: m.now()})
+		m.mu.Unlock()
+	}
+}
+
+func (m *ConnManager) observeDial(d time.Duration, err error) {
+	if err != nil {
+		m.dialErrors.Add(1)
+		return
+	}
+	m.dials.Add(1)
+	m.dialLatency.Add(int64(d))
+}
+
+func (m *ConnManager) observeSession(d time.Duration) {
+	m.sessions.Add(1)
+	m.generationLatency.Add(int64(d))
+}
+
+// Warmup signs the URL and dials the warm connections of the client ahead of
+// the first session. It is a no-op without a connection manager.
+func (c *Client) Warmup(ctx context.Context) error {
+	if c.connManager == nil {
+		return nil
+	}
+	if _, err := c.authURL(); err != nil {
+		return err
+	}
+	return c.connManager.fill(c.poolKey(), func() (*websocket.Conn, error) {
+		return c.dial(ctx)
+	})
+}
+
+func (c *Client) poolKey() poolKey {
+	return poolKey{url: c.baseURL, appId: c.appId, apiKey: c.apiKey, secret: sha256.Sum256([]byte(c.apiSecret))}
+}
+
+// chatSession is a connection carrying one chat session.
+type chatSession struct {
+	conn *websocket.Conn
+	// warm is set when conn was pre-dialed by the connection manager.
+	warm bool
+	// sent is the time the request frame was sent.
+	sent time.Time
+	// usedTokens is the total tokens reported at the end of the session.
+	usedTokens int
+	release    func()
+}
+
+// Close closes the connection and releases the session slot.
+func (s *chatSession) Close() error {
+	err := s.conn.Close()
+	s.release()
+	return err
+}
@@ -0,0 +1,240 @@
+package sparkclient
+
+import (
+	"context"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnManagerWarmConns(t *testing.T) {
+	t.Parallel()
+	srv := newFakeSparkServer(t, sparkFrame(0, 2, "Hello", sparkUsage))
+	m := NewConnManager(WithWarmConns(2))
+	defer m.Close()
+	c := newTestClient(t, srv, WithConnManager(m))
+
+	require.NoError(t, c.Warmup(context.Background()))
+	assert.Equal(t, int64(2), m.Stats().Dials)
+
+	resp, err := c.CreateChatWithCallBack(context.Background(), testChatRequest(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello", resp.GetContent())
+
+	stats := m.Stats()
+	assert.Equal(t, int64(1), stats.WarmHits)
+	assert.Equal(t, int64(1), stats.Sessions)
+	assert.Equal(t, int64(0), stats.ActiveSessions)
+	assert.Positive(t, stats.AvgDialLatency())
+	assert.Positive(t, stats.AvgGenerationLatency())
+}
+
+func TestConnManagerExpiredWarmConn(t *testing.T) {
+	t.Parallel()
+	srv := newFakeSparkServer(t, sparkFrame(0, 2, "Hello", sparkUsage))
+	m := NewConnManager(WithWarmConns(1), WithWarmIdleTimeout(time.Nanosecond))
+	defer m.Close()
+	c := newTestClient(t, srv, WithConnManager(m))
+
+	require.NoError(t, c.Warmup(context.Background()))
+	_, err := c.CreateChatWithCallBack(context.Background(), testChatRequest(), nil)
+	require.NoError(t, err)
+
+	stats := m.Stats()
+	assert.Equal(t, int64(0), stats.WarmHits)
+	assert.Equal(t, int64(1), stats.WarmDiscarded)
+}
+
+func TestConnManagerStaleWarmConn(t *testing.T) {
+	t.Parallel()
+	upgrader := websocket.Upgrader{}
+	handler := fakeSparkHandler([]string{sparkFrame(0, 2, "Hello", sparkUsage)})
+	var mu sync.Mutex
+	n := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		first := n == 0
+		n++
+		mu.Unlock()
+		if !first {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		// 模拟服务端关闭空闲连接
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		conn.Close()
+	}))
+	defer srv.Close()
+	m := NewConnManager(WithWarmConns(1))
+	defer m.Close()
+	c := newTestClient(t, srv, WithConnManager(m))
+
+	require.NoError(t, c.Warmup(context.Background()))
+	resp, err := c.CreateChatWithCallBack(context.Background(), testChatRequest(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello", resp.GetContent())
+	assert.Equal(t, int64(1), m.Stats().WarmDiscarded)
+}
+
+func TestConnManagerMaxSessionsPerApp(t *testing.T) {
+	t.Parallel()
+	// 不发送最后一帧, 会话一直占用
+	srv := newFakeSparkServer(t, sparkFrame(0, 0, "Hel", ""))
+	m := NewConnManager(WithMaxSessionsPerApp(1))
+	c := newTestClient(t, srv, WithConnManager(m))
+
+	stream, err := c.CreateChatStream(context.Background(), testChatRequest())
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), m.Stats().ActiveSessions)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = c.CreateChatStream(ctx, testChatRequest())
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// 其他 app_id 不受影响
+	other, err := New("generalv3.5", "key", "secret", "other",
+		"ws"+strings.TrimPrefix(srv.URL, "http")+"/v3.5/chat", "", "", "", WithConnManager(m))
+	require.NoError(t, err)
+	otherStream, err := other.CreateChatStream(context.Background(), testChatRequest())
+	require.NoError(t, err)
+	require.NoError(t, otherStream.Close())
+
+	require.NoError(t, stream.Close())
+	assert.Equal(t, int64(0), m.Stats().ActiveSessions)
+	stream, err = c.CreateChatStream(context.Background(), testChatRequest())
+	require.NoError(t, err)
+	require.NoError(t, stream.Close())
+}
+
+func TestConnManagerSignedURL(t *testing.T) {
+	t.Parallel()
+	var mu sync.Mutex
+	var queries []string
+	handler := fakeSparkHandler([]string{sparkFrame(0, 2, "Hello", sparkUsage)})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		queries = append(queries, r.URL.RawQuery)
+		mu.Unlock()
+		handler.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+	now := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	m := NewConnManager()
+	m.now = func() time.Time { return now }
+	c := newTestClient(t, srv, WithConnManager(m))
+	chat := func() {
+		t.Helper()
+		_, err := c.CreateChatWithCallBack(context.Background(), testChatRequest(), nil)
+		require.NoError(t, err)
+	}
+
+	chat()
+	now = now.Add(defaultSignedURLTTL - time.Second)
+	chat()
+	require.Len(t, queries, 2)
+	assert.Equal(t, queries[0], queries[1])
+
+	// 过期后重新签名
+	now = now.Add(time.Second)
+	chat()
+	require.Len(t, queries, 3)
+	assert.NotEqual(t, queries[1], queries[2])
+
+	// 失效后重新签名
+	m.invalidateURL(c.poolKey())
+	now = now.Add(time.Second)
+	chat()
+	require.Len(t, queries, 4)
+	assert.NotEqual(t, queries[2], queries[3])
+}
+
+func TestConnManagerSecrets(t *testing.T) {
+	t.Parallel()
+	var mu sync.Mutex
+	var queries []string
+	handler := fakeSparkHandler([]string{sparkFrame(0, 2, "Hello", sparkUsage)})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		queries = append(queries, r.URL.RawQuery)
+		mu.Unlock()
+		handler.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+	now := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	m := NewConnManager(WithWarmConns(1))
+	defer m.Close()
+	m.now = func() time.Time { return now }
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/v3.5/chat"
+	old, err := New("generalv3.5", "key", "old-secret", "appid", url, "", "", "", WithConnManager(m))
+	require.NoError(t, err)
+	rotated, err := New("generalv3.5", "key", "new-secret", "appid", url, "", "", "", WithConnManager(m))
+	require.NoError(t, err)
+	assert.NotEqual(t, old.poolKey(), rotated.poolKey())
+
+	// 密钥不同的客户端不共用签名 url 和预热连接
+	require.NoError(t, old.Warmup(context.Background()))
+	_, err = rotated.CreateChatWithCallBack(context.Background(), testChatRequest(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), m.Stats().WarmHits)
+	mu.Lock()
+	defer mu.Unlock()
+	// 会话结束后可能已为新密钥补充预热连接
+	require.GreaterOrEqual(t, len(queries), 2)
+	assert.NotEqual(t, queries[0], queries[1])
+}
+
+// BenchmarkCreateChat compares fresh connections against warm connections on
+// a local TLS stand-in server.
+func BenchmarkCreateChat(b *testing.B) {
+	srv := httptest.NewTLSServer(fakeSparkHandler([]string{
+		sparkFrame(0, 0, "Hel", ""),
+		sparkFrame(1, 2, "lo", sparkUsage),
+	}))
+	defer srv.Close()
+	url := "wss" + strings.TrimPrefix(srv.URL, "https") + "/v3.5/chat"
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+
+	for _, bc := range []struct {
+		name string
+		opts []ConnManagerOption
+	}{
+		{name: "cold"},
+		{name: "warm", opts: []ConnManagerOption{WithWarmConns(4)}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			m := NewConnManager(bc.opts...)
+			defer m.Close()
+			c, err := New("generalv3.5", "key", "secret", "appid", url, "", "", "",
+				WithRootCAs(pool), WithConnManager(m))
+			require.NoError(b, err)
+			require.NoError(b, c.Warmup(context.Background()))
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := c.CreateChatWithCallBack(context.Background(), testChatRequest(), nil); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.StopTimer()
+			stats := m.Stats()
+			b.ReportMetric(float64(stats.AvgDialLatency().Microseconds()), "dial-µs")
+			b.ReportMetric(float64(stats.AvgGenerationLatency().Microseconds()), "gen-µs")
+			b.ReportMetric(float64(stats.WarmHits)/float64(b.N), "warm-hits/op")
+		})
+	}
+}
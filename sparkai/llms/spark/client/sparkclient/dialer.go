@@ -0,0 +1,119 @@
+package sparkclient
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+
+	"github.com/gorilla/websocket"
+	"golang.org/x/net/proxy"
+)
+
+// ErrNilDialer is returned by WithDialer and WithProxyDialer when given a nil dialer.
+var ErrNilDialer = errors.New("nil dialer")
+
+// newDefaultDialer returns the dialer used when none is configured. It honors
+// the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.
+func newDefaultDialer() *websocket.Dialer {
+	return &websocket.Dialer{
+		Proxy: http.ProxyFromEnvironment,
+	}
+}
+
+// WithDialer sets the websocket dialer used to connect to Spark. The dialer is
+// copied, its HandshakeTimeout is used when set, otherwise the client
+// handshake timeout applies. Options configuring the dialer (proxy, TLS,
+// compression) apply on top of it when given after WithDialer.
+func WithDialer(dialer *websocket.Dialer) Option {
+	return func(c *Client) error {
+		if dialer == nil {
+			return ErrNilDialer
+		}
+		d := *dialer
+		c.dialer = &d
+		return nil
+	}
+}
+
+// WithProxy routes the websocket connections through the given proxy. HTTP
+// proxies (http://) and SOCKS5 proxies (socks5://) are supported, credentials
+// may be given in the URL user info.
+func WithProxy(proxyURL string) Option {
+	return func(c *Client) error {
+		u, err := url.Parse(proxyURL)
+		if err != nil {
+			return fmt.Errorf("invalid proxy url %q: %w", proxyURL, err)
+		}
+		switch u.Scheme {
+		case "http", "socks5", "socks5h":
+		default:
+			return fmt.Errorf("unsupported proxy scheme %q", u.Scheme)
+		}
+		c.dialer.Proxy = http.ProxyURL(u)
+		return nil
+	}
+}
+
+// WithProxyDialer dials the websocket connections through a golang.org/x/net/proxy
+// dialer, e.g. one returned by proxy.SOCKS5 or proxy.FromEnvironment. It
+// disables the URL based proxy of the dialer.
+func WithProxyDialer(dialer proxy.Dialer) Option {
+	return func(c *Client) error {
+		if dialer == nil {
+			return ErrNilDialer
+		}
+		c.dialer.Proxy = nil
+		c.dialer.NetDial = nil
+		c.dialer.NetDialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			if cd, ok := dialer.(proxy.ContextDialer); ok {
+				return cd.DialContext(ctx, network, addr)
+			}
+			return dialer.Dial(network, addr)
+		}
+		return nil
+	}
+}
+
+// WithTLSConfig sets the TLS configuration used for wss:// connections.
+func WithTLSConfig(config *tls.Config) Option {
+	return func(c *Client) error {
+		c.dialer.TLSClientConfig = config
+		return nil
+	}
+}
+
+// WithRootCAs sets the certificate authorities used to verify the server
+// certificate, e.g. the CA of a TLS intercepting egress proxy.
+func WithRootCAs(pool *x509.CertPool) Option {
+	return func(c *Client) error {
+		if c.dialer.TLSClientConfig == nil {
+			c.dialer.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		} else {
+			c.dialer.TLSClientConfig = c.dialer.TLSClientConfig.Clone()
+		}
+		c.dialer.TLSClientConfig.RootCAs = pool
+		return nil
+	}
+}
+
+// WithCompression enables the per message compression extension.
+func WithCompression(enabled bool) Option {
+	return func(c *Client) error {
+		c.dialer.EnableCompression = enabled
+		return nil
+	}
+}
+
+// websocketDialer returns the dialer to use for a session.
+func (c *Client) websocketDialer() *websocket.Dialer {
+	d := *c.dialer
+	if d.HandshakeTimeout == 0 {
+		d.HandshakeTimeout = c.handshakeTimeout
+	}
+	return &d
+}
@@ -0,0 +1,104 @@
+package sparkclient
+
+import (
+	"context"
+	"crypto/x509"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithDialer(t *testing.T) {
+	t.Parallel()
+	srv := newFakeSparkServer(t, sparkFrame(0, 2, "Hello", sparkUsage))
+
+	var dials atomic.Int32
+	d := &websocket.Dialer{
+		NetDialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			dials.Add(1)
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		},
+	}
+	c := newTestClient(t, srv, WithDialer(d))
+
+	resp, err := c.CreateChat(context.Background(), testChatRequest())
+	require.NoError(t, err)
+	assert.Equal(t, "Hello", resp.GetContent())
+	assert.Equal(t, int32(1), dials.Load())
+
+	_, err = New("generalv3.5", "key", "secret", "appid", srv.URL, "", "", "", WithDialer(nil))
+	require.ErrorIs(t, err, ErrNilDialer)
+}
+
+// newConnectProxy starts an HTTP proxy tunneling CONNECT requests.
+func newConnectProxy(t *testing.T, connects *atomic.Int32) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		connects.Add(1)
+		upstream, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		defer upstream.Close()
+		w.WriteHeader(http.StatusOK)
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		go func() { _, _ = io.Copy(upstream, conn) }()
+		_, _ = io.Copy(conn, upstream)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestWithProxy(t *testing.T) {
+	t.Parallel()
+	srv := newFakeSparkServer(t, sparkFrame(0, 2, "Hello", sparkUsage))
+	var connects atomic.Int32
+	proxySrv := newConnectProxy(t, &connects)
+
+	c := newTestClient(t, srv, WithProxy(proxySrv.URL))
+	resp, err := c.CreateChat(context.Background(), testChatRequest())
+	require.NoError(t, err)
+	assert.Equal(t, "Hello", resp.GetContent())
+	assert.Equal(t, int32(1), connects.Load())
+
+	_, err = New("generalv3.5", "key", "secret", "appid", srv.URL, "", "", "", WithProxy("ftp://proxy"))
+	require.Error(t, err)
+}
+
+func TestWithRootCAs(t *testing.T) {
+	t.Parallel()
+	srv := httptest.NewTLSServer(fakeSparkHandler([]string{sparkFrame(0, 2, "Hello", sparkUsage)}))
+	defer srv.Close()
+	url := "wss" + strings.TrimPrefix(srv.URL, "https") + "/v3.5/chat"
+
+	// 未信任测试证书时握手失败
+	c, err := New("generalv3.5", "key", "secret", "appid", url, "", "", "")
+	require.NoError(t, err)
+	_, err = c.CreateChat(context.Background(), testChatRequest())
+	require.Error(t, err)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+	c, err = New("generalv3.5", "key", "secret", "appid", url, "", "", "", WithRootCAs(pool))
+	require.NoError(t, err)
+	resp, err := c.CreateChat(context.Background(), testChatRequest())
+	require.NoError(t, err)
+	assert.Equal(t, "Hello", resp.GetContent())
+}
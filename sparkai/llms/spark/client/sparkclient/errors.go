@@ -0,0 +1,215 @@
+package sparkclient
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/iflytek/spark-ai-go/sparkai/retry"
+)
+
+// ErrorCategory groups Spark error codes by how callers are expected to react to them.
+type ErrorCategory string
+
+const (
+	// ErrorCategoryUnknown is used for codes missing from the catalog.
+	ErrorCategoryUnknown ErrorCategory = "unknown"
+	// ErrorCategoryAuth covers app_id authorization and blacklist errors.
+	ErrorCategoryAuth ErrorCategory = "auth"
+	// ErrorCategoryRateLimit covers quota, QPS and concurrency limits.
+	ErrorCategoryRateLimit ErrorCategory = "rate_limit"
+	// ErrorCategoryContentAudit covers input or output rejected by the content audit.
+	ErrorCategoryContentAudit ErrorCategory = "content_audit"
+	// ErrorCategoryInvalidParams covers malformed requests and parameters.
+	ErrorCategoryInvalidParams ErrorCategory = "invalid_params"
+	// ErrorCategoryServer covers engine and service side failures.
+	ErrorCategoryServer ErrorCategory = "server"
+)
+
+// Sentinel errors matching the error categories, use errors.Is to test an
+// error returned by the client against them.
+var (
+	ErrAuth          = errors.New("spark: authorization error")
+	ErrRateLimit     = errors.New("spark: quota or rate limit exceeded")
+	ErrContentAudit  = errors.New("spark: content audit blocked")
+	ErrInvalidParams = errors.New("spark: invalid request parameters")
+	ErrServer        = errors.New("spark: server error")
+)
+
+// sparkErrorCodes is the catalog of known Spark error codes.
+// See https://www.xfyun.cn/doc/spark/Web.html#_3-%E9%94%99%E8%AF%AF%E7%A0%81
+//
+// nolint:gochecknoglobals
+var sparkErrorCodes = map[int]ErrorCategory{
+	10000: ErrorCategoryServer,        // 升级为ws出现错误
+	10001: ErrorCategoryServer,        // 通过ws读取用户的消息出错
+	10002: ErrorCategoryServer,        // 通过ws向用户发送消息出错
+	10003: ErrorCategoryInvalidParams, // 用户的消息格式有错误
+	10004: ErrorCategoryInvalidParams, // 用户数据的schema错误
+	10005: ErrorCategoryInvalidParams, // 用户参数值有错误
+	10006: ErrorCategoryRateLimit,     // 用户并发错误
+	10007: ErrorCategoryRateLimit,     // 用户流量受限
+	10008: ErrorCategoryServer,        // 服务容量不足
+	10009: ErrorCategoryServer,        // 和引擎建立连接失败
+	10010: ErrorCategoryServer,        // 接收引擎数据的错误
+	10011: ErrorCategoryServer,        // 发送数据给引擎的错误
+	10012: ErrorCategoryServer,        // 引擎内部错误
+	10013: ErrorCategoryContentAudit,  // 输入内容审核不通过
+	10014: ErrorCategoryContentAudit,  // 输出内容涉及敏感信息
+	10015: ErrorCategoryAuth,          // appid在黑名单中
+	10016: ErrorCategoryAuth,          // appid授权类的错误
+	10017: ErrorCategoryServer,        // 清除历史失败
+	10019: ErrorCategoryContentAudit,  // 会话内容有涉及违规信息的倾向
+	10110: ErrorCategoryRateLimit,     // 服务忙
+	10163: ErrorCategoryInvalidParams, // 请求引擎的参数异常
+	10222: ErrorCategoryServer,        // 引擎网络异常
+	10907: ErrorCategoryInvalidParams, // token数量超过上限
+	11200: ErrorCategoryAuth,          // 该appId没有相关功能的授权或者业务量超过限制
+	11201: ErrorCategoryRateLimit,     // 日流控超限
+	11202: ErrorCategoryRateLimit,     // 秒级流控超限
+	11203: ErrorCategoryRateLimit,     // 并发流控超限
+}
+
+// CategoryOf returns the category of a Spark error code.
+func CategoryOf(code int) ErrorCategory {
+	if category, ok := sparkErrorCodes[code]; ok {
+		return category
+	}
+	return ErrorCategoryUnknown
+}
+
+// APIError is returned when Spark answers with a non-zero header code.
+type APIError struct {
+	// Code is the Spark error code.
+	Code int
+	// Message is the error message returned by Spark.
+	Message string
+	// Sid is the session id, useful when reporting issues to iFlytek.
+	Sid string
+	// Raw is the raw frame carrying the error.
+	Raw []byte
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("spark: api error code=%d, message=%s, sid=%s", e.Code, e.Message, e.Sid)
+}
+
+// Category returns the category of the error code.
+func (e *APIError) Category() ErrorCategory {
+	return CategoryOf(e.Code)
+}
+
+// Is reports whether target is the sentinel error of the error category.
+func (e *APIError) Is(target error) bool {
+	sentinel, ok := categorySentinels[e.Category()]
+	return ok && sentinel == target
+}
+
+// OutputFiltered reports whether the answer was blocked by the output content
+// audit (10014), as opposed to a rejected question.
+func (e *APIError) OutputFiltered() bool {
+	return e.Code == 10014
+}
+
+// Retryable reports whether the call may succeed if retried: rate limits and
+// server errors are transient, except the daily quota (11201).
+func (e *APIError) Retryable() bool {
+	switch e.Category() {
+	case ErrorCategoryRateLimit:
+		return e.Code != 11201
+	case ErrorCategoryServer:
+		return true
+	default:
+		return false
+	}
+}
+
+// nolint:gochecknoglobals
+var categorySentinels = map[ErrorCategory]error{
+	ErrorCategoryAuth:          ErrAuth,
+	ErrorCategoryRateLimit:     ErrRateLimit,
+	ErrorCategoryContentAudit:  ErrContentAudit,
+	ErrorCategoryInvalidParams: ErrInvalidParams,
+	ErrorCategoryServer:        ErrServer,
+}
+
+// maxHandshakeBody bounds how much of a rejected handshake response body is read.
+const maxHandshakeBody = 4096
+
+// HandshakeError is returned when Spark rejects the websocket upgrade, e.g.
+// 401 on signature errors, 403 when the client IP is not whitelisted or the
+// request date is skewed, 429 when the app_id is rate limited.
+type HandshakeError struct {
+	// StatusCode is the HTTP status code of the rejected upgrade.
+	StatusCode int
+	// Message is the message decoded from the response body, if any.
+	Message string
+	// Body is the raw response body.
+	Body string
+
+	retryAfter time.Duration
+}
+
+// newHandshakeError decodes a rejected handshake response.
+func newHandshakeError(resp *http.Response) *HandshakeError {
+	e := &HandshakeError{
+		StatusCode: resp.StatusCode,
+		retryAfter: retry.ParseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+	if resp.Body != nil {
+		b, err := io.ReadAll(io.LimitReader(resp.Body, maxHandshakeBody))
+		if err == nil {
+			e.Body = string(b)
+		}
+	}
+	var body struct {
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal([]byte(e.Body), &body); err == nil {
+		e.Message = body.Message
+	} else {
+		e.Message = strings.TrimSpace(e.Body)
+	}
+	return e
+}
+
+func (e *HandshakeError) Error() string {
+	return fmt.Sprintf("spark: handshake failed status=%d, message=%s", e.StatusCode, e.Message)
+}
+
+// Category returns the category of the rejected handshake.
+func (e *HandshakeError) Category() ErrorCategory {
+	switch {
+	case e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden:
+		return ErrorCategoryAuth
+	case e.StatusCode == http.StatusTooManyRequests:
+		return ErrorCategoryRateLimit
+	case e.StatusCode >= http.StatusInternalServerError:
+		return ErrorCategoryServer
+	case e.StatusCode >= http.StatusBadRequest:
+		return ErrorCategoryInvalidParams
+	default:
+		return ErrorCategoryUnknown
+	}
+}
+
+// Is reports whether target is the sentinel error of the error category.
+func (e *HandshakeError) Is(target error) bool {
+	sentinel, ok := categorySentinels[e.Category()]
+	return ok && sentinel == target
+}
+
+// Retryable reports whether the handshake may succeed if retried.
+func (e *HandshakeError) Retryable() bool {
+	category := e.Category()
+	return category == ErrorCategoryRateLimit || category == ErrorCategoryServer
+}
+
+// RetryAfter returns the delay requested by the Retry-After header, or zero.
+func (e *HandshakeError) RetryAfter() time.Duration {
+	return e.retryAfter
+}
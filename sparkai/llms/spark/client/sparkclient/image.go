@@ -0,0 +1,68 @@
+package sparkclient
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/iflytek/spark-ai-go/sparkai/messages"
+)
+
+// MaxImageSize is the maximum size in bytes of an image sent to the image
+// understanding endpoint, before base64 encoding.
+const MaxImageSize = 4 << 20
+
+// ContentTypeImage is the content_type of image messages.
+const ContentTypeImage = "image"
+
+var (
+	// ErrImageTooLarge is returned for images larger than MaxImageSize.
+	ErrImageTooLarge = errors.New("image too large")
+	// ErrImageFormat is returned for images in a format Spark does not accept.
+	ErrImageFormat = errors.New("unsupported image format")
+)
+
+// nolint:gochecknoglobals
+var imageFormats = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/bmp":  true,
+}
+
+// ImageChatMessage is a user message carrying an image, sent base64 encoded
+// with the image content_type. Spark expects it as the first message of the
+// conversation, followed by the questions about the image.
+type ImageChatMessage struct {
+	// MIMEType is the detected format of the image.
+	MIMEType string
+	// Data is the raw image.
+	Data []byte
+}
+
+var _ messages.ChatMessage = ImageChatMessage{}
+
+// NewImageChatMessage checks the size and the format of the image. The format
+// is sniffed from data, mimeType is only used in error messages.
+func NewImageChatMessage(mimeType string, data []byte) (ImageChatMessage, error) {
+	if len(data) > MaxImageSize {
+		return ImageChatMessage{}, fmt.Errorf("%w: %d bytes, at most %d", ErrImageTooLarge, len(data), MaxImageSize)
+	}
+	detected := http.DetectContentType(data)
+	if !imageFormats[detected] {
+		return ImageChatMessage{}, fmt.Errorf("%w: %s (declared %q), expected jpeg, png or bmp", ErrImageFormat, detected, mimeType)
+	}
+	return ImageChatMessage{MIMEType: detected, Data: data}, nil
+}
+
+func (m ImageChatMessage) GetType() messages.ChatMessageType { return messages.ChatMessageTypeHuman }
+
+// GetContent returns the base64 encoded image.
+func (m ImageChatMessage) GetContent() string {
+	return base64.StdEncoding.EncodeToString(m.Data)
+}
+
+func (m ImageChatMessage) UpdateContent(string) {}
+
+// GetContentType returns ContentTypeImage.
+func (m ImageChatMessage) GetContentType() string { return ContentTypeImage }
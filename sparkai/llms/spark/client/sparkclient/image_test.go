@@ -0,0 +1,54 @@
+package sparkclient
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/png"
+	"testing"
+
+	"github.com/iflytek/spark-ai-go/sparkai/messages"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testPNG(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewGray(image.Rect(0, 0, 2, 2))))
+	return buf.Bytes()
+}
+
+func TestNewImageChatMessage(t *testing.T) {
+	t.Parallel()
+	data := testPNG(t)
+
+	msg, err := NewImageChatMessage("image/jpeg", data)
+	require.NoError(t, err)
+	assert.Equal(t, "image/png", msg.MIMEType)
+	assert.Equal(t, base64.StdEncoding.EncodeToString(data), msg.GetContent())
+
+	_, err = NewImageChatMessage("image/gif", []byte("GIF89a......"))
+	require.ErrorIs(t, err, ErrImageFormat)
+
+	_, err = NewImageChatMessage("image/png", append(data, make([]byte, MaxImageSize)...))
+	require.ErrorIs(t, err, ErrImageTooLarge)
+}
+
+func TestConstructSparkReqImage(t *testing.T) {
+	t.Parallel()
+	c, err := New("image", "key", "secret", "appid", "wss://spark-api.cn-huabei-1.xf-yun.com/v2.1/image", "", "", "")
+	require.NoError(t, err)
+	img, err := NewImageChatMessage("image/png", testPNG(t))
+	require.NoError(t, err)
+
+	req := c.constructSparkReq("appid", &ChatRequest{
+		Messages: []messages.ChatMessage{img, messages.HumanChatMessage{Content: "图片里有什么"}},
+	})
+	text := req.Payload.Message.Text
+	require.Len(t, text, 2)
+	assert.Equal(t, "user", text[0].Role)
+	assert.Equal(t, ContentTypeImage, text[0].ContentType)
+	assert.Equal(t, img.GetContent(), text[0].Content)
+	assert.Empty(t, text[1].ContentType)
+}
@@ -0,0 +1,223 @@
+package sparkclient
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// ErrClientRateLimit is returned when a session would have to wait for the
+// limiter past the context deadline, or at all when the limiter fails fast.
+// It matches ErrRateLimit with errors.Is.
+var ErrClientRateLimit = fmt.Errorf("%w: client-side limit reached", ErrRateLimit)
+
+// LimiterConfig configures a Limiter. Zero fields disable the matching limit.
+type LimiterConfig struct {
+	// QPS is the rate of sessions allowed per second.
+	QPS float64
+	// Burst is the number of sessions which may start at once, it defaults to
+	// QPS rounded up.
+	Burst int
+	// MaxConcurrent is the maximum number of sessions in progress.
+	MaxConcurrent int
+	// TokensPerMinute is the token budget of a sliding one minute window,
+	// accounted with the total tokens reported at the end of each session.
+	// Sessions in progress are not accounted until they end.
+	TokensPerMinute int
+	// FailFast makes the limiter return ErrClientRateLimit instead of waiting.
+	FailFast bool
+}
+
+// Limiter enforces client-side QPS, concurrency and tokens per minute limits,
+// so that sessions are queued locally instead of being rejected by Spark.
+// Spark enforces its limits per app_id: clients sharing an app_id should
+// share a Limiter. It is safe for concurrent use.
+//
+// A session waits for the limiter until its context is done. If the context
+// has a deadline which the wait is known to exceed, ErrClientRateLimit is
+// returned immediately.
+type Limiter struct {
+	cfg LimiterConfig
+	sem chan struct{}
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+	usage  []tokenUsage
+}
+
+type tokenUsage struct {
+	at     time.Time
+	tokens int
+}
+
+// NewLimiter returns a new limiter.
+func NewLimiter(cfg LimiterConfig) *Limiter {
+	if cfg.QPS > 0 && cfg.Burst <= 0 {
+		cfg.Burst = int(math.Ceil(cfg.QPS))
+	}
+	l := &Limiter{
+		cfg:    cfg,
+		tokens: float64(cfg.Burst),
+		last:   time.Now(),
+	}
+	if cfg.MaxConcurrent > 0 {
+		l.sem = make(chan struct{}, cfg.MaxConcurrent)
+	}
+	return l
+}
+
+// WithLimiter makes the client wait for the given limiter before each session.
+func WithLimiter(l *Limiter) Option {
+	return func(c *Client) error {
+		c.limiter = l
+		return nil
+	}
+}
+
+// Acquire waits until a session may start. The returned func must be called
+// with the total tokens used by the session once it is finished.
+func (l *Limiter) Acquire(ctx context.Context) (func(tokens int), error) {
+	if err := l.acquireSlot(ctx); err != nil {
+		return nil, err
+	}
+	var once sync.Once
+	release := func(tokens int) {
+		once.Do(func() {
+			l.record(tokens)
+			if l.sem != nil {
+				<-l.sem
+			}
+		})
+	}
+	if err := l.waitBudget(ctx); err != nil {
+		release(0)
+		return nil, err
+	}
+	if err := l.waitToken(ctx); err != nil {
+		release(0)
+		return nil, err
+	}
+	return release, nil
+}
+
+func (l *Limiter) acquireSlot(ctx context.Context) error {
+	if l.sem == nil {
+		return nil
+	}
+	if l.cfg.FailFast {
+		select {
+		case l.sem <- struct{}{}:
+			return nil
+		default:
+			return ErrClientRateLimit
+		}
+	}
+	select {
+	case l.sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// waitBudget waits until the tokens used during the last minute fall below the budget.
+func (l *Limiter) waitBudget(ctx context.Context) error {
+	if l.cfg.TokensPerMinute <= 0 {
+		return nil
+	}
+	for {
+		l.mu.Lock()
+		wait := l.budgetWait(time.Now())
+		l.mu.Unlock()
+		if wait == 0 {
+			return nil
+		}
+		if err := l.wait(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// budgetWait returns how long until the budget is available again, l.mu must be held.
+func (l *Limiter) budgetWait(now time.Time) time.Duration {
+	i := 0
+	for i < len(l.usage) && now.Sub(l.usage[i].at) >= time.Minute {
+		i++
+	}
+	l.usage = l.usage[i:]
+	used := 0
+	for _, u := range l.usage {
+		used += u.tokens
+	}
+	if used < l.cfg.TokensPerMinute {
+		return 0
+	}
+	// 等待最早的用量移出窗口, 直到低于预算
+	for _, u := range l.usage {
+		used -= u.tokens
+		if used < l.cfg.TokensPerMinute {
+			return u.at.Add(time.Minute).Sub(now)
+		}
+	}
+	return 0
+}
+
+// waitToken takes a token from the QPS bucket, waiting for it if needed.
+func (l *Limiter) waitToken(ctx context.Context) error {
+	if l.cfg.QPS <= 0 {
+		return nil
+	}
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = math.Min(float64(l.cfg.Burst), l.tokens+now.Sub(l.last).Seconds()*l.cfg.QPS)
+	l.last = now
+	// 预占令牌, 令牌数可以为负, 表示需要排队等待的时间
+	l.tokens--
+	var wait time.Duration
+	if l.tokens < 0 {
+		wait = time.Duration(-l.tokens / l.cfg.QPS * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	if err := l.wait(ctx, wait); err != nil {
+		l.mu.Lock()
+		l.tokens++
+		l.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// wait sleeps for d, failing fast when the limiter is configured to or when
+// d exceeds the ctx deadline.
+func (l *Limiter) wait(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	if l.cfg.FailFast {
+		return ErrClientRateLimit
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Now().Add(d).After(deadline) {
+		return ErrClientRateLimit
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (l *Limiter) record(tokens int) {
+	if l.cfg.TokensPerMinute <= 0 || tokens <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.usage = append(l.usage, tokenUsage{at: time.Now(), tokens: tokens})
+}
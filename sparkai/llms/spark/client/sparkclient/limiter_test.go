@@ -0,0 +1,129 @@
+package sparkclient
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiterQPS(t *testing.T) {
+	t.Parallel()
+	l := NewLimiter(LimiterConfig{QPS: 20, Burst: 1})
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		release, err := l.Acquire(context.Background())
+		require.NoError(t, err)
+		release(0)
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+}
+
+func TestLimiterDeadline(t *testing.T) {
+	t.Parallel()
+	l := NewLimiter(LimiterConfig{QPS: 1})
+	release, err := l.Acquire(context.Background())
+	require.NoError(t, err)
+	release(0)
+
+	// 等待时间超过 ctx 截止时间时立即失败
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = l.Acquire(ctx)
+	require.ErrorIs(t, err, ErrClientRateLimit)
+	require.ErrorIs(t, err, ErrRateLimit)
+	assert.Less(t, time.Since(start), 50*time.Millisecond)
+
+	// 失败的请求不占用令牌
+	ctx, cancel = context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	release, err = l.Acquire(ctx)
+	require.NoError(t, err)
+	release(0)
+}
+
+func TestLimiterMaxConcurrent(t *testing.T) {
+	t.Parallel()
+	l := NewLimiter(LimiterConfig{MaxConcurrent: 1, FailFast: true})
+	release, err := l.Acquire(context.Background())
+	require.NoError(t, err)
+
+	_, err = l.Acquire(context.Background())
+	require.ErrorIs(t, err, ErrClientRateLimit)
+
+	release(0)
+	release(0)
+	release, err = l.Acquire(context.Background())
+	require.NoError(t, err)
+	release(0)
+}
+
+func TestLimiterMaxConcurrentQueue(t *testing.T) {
+	t.Parallel()
+	l := NewLimiter(LimiterConfig{MaxConcurrent: 1})
+	release, err := l.Acquire(context.Background())
+	require.NoError(t, err)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		release(0)
+	}()
+	release, err = l.Acquire(context.Background())
+	require.NoError(t, err)
+	release(0)
+
+	release, err = l.Acquire(context.Background())
+	require.NoError(t, err)
+	defer release(0)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = l.Acquire(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestLimiterTokensPerMinute(t *testing.T) {
+	t.Parallel()
+	l := NewLimiter(LimiterConfig{TokensPerMinute: 20})
+	for i := 0; i < 2; i++ {
+		release, err := l.Acquire(context.Background())
+		require.NoError(t, err)
+		release(10)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err := l.Acquire(ctx)
+	require.ErrorIs(t, err, ErrClientRateLimit)
+
+	l.mu.Lock()
+	wait := l.budgetWait(time.Now())
+	l.usage[0].at = l.usage[0].at.Add(-time.Minute)
+	l.mu.Unlock()
+	assert.Greater(t, wait, 59*time.Second)
+
+	// 最早的用量移出窗口后恢复
+	release, err := l.Acquire(ctx)
+	require.NoError(t, err)
+	release(0)
+}
+
+func TestCreateChatWithLimiter(t *testing.T) {
+	t.Parallel()
+	srv := newFakeSparkServer(t, sparkFrame(0, 2, "Hello", sparkUsage))
+	l := NewLimiter(LimiterConfig{TokensPerMinute: 10, MaxConcurrent: 1})
+	c := newTestClient(t, srv, WithLimiter(l))
+
+	_, err := c.CreateChatWithCallBack(context.Background(), testChatRequest(), nil)
+	require.NoError(t, err)
+
+	// 上一次会话用掉 14 个 token, 超出预算
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err = c.CreateChatWithCallBack(ctx, testChatRequest(), nil)
+	require.ErrorIs(t, err, ErrClientRateLimit)
+	assert.Empty(t, l.sem)
+}
@@ -0,0 +1,215 @@
+package sparkclient
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+const (
+	// DefaultHost is the host of the Spark chat endpoints.
+	DefaultHost = "spark-api.xf-yun.com"
+	// DefaultImageHost is the host of the Spark image understanding endpoint.
+	DefaultImageHost = "spark-api.cn-huabei-1.xf-yun.com"
+)
+
+// Logical model names, see LookupModel.
+const (
+	ModelSparkLite       = "spark-lite"
+	ModelSparkPro        = "spark-pro"
+	ModelSparkPro128K    = "spark-pro-128k"
+	ModelSparkMax        = "spark-max"
+	ModelSparkMax32K     = "spark-max-32k"
+	ModelSpark4Ultra     = "spark-4.0-ultra"
+	ModelSparkMultimodal = "spark-multimodal"
+)
+
+// ErrUnknownModel is returned when a model name is missing from the registry.
+var ErrUnknownModel = errors.New("unknown spark model")
+
+// ModelFeatures lists the features a model supports.
+type ModelFeatures struct {
+	// FunctionCall is set when the model accepts function definitions.
+	FunctionCall bool
+	// SystemRole is set when the model accepts system messages.
+	SystemRole bool
+	// Images is set when the model accepts image messages.
+	Images bool
+	// WebSearch is set when the model accepts the web search tool.
+	WebSearch bool
+}
+
+// Model describes a Spark model and the endpoint serving it.
+type Model struct {
+	// Name is the logical model name, e.g. spark-max.
+	Name string
+	// Aliases are the other names the model is known by.
+	Aliases []string
+	// Version is the API generation of the endpoint.
+	Version APIVersion
+	// Host is the endpoint host.
+	Host string
+	// Path is the endpoint path, e.g. /v3.5/chat.
+	Path string
+	// Domain is the domain parameter selecting the model.
+	Domain string
+	// ContextWindow is the maximum number of tokens of the conversation.
+	ContextWindow int
+	// MaxTokens is the maximum value of the max_tokens parameter.
+	MaxTokens int64
+	// Features lists the supported features.
+	Features ModelFeatures
+}
+
+// URL returns the websocket URL of the model endpoint.
+func (m Model) URL() string {
+	return "wss://" + m.Host + m.Path
+}
+
+// nolint:gochecknoglobals
+var models = []Model{
+	{
+		Name:          ModelSparkLite,
+		Aliases:       []string{"lite", "general", "v1.1"},
+		Version:       APIv1,
+		Host:          DefaultHost,
+		Path:          "/v1.1/chat",
+		Domain:        "lite",
+		ContextWindow: 4096,
+		MaxTokens:     4096,
+		Features:      ModelFeatures{SystemRole: true},
+	},
+	{
+		Name:          ModelSparkPro,
+		Aliases:       []string{"pro", "generalv3", "v3.1"},
+		Version:       APIv3,
+		Host:          DefaultHost,
+		Path:          "/v3.1/chat",
+		Domain:        "generalv3",
+		ContextWindow: 8192,
+		MaxTokens:     8192,
+		Features:      ModelFeatures{FunctionCall: true, SystemRole: true},
+	},
+	{
+		Name:          ModelSparkPro128K,
+		Aliases:       []string{"pro-128k"},
+		Version:       APIv3,
+		Host:          DefaultHost,
+		Path:          "/chat/pro-128k",
+		Domain:        "pro-128k",
+		ContextWindow: 131072,
+		MaxTokens:     4096,
+		Features:      ModelFeatures{FunctionCall: true, SystemRole: true},
+	},
+	{
+		Name:          ModelSparkMax,
+		Aliases:       []string{"max", "generalv3.5", "v3.5"},
+		Version:       APIv35,
+		Host:          DefaultHost,
+		Path:          "/v3.5/chat",
+		Domain:        "generalv3.5",
+		ContextWindow: 8192,
+		MaxTokens:     8192,
+		Features:      ModelFeatures{FunctionCall: true, SystemRole: true, WebSearch: true},
+	},
+	{
+		Name:          ModelSparkMax32K,
+		Aliases:       []string{"max-32k"},
+		Version:       APIv35,
+		Host:          DefaultHost,
+		Path:          "/chat/max-32k",
+		Domain:        "max-32k",
+		ContextWindow: 32768,
+		MaxTokens:     8192,
+		Features:      ModelFeatures{FunctionCall: true, SystemRole: true, WebSearch: true},
+	},
+	{
+		Name:          ModelSpark4Ultra,
+		Aliases:       []string{"4.0ultra", "ultra", "v4.0"},
+		Version:       APIv4,
+		Host:          DefaultHost,
+		Path:          "/v4.0/chat",
+		Domain:        "4.0Ultra",
+		ContextWindow: 8192,
+		MaxTokens:     8192,
+		Features:      ModelFeatures{FunctionCall: true, SystemRole: true, WebSearch: true},
+	},
+	{
+		Name:          ModelSparkMultimodal,
+		Aliases:       []string{"image", "multimodal"},
+		Version:       APIv2,
+		Host:          DefaultImageHost,
+		Path:          "/v2.1/image",
+		Domain:        "image",
+		ContextWindow: 8192,
+		MaxTokens:     2048,
+		Features:      ModelFeatures{Images: true},
+	},
+}
+
+// LookupModel returns the model registered under name, or under one of its
+// aliases. The lookup is case insensitive and also accepts the domain.
+func LookupModel(name string) (Model, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, m := range models {
+		if name == m.Name || name == strings.ToLower(m.Domain) {
+			return m, true
+		}
+		for _, alias := range m.Aliases {
+			if name == alias {
+				return m, true
+			}
+		}
+	}
+	return Model{}, false
+}
+
+// LookupModelByURL returns the model served by the endpoint of rawURL,
+// matched on its path.
+func LookupModelByURL(rawURL string) (Model, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return Model{}, false
+	}
+	path := strings.TrimSuffix(u.Path, "/")
+	for _, m := range models {
+		if path == m.Path {
+			return m, true
+		}
+	}
+	return Model{}, false
+}
+
+// Models returns the names of the registered models, sorted.
+func Models() []string {
+	names := make([]string, 0, len(models))
+	for _, m := range models {
+		names = append(names, m.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ResolveModel looks up name and checks it is consistent with the endpoint
+// and domain given explicitly, if any.
+func ResolveModel(name, baseURL, domain string) (Model, error) {
+	m, ok := LookupModel(name)
+	if !ok {
+		return Model{}, fmt.Errorf("%w %q, known models: %s", ErrUnknownModel, name, strings.Join(Models(), ", "))
+	}
+	if baseURL != "" {
+		u, err := url.Parse(baseURL)
+		if err != nil {
+			return Model{}, fmt.Errorf("invalid spark url %q: %w", baseURL, err)
+		}
+		if strings.TrimSuffix(u.Path, "/") != m.Path {
+			return Model{}, fmt.Errorf("spark url %q does not serve model %s, expected path %s", baseURL, m.Name, m.Path)
+		}
+	}
+	if domain != "" && domain != m.Domain {
+		return Model{}, fmt.Errorf("domain %q does not match model %s, expected %s", domain, m.Name, m.Domain)
+	}
+	return m, nil
+}
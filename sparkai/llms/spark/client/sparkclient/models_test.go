@@ -0,0 +1,72 @@
+package sparkclient
+
+import (
+	"testing"
+
+	"github.com/iflytek/spark-ai-go/sparkai/llms/spark/client/sparkclient/protocol"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookupModel(t *testing.T) {
+	t.Parallel()
+	for _, name := range []string{"spark-max", "MAX", "generalv3.5", " v3.5 "} {
+		m, ok := LookupModel(name)
+		require.True(t, ok, name)
+		assert.Equal(t, ModelSparkMax, m.Name)
+		assert.Equal(t, "generalv3.5", m.Domain)
+		assert.Equal(t, "wss://spark-api.xf-yun.com/v3.5/chat", m.URL())
+	}
+	m, ok := LookupModel("4.0Ultra")
+	require.True(t, ok)
+	assert.Equal(t, ModelSpark4Ultra, m.Name)
+
+	_, ok = LookupModel("gpt-4")
+	assert.False(t, ok)
+}
+
+func TestLookupModelByURL(t *testing.T) {
+	t.Parallel()
+	m, ok := LookupModelByURL("wss://spark-api.xf-yun.com/chat/pro-128k")
+	require.True(t, ok)
+	assert.Equal(t, ModelSparkPro128K, m.Name)
+
+	m, ok = LookupModelByURL("ws://127.0.0.1:8080/v1.1/chat/")
+	require.True(t, ok)
+	assert.Equal(t, ModelSparkLite, m.Name)
+
+	_, ok = LookupModelByURL("wss://spark-api.xf-yun.com/v3.1/multimodal")
+	assert.False(t, ok)
+}
+
+func TestResolveModel(t *testing.T) {
+	t.Parallel()
+	m, err := ResolveModel("spark-pro", "wss://proxy.example.com/v3.1/chat", "generalv3")
+	require.NoError(t, err)
+	assert.Equal(t, APIv3, m.Version)
+
+	_, err = ResolveModel("spark-ultra", "", "")
+	require.ErrorIs(t, err, ErrUnknownModel)
+	assert.Contains(t, err.Error(), ModelSpark4Ultra)
+
+	_, err = ResolveModel("spark-pro", "wss://spark-api.xf-yun.com/v3.5/chat", "")
+	require.Error(t, err)
+
+	_, err = ResolveModel("spark-pro", "", "generalv3.5")
+	require.Error(t, err)
+}
+
+func TestModelsConsistentWithProtocol(t *testing.T) {
+	t.Parallel()
+	// 版本号出现在路径中的模型, 注册表与协议包的版本及特性应一致
+	for _, name := range Models() {
+		m, _ := LookupModel(name)
+		v, ok := protocol.VersionFromURL(m.URL())
+		if !ok {
+			continue
+		}
+		assert.Equal(t, string(m.Version), string(v), name)
+		features, _ := v.Features()
+		assert.Equal(t, m.Features.FunctionCall, features.Functions, name)
+	}
+}
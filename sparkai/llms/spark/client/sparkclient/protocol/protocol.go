@@ -0,0 +1,217 @@
+// Package protocol defines the frames of the Spark chat websocket protocol.
+// See https://www.xfyun.cn/doc/spark/Web.html for the reference.
+package protocol
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// Frame status, see Choices.Status and ResponseHeader.Status.
+const (
+	// StatusFirst is the status of the first frame of a session.
+	StatusFirst = 0
+	// StatusContinue is the status of the intermediate frames.
+	StatusContinue = 1
+	// StatusLast is the status of the last frame of a session.
+	StatusLast = 2
+)
+
+// Message roles.
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+	RoleFunction  = "function"
+)
+
+// ToolWebSearch is the type of the web search tool.
+const ToolWebSearch = "web_search"
+
+// Request is the request frame sent once at the start of a session.
+type Request struct {
+	Header    RequestHeader  `json:"header"`
+	Parameter Parameter      `json:"parameter"`
+	Payload   RequestPayload `json:"payload"`
+}
+
+// RequestHeader carries the application and user identifiers.
+type RequestHeader struct {
+	// AppID is the application id.
+	AppID string `json:"app_id"`
+	// UID is the end user id, at most 32 characters.
+	UID string `json:"uid,omitempty"`
+}
+
+// Parameter carries the generation parameters.
+type Parameter struct {
+	Chat ChatParameter `json:"chat"`
+}
+
+// ChatParameter is the parameter.chat object of the request.
+type ChatParameter struct {
+	// Domain selects the model served by the endpoint.
+	Domain string `json:"domain"`
+	// Temperature is the sampling temperature, in (0, 1].
+	Temperature float64 `json:"temperature,omitempty"`
+	// MaxTokens is the maximum number of tokens to generate.
+	MaxTokens int64 `json:"max_tokens,omitempty"`
+	// TopK is the number of candidates sampled from, in [1, 6].
+	TopK int64 `json:"top_k,omitempty"`
+	// Auditing is the content audit strategy: default, strict or moderate.
+	Auditing string `json:"auditing,omitempty"`
+	// ChatID correlates the sessions of a conversation.
+	ChatID string `json:"chat_id,omitempty"`
+	// Tools configures the builtin tools.
+	Tools []Tool `json:"tools,omitempty"`
+}
+
+// Tool is a builtin Spark tool.
+type Tool struct {
+	// Type is the tool type, e.g. ToolWebSearch.
+	Type string `json:"type"`
+	// WebSearch configures the web search tool.
+	WebSearch *WebSearch `json:"web_search,omitempty"`
+}
+
+// WebSearch configures the Spark web search tool.
+type WebSearch struct {
+	// Enable turns the web search on or off.
+	Enable bool `json:"enable"`
+	// ShowRefLabel asks Spark to return the reference labels of the search results.
+	ShowRefLabel bool `json:"show_ref_label"`
+	// SearchMode is either normal or deep.
+	SearchMode string `json:"search_mode,omitempty"`
+}
+
+// RequestPayload carries the conversation and the function definitions.
+type RequestPayload struct {
+	Message   MessagePayload    `json:"message"`
+	Functions *FunctionsPayload `json:"functions,omitempty"`
+}
+
+// MessagePayload is the conversation history, the last message is the question.
+type MessagePayload struct {
+	Text []Message `json:"text"`
+}
+
+// Message is a message of the conversation.
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+	// ContentType is set to image for image messages, text otherwise.
+	ContentType string `json:"content_type,omitempty"`
+	// Name is the name of the function for function messages.
+	Name string `json:"name,omitempty"`
+	// FunctionCall is the function call of assistant messages.
+	FunctionCall *FunctionCall `json:"function_call,omitempty"`
+}
+
+// FunctionsPayload carries the function definitions.
+type FunctionsPayload struct {
+	Text []Function `json:"text"`
+}
+
+// Function is a function the model may call.
+type Function struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Parameters is the JSON schema of the function parameters.
+	Parameters any `json:"parameters"`
+}
+
+// FunctionCall is the name and arguments of a function call.
+type FunctionCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// Response is a response frame. Error frames only carry the header.
+type Response struct {
+	Header  ResponseHeader   `json:"header"`
+	Payload *ResponsePayload `json:"payload,omitempty"`
+}
+
+// ResponseHeader carries the status of the session.
+type ResponseHeader struct {
+	// Code is zero on success, the Spark error code otherwise.
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	// Sid is the session id.
+	Sid    string `json:"sid"`
+	Status int    `json:"status"`
+}
+
+// ResponsePayload carries a delta of the answer, and the usage on the last frame.
+type ResponsePayload struct {
+	Choices Choices `json:"choices"`
+	Usage   *Usage  `json:"usage,omitempty"`
+}
+
+// Choices is the delta carried by a frame.
+type Choices struct {
+	Status int      `json:"status"`
+	Seq    int      `json:"seq"`
+	Text   []Choice `json:"text"`
+}
+
+// Choice is a fragment of the answer.
+type Choice struct {
+	Content string `json:"content"`
+	Role    string `json:"role"`
+	Index   int    `json:"index"`
+	// ContentType is set by the versions supporting tools, e.g. text or tool.
+	ContentType  string        `json:"content_type,omitempty"`
+	FunctionCall *FunctionCall `json:"function_call,omitempty"`
+	// ToolCalls are the raw builtin tool results, e.g. web search outputs.
+	ToolCalls []json.RawMessage `json:"tool_calls,omitempty"`
+}
+
+// Usage is the token usage of the session.
+type Usage struct {
+	Text UsageText `json:"text"`
+}
+
+// UsageText holds the token counts.
+type UsageText struct {
+	QuestionTokens   int `json:"question_tokens"`
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// IsLast reports whether r is the last frame of the session.
+func (r *Response) IsLast() bool {
+	return r.Header.Status == StatusLast || (r.Payload != nil && r.Payload.Choices.Status == StatusLast)
+}
+
+// DecodeError is returned by DecodeResponse when a frame cannot be decoded.
+type DecodeError struct {
+	// Frame is the raw frame.
+	Frame []byte
+	Err   error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("spark protocol: decode frame: %v", e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// DecodeResponse decodes a response frame. In strict mode, fields unknown to
+// this package are reported as a DecodeError, which helps spotting protocol
+// changes while debugging.
+func DecodeResponse(data []byte, strict bool) (*Response, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if strict {
+		dec.DisallowUnknownFields()
+	}
+	var resp Response
+	if err := dec.Decode(&resp); err != nil {
+		return nil, &DecodeError{Frame: data, Err: err}
+	}
+	return &resp, nil
+}
@@ -0,0 +1,167 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readGolden(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	require.NoError(t, err)
+	return data
+}
+
+func TestRequestRoundTrip(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		golden  string
+		version Version
+	}{
+		{golden: "request_v1.1.json", version: V1_1},
+		{golden: "request_v3.5_functions.json", version: V3_5},
+		{golden: "request_v4.0_web_search.json", version: V4_0},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.golden, func(t *testing.T) {
+			t.Parallel()
+			data := readGolden(t, tt.golden)
+			dec := json.NewDecoder(bytes.NewReader(data))
+			dec.DisallowUnknownFields()
+			var req Request
+			require.NoError(t, dec.Decode(&req))
+			require.NoError(t, req.Validate(tt.version))
+
+			got, err := json.Marshal(&req)
+			require.NoError(t, err)
+			assert.JSONEq(t, string(data), string(got))
+		})
+	}
+}
+
+func TestResponseRoundTrip(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		golden string
+		check  func(t *testing.T, resp *Response)
+	}{
+		{
+			golden: "response_first.json",
+			check: func(t *testing.T, resp *Response) {
+				t.Helper()
+				assert.False(t, resp.IsLast())
+				assert.Equal(t, "你好，", resp.Payload.Choices.Text[0].Content)
+			},
+		},
+		{
+			golden: "response_last.json",
+			check: func(t *testing.T, resp *Response) {
+				t.Helper()
+				assert.True(t, resp.IsLast())
+				require.NotNil(t, resp.Payload.Usage)
+				assert.Equal(t, 14, resp.Payload.Usage.Text.TotalTokens)
+			},
+		},
+		{
+			golden: "response_function_call.json",
+			check: func(t *testing.T, resp *Response) {
+				t.Helper()
+				require.NotNil(t, resp.Payload.Choices.Text[0].FunctionCall)
+				assert.Equal(t, "get_weather", resp.Payload.Choices.Text[0].FunctionCall.Name)
+			},
+		},
+		{
+			golden: "response_tool_calls.json",
+			check: func(t *testing.T, resp *Response) {
+				t.Helper()
+				assert.Equal(t, "tool", resp.Payload.Choices.Text[0].ContentType)
+				assert.Len(t, resp.Payload.Choices.Text[0].ToolCalls, 1)
+			},
+		},
+		{
+			golden: "response_error.json",
+			check: func(t *testing.T, resp *Response) {
+				t.Helper()
+				assert.Equal(t, 10013, resp.Header.Code)
+				assert.Nil(t, resp.Payload)
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.golden, func(t *testing.T) {
+			t.Parallel()
+			data := readGolden(t, tt.golden)
+			resp, err := DecodeResponse(data, true)
+			require.NoError(t, err)
+			tt.check(t, resp)
+
+			got, err := json.Marshal(resp)
+			require.NoError(t, err)
+			assert.JSONEq(t, string(data), string(got))
+		})
+	}
+}
+
+func TestDecodeResponseStrict(t *testing.T) {
+	t.Parallel()
+	frame := []byte(`{"header":{"code":0,"message":"Success","sid":"sid","status":2,"new_field":1}}`)
+
+	resp, err := DecodeResponse(frame, false)
+	require.NoError(t, err)
+	assert.Equal(t, "sid", resp.Header.Sid)
+
+	_, err = DecodeResponse(frame, true)
+	var decodeErr *DecodeError
+	require.ErrorAs(t, err, &decodeErr)
+	assert.Contains(t, err.Error(), "new_field")
+	assert.Equal(t, frame, decodeErr.Frame)
+}
+
+func TestVersionFromURL(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		url  string
+		want Version
+		ok   bool
+	}{
+		{url: "wss://spark-api.xf-yun.com/v1.1/chat", want: V1_1, ok: true},
+		{url: "wss://spark-api.xf-yun.com/v3.5/chat", want: V3_5, ok: true},
+		{url: "wss://spark-api.xf-yun.com/v4.0/chat", want: V4_0, ok: true},
+		{url: "wss://spark-api.xf-yun.com/chat/pro-128k", ok: false},
+		{url: "ws://127.0.0.1:8080/chat", ok: false},
+	}
+	for _, tt := range tests {
+		got, ok := VersionFromURL(tt.url)
+		assert.Equal(t, tt.ok, ok, tt.url)
+		assert.Equal(t, tt.want, got, tt.url)
+	}
+}
+
+func TestRequestValidate(t *testing.T) {
+	t.Parallel()
+	req := Request{
+		Payload: RequestPayload{
+			Functions: &FunctionsPayload{Text: []Function{{Name: "get_weather"}}},
+		},
+	}
+	var unsupported *UnsupportedError
+	require.ErrorAs(t, req.Validate(V1_1), &unsupported)
+	assert.Equal(t, "functions", unsupported.Feature)
+	require.NoError(t, req.Validate(V3_1))
+	require.NoError(t, req.Validate(Version("v9.9")))
+
+	req = Request{Parameter: Parameter{Chat: ChatParameter{
+		Tools: []Tool{{Type: ToolWebSearch, WebSearch: &WebSearch{Enable: true}}},
+	}}}
+	require.ErrorAs(t, req.Validate(V3_1), &unsupported)
+	assert.Equal(t, "tools", unsupported.Feature)
+	require.NoError(t, req.Validate(V4_0))
+}
@@ -0,0 +1,84 @@
+package protocol
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Version is a generation of the Spark chat API, as found in the endpoint path.
+type Version string
+
+const (
+	V1_1 Version = "v1.1"
+	V2_1 Version = "v2.1"
+	V3_1 Version = "v3.1"
+	V3_5 Version = "v3.5"
+	V4_0 Version = "v4.0"
+)
+
+// Features lists the request features supported by a version.
+type Features struct {
+	// Functions is set when the version accepts function definitions.
+	Functions bool
+	// Tools is set when the version accepts the builtin tools.
+	Tools bool
+}
+
+// nolint:gochecknoglobals
+var versionFeatures = map[Version]Features{
+	V1_1: {},
+	V2_1: {},
+	V3_1: {Functions: true},
+	V3_5: {Functions: true, Tools: true},
+	V4_0: {Functions: true, Tools: true},
+}
+
+// Features returns the features of the version, ok is false for unknown versions.
+func (v Version) Features() (features Features, ok bool) {
+	features, ok = versionFeatures[v]
+	return features, ok
+}
+
+// VersionFromURL returns the version of a Spark endpoint such as
+// wss://spark-api.xf-yun.com/v3.5/chat. ok is false when the path does not
+// carry a known version.
+func VersionFromURL(rawURL string) (v Version, ok bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", false
+	}
+	for _, segment := range strings.Split(u.Path, "/") {
+		if _, ok := versionFeatures[Version(segment)]; ok {
+			return Version(segment), true
+		}
+	}
+	return "", false
+}
+
+// UnsupportedError is returned by Request.Validate when the request uses a
+// feature the API version does not support.
+type UnsupportedError struct {
+	Version Version
+	Feature string
+}
+
+func (e *UnsupportedError) Error() string {
+	return fmt.Sprintf("spark protocol: %s is not supported by %s", e.Feature, e.Version)
+}
+
+// Validate checks that the request only uses features supported by v.
+// Requests for unknown versions are not checked.
+func (r *Request) Validate(v Version) error {
+	features, ok := v.Features()
+	if !ok {
+		return nil
+	}
+	if r.Payload.Functions != nil && len(r.Payload.Functions.Text) > 0 && !features.Functions {
+		return &UnsupportedError{Version: v, Feature: "functions"}
+	}
+	if len(r.Parameter.Chat.Tools) > 0 && !features.Tools {
+		return &UnsupportedError{Version: v, Feature: "tools"}
+	}
+	return nil
+}
@@ -7,7 +7,10 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"github.com/gorilla/websocket"
+	"github.com/iflytek/spark-ai-go/sparkai/llms/spark/client/sparkclient/protocol"
 	"github.com/iflytek/spark-ai-go/sparkai/messages"
+	"github.com/iflytek/spark-ai-go/sparkai/retry"
 	"net/http"
 	"net/url"
 	"strings"
@@ -16,17 +19,24 @@ import (
 
 const (
 	apiVersion = "v3.1"
+
+	defaultHandshakeTimeout = 5 * time.Second
+	defaultReadTimeout      = 60 * time.Second
+	defaultWriteTimeout     = 10 * time.Second
 )
 
 // ErrEmptyResponse is returned when the OpenAI API returns an empty response.
 var ErrEmptyResponse = errors.New("empty response")
 
+// APIVersion is a generation of the Spark API, see Model.Version.
 type APIVersion string
 
 const (
-	APIv1 APIVersion = "v1.1"
-	APIv2 APIVersion = "v2.1"
-	APIv3 APIVersion = "v3.1"
+	APIv1  APIVersion = "v1.1"
+	APIv2  APIVersion = "v2.1"
+	APIv3  APIVersion = "v3.1"
+	APIv35 APIVersion = "v3.5"
+	APIv4  APIVersion = "v4.0"
 )
 
 // Client is a client for the OpenAI API.
@@ -37,11 +47,27 @@ type Client struct {
 	Model        string
 	baseURL      string
 	organization string
-	wsClient     Doer
+	dialer       *websocket.Dialer
 	domain       string
 	// required when APIVersion
 	apiVersion      APIVersion
 	embeddingsModel string
+
+	// handshakeTimeout bounds the websocket handshake.
+	handshakeTimeout time.Duration
+	// readTimeout is the maximum idle time between two frames of a session.
+	readTimeout time.Duration
+	// writeTimeout bounds the write of the request frame.
+	writeTimeout time.Duration
+
+	// retryPolicy is applied to chat sessions, the zero value disables retries.
+	retryPolicy retry.Policy
+	// connManager, if set, provides signed URLs, warm connections and session slots.
+	connManager *ConnManager
+	// limiter, if set, is waited for before each session.
+	limiter *Limiter
+	// debug enables strict decoding of the frames and request validation.
+	debug bool
 }
 
 // Option is an option for the Spark client.
@@ -66,6 +92,11 @@ func New(domain, apiKey, apiSecret, appId string, baseURL string, organization s
 		baseURL:         strings.TrimSuffix(baseURL, "/"),
 		organization:    organization,
 		apiVersion:      APIVersion(apiVersion),
+
+		handshakeTimeout: defaultHandshakeTimeout,
+		readTimeout:      defaultReadTimeout,
+		writeTimeout:     defaultWriteTimeout,
+		dialer:           newDefaultDialer(),
 	}
 
 	for _, opt := range opts {
@@ -77,6 +108,53 @@ func New(domain, apiKey, apiSecret, appId string, baseURL string, organization s
 	return c, nil
 }
 
+// WithHandshakeTimeout sets the maximum duration of the websocket handshake.
+// Zero means the handshake is only bounded by the request context.
+func WithHandshakeTimeout(timeout time.Duration) Option {
+	return func(c *Client) error {
+		c.handshakeTimeout = timeout
+		return nil
+	}
+}
+
+// WithReadTimeout sets the maximum idle time allowed between two frames of a
+// chat session. Zero disables the idle timeout, the session is then only
+// bounded by the request context.
+func WithReadTimeout(timeout time.Duration) Option {
+	return func(c *Client) error {
+		c.readTimeout = timeout
+		return nil
+	}
+}
+
+// WithWriteTimeout sets the maximum duration for sending the request frame.
+func WithWriteTimeout(timeout time.Duration) Option {
+	return func(c *Client) error {
+		c.writeTimeout = timeout
+		return nil
+	}
+}
+
+// WithDebug enables the debug mode: response frames carrying fields unknown
+// to the protocol package fail with a protocol.DecodeError, and requests using
+// features the API version does not support fail with a protocol.UnsupportedError
+// before being sent.
+func WithDebug(debug bool) Option {
+	return func(c *Client) error {
+		c.debug = debug
+		return nil
+	}
+}
+
+// WithRetryPolicy sets the policy used to retry failed chat sessions. A session
+// is only retried while no frame has been delivered to the caller.
+func WithRetryPolicy(policy retry.Policy) Option {
+	return func(c *Client) error {
+		c.retryPolicy = policy
+		return nil
+	}
+}
+
 // Completion is a completion.
 type Completion struct {
 	Text string `json:"text"`
@@ -127,39 +205,31 @@ func (c *Client) CreateChat(ctx context.Context, r *ChatRequest) (messages.ChatM
 	if err != nil {
 		return nil, err
 	}
-	switch resp.GetType() {
-	case messages.ChatMessageTypeAI:
-		fcMsg := resp.(messages.AIChatMessage)
-		fmt.Println(fcMsg.FunctionCall.GetContent())
-	default:
-		fmt.Println(resp.GetContent())
-
-	}
-
 	return resp, nil
 }
 
-// CreateChat creates chat request.
+// CreateChatWithCallBack creates chat request, stream_cb is called for every frame received.
+// An error returned by stream_cb aborts the chat session and is returned to the caller.
 func (c *Client) CreateChatWithCallBack(ctx context.Context, r *ChatRequest, stream_cb func(msg messages.ChatMessage) error) (messages.ChatMessage, error) {
 
 	resp, err := c.createChat(ctx, r, stream_cb)
 	if err != nil {
 		return nil, err
 	}
-	if len(resp.GetContent()) == 0 {
+	if len(resp.GetContent()) == 0 && resp.FunctionCall == nil {
 		return nil, ErrEmptyResponse
 	}
 	return resp, nil
 }
 
-// 创建鉴权url  apikey 即 hmac username
-func (c *Client) assembleAuthUrl1(hosturl string, apiKey, apiSecret string) string {
+// 创建鉴权url  apikey 即 hmac username, now 为签名时间
+func (c *Client) assembleAuthUrl1(hosturl string, apiKey, apiSecret string, now time.Time) (string, error) {
 	ul, err := url.Parse(hosturl)
 	if err != nil {
-		fmt.Println(err)
+		return "", fmt.Errorf("invalid spark url %q: %w", hosturl, err)
 	}
 	//签名时间
-	date := time.Now().UTC().Format(time.RFC1123)
+	date := now.UTC().Format(time.RFC1123)
 	//date = "Tue, 28 May 2019 09:10:42 MST"
 	//参与签名的字段 host ,date, request-line
 	signString := []string{"host: " + ul.Host, "date: " + date, "GET " + ul.Path + " HTTP/1.1"}
@@ -181,7 +251,7 @@ func (c *Client) assembleAuthUrl1(hosturl string, apiKey, apiSecret string) stri
 	v.Add("authorization", authorization)
 	//将编码后的字符串url encode后添加到url后面
 	callurl := hosturl + "?" + v.Encode()
-	return callurl
+	return callurl, nil
 }
 
 func (c *Client) HmacWithShaTobase64(algorithm, data, key string) string {
@@ -191,6 +261,15 @@ func (c *Client) HmacWithShaTobase64(algorithm, data, key string) string {
 	return base64.StdEncoding.EncodeToString(encodeData)
 }
 
+// protocolVersion returns the API version of the client, taken from the
+// configured API version or from the endpoint path.
+func (c *Client) protocolVersion() (protocol.Version, bool) {
+	if _, ok := protocol.Version(c.apiVersion).Features(); ok {
+		return protocol.Version(c.apiVersion), true
+	}
+	return protocol.VersionFromURL(c.baseURL)
+}
+
 func (c *Client) buildURL(suffix string, model string) string {
 	// spark ai implement:
 	return fmt.Sprintf("%s%s", c.baseURL, suffix)
@@ -0,0 +1,155 @@
+package sparkclient
+
+import (
+	"context"
+	"io"
+
+	"github.com/iflytek/spark-ai-go/sparkai/messages"
+	"github.com/iflytek/spark-ai-go/sparkai/retry"
+)
+
+const defaultStreamBufferSize = 16
+
+// ChatStreamEvent is a single delta frame of a streamed Spark chat session.
+type ChatStreamEvent struct {
+	// Seq is the sequence number of the frame.
+	Seq int `json:"seq"`
+	// Status is the Spark frame status: 0 first frame, 1 intermediate frame, 2 last frame.
+	Status int `json:"status"`
+	// Role is the role of the author of the delta.
+	Role string `json:"role,omitempty"`
+	// Content is the content delta carried by this frame.
+	Content string `json:"content,omitempty"`
+	// FunctionCall is the function call fragment carried by this frame, if any.
+	FunctionCall *messages.FunctionCall `json:"function_call,omitempty"`
+	// Usage is only set on the last frame.
+	Usage *ChatUsage `json:"usage,omitempty"`
+}
+
+// IsLast reports whether the event is the last frame of the session.
+func (e *ChatStreamEvent) IsLast() bool {
+	return e.Status == 2
+}
+
+// message converts the event to the chat message handed to CreateChatWithCallBack callbacks.
+func (e *ChatStreamEvent) message() messages.ChatMessage {
+	if e.FunctionCall != nil {
+		return messages.AIChatMessage{
+			Content:      e.FunctionCall.GetContent(),
+			FunctionCall: e.FunctionCall,
+		}
+	}
+	return messages.GenericChatMessage{
+		Content: e.Content,
+		Role:    e.Role,
+	}
+}
+
+// ChatStream is a streamed Spark chat session. Events are delivered in order
+// through Recv or Events, the aggregated response is available from Response
+// once the stream is finished.
+//
+// The session is aborted when the context passed to CreateChatStream is
+// cancelled or when Close is called. Callers that stop reading events before
+// the end of the stream must call Close to release the connection.
+type ChatStream struct {
+	events chan ChatStreamEvent
+	done   chan struct{}
+	cancel context.CancelFunc
+
+	resp *ChatResponse
+	err  error
+}
+
+// CreateChatStream starts a chat session and returns a stream of its deltas.
+// The handshake and request frame are sent before returning, so connection
+// errors are returned directly.
+func (c *Client) CreateChatStream(ctx context.Context, r *ChatRequest) (*ChatStream, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	var sess *chatSession
+	err := retry.Do(ctx, c.retryPolicy, func(attempt int) error {
+		var err error
+		sess, err = c.openChat(ctx, r, true)
+		return err
+	})
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	s := &ChatStream{
+		events: make(chan ChatStreamEvent, defaultStreamBufferSize),
+		done:   make(chan struct{}),
+		cancel: cancel,
+	}
+	go func() {
+		defer close(s.done)
+		defer close(s.events)
+		defer cancel()
+
+		s.resp, s.err = c.chat(ctx, r, sess, func(event *ChatStreamEvent) error {
+			select {
+			case s.events <- *event:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		if s.err == nil && len(s.resp.Content) == 0 && s.resp.FunctionCall == nil {
+			s.err = ErrEmptyResponse
+		}
+	}()
+	return s, nil
+}
+
+// Events returns the channel the stream deltas are delivered on. The channel
+// is closed when the stream ends, call Err or Response to know why.
+func (s *ChatStream) Events() <-chan ChatStreamEvent {
+	return s.events
+}
+
+// Recv returns the next delta of the stream. It returns io.EOF once the stream
+// has finished successfully, or the error that aborted it.
+func (s *ChatStream) Recv() (ChatStreamEvent, error) {
+	event, ok := <-s.events
+	if ok {
+		return event, nil
+	}
+	<-s.done
+	if s.err != nil {
+		return ChatStreamEvent{}, s.err
+	}
+	return ChatStreamEvent{}, io.EOF
+}
+
+// Response waits for the end of the stream and returns the aggregated response.
+// Undelivered events are discarded.
+func (s *ChatStream) Response() (*ChatResponse, error) {
+	for range s.events { //nolint:revive
+	}
+	<-s.done
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.resp, nil
+}
+
+// Err returns the error that aborted the stream, if any. It is only
+// meaningful after the events channel has been closed.
+func (s *ChatStream) Err() error {
+	select {
+	case <-s.done:
+		return s.err
+	default:
+		return nil
+	}
+}
+
+// Close aborts the stream and releases the underlying connection.
+func (s *ChatStream) Close() error {
+	s.cancel()
+	for range s.events { //nolint:revive
+	}
+	<-s.done
+	return nil
+}
@@ -0,0 +1,69 @@
+package spark
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/iflytek/spark-ai-go/sparkai/llms/spark/client/sparkclient"
+	"github.com/iflytek/spark-ai-go/sparkai/messages"
+)
+
+// imageMessage converts an image part to a Spark image message, URLs are
+// fetched with the HTTP client of the LLM.
+func (o *LLM) imageMessage(ctx context.Context, part messages.ContentPart) (sparkclient.ImageChatMessage, error) {
+	switch p := part.(type) {
+	case messages.BinaryContent:
+		return sparkclient.NewImageChatMessage(p.MIMEType, p.Data)
+	case messages.ImageURLContent:
+		mimeType, data, err := o.fetchImage(ctx, p.URL)
+		if err != nil {
+			return sparkclient.ImageChatMessage{}, err
+		}
+		return sparkclient.NewImageChatMessage(mimeType, data)
+	default:
+		return sparkclient.ImageChatMessage{}, fmt.Errorf("content part %T not supported", part) //nolint:goerr113
+	}
+}
+
+// fetchImage downloads the image at rawURL, data: URLs are decoded in place.
+// At most sparkclient.MaxImageSize+1 bytes are read so oversized images are
+// rejected without being downloaded entirely.
+func (o *LLM) fetchImage(ctx context.Context, rawURL string) (string, []byte, error) {
+	if strings.HasPrefix(rawURL, "data:") {
+		return decodeDataURL(rawURL)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return "", nil, fmt.Errorf("fetch image: %w", err)
+	}
+	resp, err := o.httpClient.Do(req)
+	if err != nil {
+		return "", nil, fmt.Errorf("fetch image %s: %w", rawURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", nil, fmt.Errorf("fetch image %s: unexpected status %s", rawURL, resp.Status) //nolint:goerr113
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, sparkclient.MaxImageSize+1))
+	if err != nil {
+		return "", nil, fmt.Errorf("fetch image %s: %w", rawURL, err)
+	}
+	return resp.Header.Get("Content-Type"), data, nil
+}
+
+// decodeDataURL decodes a base64 data URL such as data:image/png;base64,....
+func decodeDataURL(rawURL string) (string, []byte, error) {
+	meta, payload, ok := strings.Cut(strings.TrimPrefix(rawURL, "data:"), ",")
+	if !ok || !strings.HasSuffix(meta, ";base64") {
+		return "", nil, fmt.Errorf("invalid image data url, expected data:<mime>;base64,<data>") //nolint:goerr113
+	}
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid image data url: %w", err)
+	}
+	return strings.TrimSuffix(meta, ";base64"), data, nil
+}
@@ -16,28 +16,72 @@ var (
 	ErrMissingAPISecret         = errors.New("missing the Spark API secret, set it in the SPARK_API_SECRET environment variable") //nolint:lll
 	ErrMissingAPI               = errors.New("missing the SPARK_BASE_URL set it in the SPARK_BASE_URL environment variable")      //nolint:lll
 	ErrUnexpectedResponseLength = errors.New("unexpected length of response")
-	DefaultSparkUrl             = "wss://spark-api.xf-yun.com/v3.1/multimodal"
+	ErrImagesNotSupported       = errors.New("images are not supported by the spark model")
+	DefaultSparkUrl             = "wss://spark-api.xf-yun.com/v3.5/chat"
 )
 
 // NewClient is wrapper for sparkclient internal package.
+//
+// Options take precedence over the model selected by WithModel, which takes
+// precedence over the environment variables. When neither a model nor a
+// domain is given, the domain is inferred from the endpoint URL.
 func NewClient(opts ...Option) (*options, *sparkclient.Client, error) {
-	sparkUrl := os.Getenv(BaseURLEnvVarName)
-	if sparkUrl == "" {
-		sparkUrl = DefaultSparkUrl
-	}
 	options := &options{
-		apiKey:       os.Getenv(ApiKeyEnvVarName),
-		apiSecret:    os.Getenv(ApiSecretEnvVarName),
-		appId:        os.Getenv(AppIdEnvVarName),
-		baseURL:      sparkUrl,
-		organization: os.Getenv(organizationEnvVarName),
-		httpClient:   http.DefaultClient,
-		domain:       os.Getenv(SparkDomainEnvVarName),
+		httpClient: http.DefaultClient,
 	}
 
 	for _, opt := range opts {
 		opt(options)
 	}
+	if options.model == "" {
+		options.model = os.Getenv(ModelEnvVarName)
+	}
+	if options.model != "" {
+		m, err := sparkclient.ResolveModel(options.model, options.baseURL, options.domain)
+		if err != nil {
+			return options, nil, err
+		}
+		options.modelInfo = &m
+		if options.baseURL == "" {
+			options.baseURL = m.URL()
+		}
+		if options.domain == "" {
+			options.domain = m.Domain
+		}
+		if options.apiVersion == "" {
+			options.apiVersion = string(m.Version)
+		}
+	}
+	// 未通过参数设置的字段从环境变量读取
+	if options.apiKey == "" {
+		options.apiKey = os.Getenv(ApiKeyEnvVarName)
+	}
+	if options.apiSecret == "" {
+		options.apiSecret = os.Getenv(ApiSecretEnvVarName)
+	}
+	if options.appId == "" {
+		options.appId = os.Getenv(AppIdEnvVarName)
+	}
+	if options.organization == "" {
+		options.organization = os.Getenv(organizationEnvVarName)
+	}
+	if options.baseURL == "" {
+		options.baseURL = getEnvs(BaseURLEnvVarName)
+	}
+	if options.baseURL == "" {
+		options.baseURL = DefaultSparkUrl
+	}
+	if options.domain == "" {
+		options.domain = os.Getenv(SparkDomainEnvVarName)
+	}
+	if options.modelInfo == nil {
+		// 根据 url 推断模型及 domain
+		if m, ok := sparkclient.LookupModelByURL(options.baseURL); ok && (options.domain == "" || options.domain == m.Domain) {
+			options.modelInfo = &m
+			options.domain = m.Domain
+		}
+	}
+
 	if len(options.baseURL) == 0 {
 		return options, nil, ErrMissingAPI
 	}
@@ -53,8 +97,11 @@ func NewClient(opts ...Option) (*options, *sparkclient.Client, error) {
 	if len(options.domain) == 0 {
 		return options, nil, ErrMissingDomain
 	}
+	if options.retryPolicy != nil {
+		options.clientOptions = append(options.clientOptions, sparkclient.WithRetryPolicy(*options.retryPolicy))
+	}
 	cli, err := sparkclient.New(options.domain, options.apiKey, options.apiSecret, options.appId, options.baseURL, options.organization,
-		options.apiVersion, options.embeddingModel)
+		options.apiVersion, options.embeddingModel, options.clientOptions...)
 	return options, cli, err
 }
 
@@ -0,0 +1,36 @@
+package spark
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/iflytek/spark-ai-go/sparkai/llms"
+	"github.com/iflytek/spark-ai-go/sparkai/messages"
+)
+
+const (
+	jsonInstruction       = "你只能输出一个合法的 JSON, 不要输出 Markdown 代码块或任何其他文字."
+	jsonSchemaInstruction = "\nJSON 必须符合以下 JSON Schema:\n%s"
+)
+
+// withResponseFormat emulates the JSON response formats, which Spark does not
+// support, with a system instruction. The answer is then validated by
+// llms.ParseJSON.
+func withResponseFormat(msgs []messages.ChatMessage, format *llms.ResponseFormat) ([]messages.ChatMessage, error) {
+	if !format.IsJSON() {
+		return msgs, nil
+	}
+	instruction := jsonInstruction
+	if format.JSONSchema != nil && format.JSONSchema.Schema != nil {
+		schema, err := json.Marshal(format.JSONSchema.Schema)
+		if err != nil {
+			return nil, fmt.Errorf("marshal json schema: %w", err)
+		}
+		instruction += fmt.Sprintf(jsonSchemaInstruction, schema)
+	}
+	if len(msgs) > 0 && msgs[0].GetType() == messages.ChatMessageTypeSystem {
+		system := messages.SystemChatMessage{Content: msgs[0].GetContent() + "\n" + instruction}
+		return append([]messages.ChatMessage{system}, msgs[1:]...), nil
+	}
+	return append([]messages.ChatMessage{messages.SystemChatMessage{Content: instruction}}, msgs...), nil
+}
@@ -2,27 +2,72 @@ package spark
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/iflytek/spark-ai-go/log"
 	"github.com/iflytek/spark-ai-go/sparkai/callbacks"
 	"github.com/iflytek/spark-ai-go/sparkai/llms"
 	"github.com/iflytek/spark-ai-go/sparkai/llms/spark/client/sparkclient"
+	"github.com/iflytek/spark-ai-go/sparkai/llms/spark/client/sparkclient/protocol"
 	"github.com/iflytek/spark-ai-go/sparkai/messages"
 )
 
+// init registers the context sizes of the Spark models, for llms.GetModelContextSize.
+func init() { //nolint:gochecknoinits
+	for _, name := range sparkclient.Models() {
+		m, _ := sparkclient.LookupModel(name)
+		llms.RegisterModelContextSize(m.Name, m.ContextWindow)
+		llms.RegisterModelContextSize(m.Domain, m.ContextWindow)
+		for _, alias := range m.Aliases {
+			llms.RegisterModelContextSize(alias, m.ContextWindow)
+		}
+	}
+}
+
 type LLM struct {
 	CallbacksHandler callbacks.Handler
 	client           *sparkclient.Client
+	domain           string
+	maxConcurrency   int
+	strictOptions    bool
+	// choiceTemperatures are the temperatures of the choices when N > 1.
+	choiceTemperatures []float64
+	// model is the registered model served by the endpoint, if known.
+	model *sparkclient.Model
+	// httpClient fetches the images given by URL.
+	httpClient sparkclient.Doer
 }
 
+var _ llms.Model = (*LLM)(nil)
+
 // New returns a new Spark LLM.
 func New(opts ...Option) (*LLM, error) {
-	opt, c, err := NewClient(opts...)
+	llm := &LLM{}
+	opt, c, err := NewClient(append(opts[:len(opts):len(opts)], withRetryCallback(llm))...)
 	if err != nil {
 		return nil, err
 	}
-	return &LLM{
-		client:           c,
-		CallbacksHandler: opt.callbackHandler,
-	}, err
+	llm.client = c
+	llm.CallbacksHandler = opt.callbackHandler
+	llm.domain = opt.domain
+	llm.maxConcurrency = opt.maxConcurrency
+	llm.strictOptions = opt.strictOptions
+	llm.choiceTemperatures = opt.choiceTemperatures
+	llm.model = opt.modelInfo
+	llm.httpClient = opt.httpClient
+	return llm, err
+}
+
+// withRetryCallback reports the retries of the client to the LLM callbacks handler.
+func withRetryCallback(llm *LLM) Option {
+	return func(opts *options) {
+		if opts.retryPolicy != nil {
+			policy := callbacks.WithRetryHandler(*opts.retryPolicy, func() callbacks.Handler { return llm.CallbacksHandler })
+			opts.retryPolicy = &policy
+		}
+	}
 }
 
 // Call requests a completion for the given prompt.
@@ -47,40 +92,300 @@ func (o *LLM) Generate(ctx context.Context, prompts []string, options ...llms.Ca
 		opt(&opts)
 	}
 
-	generations := make([]*llms.Generation, 0, len(prompts))
+	if err := o.checkOptions(opts, o.SupportedOptions()); err != nil {
+		return nil, err
+	}
+
+	generations := make([]*llms.Generation, 0, len(prompts)*max(opts.N, 1))
 	for _, prompt := range prompts {
-		result, err := o.client.CreateCompletion(ctx, &sparkclient.CompletionRequest{
-			Prompt:      prompt,
-			MaxTokens:   opts.MaxTokens,
-			Temperature: opts.Temperature,
-			N:           opts.N,
-			TopK:        int64(opts.TopK),
-			Functions:   opts.Functions,
-		})
+		choices, err := o.createChats(ctx, []messages.ChatMessage{messages.HumanChatMessage{Content: prompt}}, opts)
 		if err != nil {
 			if o.CallbacksHandler != nil {
 				o.CallbacksHandler.HandleLLMError(ctx, err)
 			}
 			return nil, err
 		}
-		chatRes := result.(*sparkclient.ChatResponse)
-
-		generations = append(generations, &llms.Generation{
-			Text: result.GetContent(),
-			Message: &messages.AIChatMessage{
-				Content:      chatRes.GetContent(),
-				FunctionCall: chatRes.FunctionCall,
-			},
-		})
+
+		for _, chatRes := range choices {
+			usage := usageOf(chatRes)
+			generations = append(generations, &llms.Generation{
+				Text: chatRes.GetContent(),
+				Message: &messages.AIChatMessage{
+					Content:      chatRes.GetContent(),
+					FunctionCall: chatRes.FunctionCall,
+					Usage:        usage,
+				},
+				GenerationInfo: generationInfo(chatRes),
+				StopReason:     chatRes.FinishReason,
+			})
+		}
 	}
 
-	//if o.CallbacksHandler != nil {
-	//	o.CallbacksHandler.HandleLLMEnd(ctx, llms.LLMResult{Generations: [][]*llms.Generation{generations}})
-	//}
+	if o.CallbacksHandler != nil {
+		o.CallbacksHandler.HandleLLMEnd(ctx, llms.NewLLMResult(generations))
+	}
 
 	return generations, nil
 }
 
+// GenerateContent implements the Model interface.
+//
+//nolint:goerr113
+func (o *LLM) GenerateContent(ctx context.Context, msgs []messages.MessageContent, options ...llms.CallOption) (*messages.ContentResponse, error) { //nolint: lll
+	if o.CallbacksHandler != nil {
+		o.CallbacksHandler.HandleLLMGenerateContentStart(ctx, msgs)
+	}
+
+	opts := llms.CallOptions{}
+	for _, opt := range options {
+		opt(&opts)
+	}
+
+	if err := o.checkOptions(opts, o.SupportedOptions()); err != nil {
+		return nil, err
+	}
+	chatMsgs, err := o.toChatMessages(ctx, msgs)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := o.createChats(ctx, chatMsgs, opts)
+	if err != nil {
+		if o.CallbacksHandler != nil {
+			o.CallbacksHandler.HandleLLMError(ctx, err)
+		}
+		return nil, err
+	}
+
+	choices := make([]*messages.ContentChoice, 0, len(results))
+	for _, chatRes := range results {
+		choices = append(choices, &messages.ContentChoice{
+			Content:        chatRes.GetContent(),
+			StopReason:     chatRes.FinishReason,
+			GenerationInfo: generationInfo(chatRes),
+			FuncCall:       chatRes.FunctionCall,
+			ToolCalls:      toolCalls(chatRes),
+		})
+	}
+	response := &messages.ContentResponse{Choices: choices, Usage: totalUsage(results)}
+
+	if o.CallbacksHandler != nil {
+		o.CallbacksHandler.HandleLLMGenerateContentEnd(ctx, response)
+	}
+
+	return response, nil
+}
+
+// toChatMessages converts the messages to the Spark format. Text parts are
+// concatenated, image parts become image messages placed before the text of
+// their message. Spark calls one function per message: a tool call becomes
+// the function call of an assistant message and a tool result a function
+// message, named after the function called.
+//
+//nolint:goerr113
+func (o *LLM) toChatMessages(ctx context.Context, msgs []messages.MessageContent) ([]messages.ChatMessage, error) { //nolint: lll
+	chatMsgs := make([]messages.ChatMessage, 0, len(msgs))
+	// lastCall 是最近一次调用的函数, 用于命名没有名字的函数结果
+	lastCall := ""
+	for i, mc := range msgs {
+		var text strings.Builder
+		var call *messages.FunctionCall
+		var results []messages.ChatMessage
+		hasImage := false
+		for _, part := range mc.Parts {
+			switch p := part.(type) {
+			case messages.TextContent:
+				text.WriteString(p.Text)
+			case messages.ToolCall:
+				if call != nil {
+					return nil, fmt.Errorf("spark calls at most one function per message, got several tool calls")
+				}
+				if p.FunctionCall == nil {
+					return nil, fmt.Errorf("tool call %q has no function", p.ID)
+				}
+				call = p.FunctionCall
+			case messages.ToolCallResponse:
+				name := p.Name
+				if name == "" {
+					name = lastCall
+				}
+				results = append(results, messages.FunctionChatMessage{Name: name, Content: p.Content})
+			case messages.ImageURLContent, messages.BinaryContent:
+				if o.model != nil && !o.model.Features.Images {
+					return nil, fmt.Errorf("%w: %s", ErrImagesNotSupported, o.model.Name)
+				}
+				// 星火图片理解要求图片作为对话的第一条消息
+				if i != 0 || mc.Role != messages.ChatMessageTypeHuman {
+					return nil, fmt.Errorf("images must be sent in the first message of the conversation, as human")
+				}
+				img, err := o.imageMessage(ctx, part)
+				if err != nil {
+					return nil, err
+				}
+				chatMsgs = append(chatMsgs, img)
+				hasImage = true
+			default:
+				return nil, fmt.Errorf("content part %T not supported", part)
+			}
+		}
+		if call != nil && mc.Role != messages.ChatMessageTypeAI {
+			return nil, fmt.Errorf("tool calls must be sent as ai, not %v", mc.Role)
+		}
+		if len(results) > 0 {
+			chatMsgs = append(chatMsgs, results...)
+			continue
+		}
+		if hasImage && text.Len() == 0 {
+			// 只有图片的消息
+			continue
+		}
+		// 星火角色: system / user / assistant / function
+		switch mc.Role {
+		case messages.ChatMessageTypeSystem:
+			chatMsgs = append(chatMsgs, messages.SystemChatMessage{Content: text.String()})
+		case messages.ChatMessageTypeAI:
+			if call != nil {
+				lastCall = call.Name
+			}
+			chatMsgs = append(chatMsgs, messages.AIChatMessage{Content: text.String(), FunctionCall: call})
+		case messages.ChatMessageTypeHuman, messages.ChatMessageTypeGeneric:
+			chatMsgs = append(chatMsgs, messages.HumanChatMessage{Content: text.String()})
+		case messages.ChatMessageTypeFunction, messages.ChatMessageTypeTool:
+			chatMsgs = append(chatMsgs, messages.FunctionChatMessage{Name: lastCall, Content: text.String()})
+		default:
+			return nil, fmt.Errorf("role %v not supported", mc.Role)
+		}
+	}
+	return chatMsgs, nil
+}
+
+// createChat runs a chat session over msgs, streaming the text deltas to
+// opts.StreamingFunc when set.
+func (o *LLM) createChat(ctx context.Context, msgs []messages.ChatMessage, opts llms.CallOptions) (*sparkclient.ChatResponse, error) { //nolint: lll
+	msgs, err := withResponseFormat(msgs, opts.ResponseFormat)
+	if err != nil {
+		return nil, err
+	}
+	topK := int64(opts.TopK)
+	req := &sparkclient.ChatRequest{
+		Domain:      &o.domain,
+		Messages:    msgs,
+		Temperature: &opts.Temperature,
+		TopK:        &topK,
+		MaxTokens:   &opts.MaxTokens,
+		Functions:   opts.Functions,
+		Uid:         &opts.UserID,
+		ChatId:      &opts.ChatID,
+		Audit:       &opts.Auditing,
+		WebSearch:   webSearch(opts.WebSearch),
+	}
+
+	var filter *stopWordFilter
+	if len(opts.StopWords) > 0 {
+		// 星火不支持停止词, 在客户端截断流式输出并中断会话
+		filter = newStopWordFilter(opts.StopWords)
+	}
+	stream := func(text string) error {
+		if text == "" || opts.StreamingFunc == nil {
+			return nil
+		}
+		return opts.StreamingFunc(ctx, []byte(text))
+	}
+
+	var streamCb func(msg messages.ChatMessage) error
+	if opts.StreamingFunc != nil || filter != nil {
+		streamCb = func(msg messages.ChatMessage) error {
+			// 函数调用帧不作为文本输出
+			if ai, ok := msg.(messages.AIChatMessage); ok && ai.FunctionCall != nil {
+				return nil
+			}
+			text := msg.GetContent()
+			if filter != nil {
+				var stop bool
+				if text, stop = filter.write(text); stop {
+					if err := stream(text); err != nil {
+						return err
+					}
+					return errStopWord
+				}
+			}
+			return stream(text)
+		}
+	}
+	result, err := o.client.CreateChatWithCallBack(ctx, req, streamCb)
+	if filter != nil && errors.Is(err, errStopWord) {
+		return &sparkclient.ChatResponse{
+			Role:         protocol.RoleAssistant,
+			Content:      filter.text.String(),
+			FinishReason: messages.FinishReasonStop,
+		}, nil
+	}
+	var apiErr *sparkclient.APIError
+	if errors.As(err, &apiErr) && apiErr.OutputFiltered() {
+		// 输出内容审核不通过, 按 content_filter 结束而不是报错
+		return &sparkclient.ChatResponse{
+			Role:         protocol.RoleAssistant,
+			Sid:          apiErr.Sid,
+			Status:       protocol.StatusLast,
+			FinishReason: messages.FinishReasonContentFilter,
+		}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if filter != nil {
+		if err := stream(filter.flush()); err != nil {
+			return nil, err
+		}
+	}
+	chatRes := result.(*sparkclient.ChatResponse)
+	if opts.ResponseFormat.IsJSON() {
+		chatRes.Content = llms.ExtractJSON(chatRes.Content)
+	}
+	return chatRes, nil
+}
+
+// checkOptions reports the options Spark does not support, as an error in
+// strict mode and as a warning otherwise.
+func (o *LLM) checkOptions(opts llms.CallOptions, supported []llms.OptionName) error {
+	err := llms.CheckOptions("spark", opts, supported)
+	if err == nil || o.strictOptions {
+		return err
+	}
+	log.Logger.Warn(err.Error())
+	return nil
+}
+
+// SupportedOptions implements llms.OptionSupporter. StopWords are emulated
+// by truncating the streamed output, ResponseFormat by a system instruction.
+func (o *LLM) SupportedOptions() []llms.OptionName {
+	return []llms.OptionName{
+		llms.OptionMaxTokens,
+		llms.OptionTemperature,
+		llms.OptionStopWords,
+		llms.OptionStreamingFunc,
+		llms.OptionTopK,
+		llms.OptionN,
+		llms.OptionFunctions,
+		llms.OptionUserID,
+		llms.OptionChatID,
+		llms.OptionAuditing,
+		llms.OptionWebSearch,
+		llms.OptionResponseFormat,
+	}
+}
+
+func webSearch(ws *llms.WebSearch) *sparkclient.WebSearch {
+	if ws == nil {
+		return nil
+	}
+	return &sparkclient.WebSearch{
+		Enable:       ws.Enable,
+		ShowRefLabel: ws.ShowRefLabel,
+		SearchMode:   ws.SearchMode,
+	}
+}
+
 //// CreateEmbedding creates embeddings for the given input texts.
 //func (o *LLM) CreateEmbedding(ctx context.Context, inputTexts []string) ([][]float32, error) {
 //	embeddings, err := o.client.CreateEmbedding(ctx, &sparkclient.EmbeddingRequest{
@@ -1,8 +1,13 @@
 package spark
 
 import (
+	"crypto/tls"
+	"time"
+
+	"github.com/gorilla/websocket"
 	"github.com/iflytek/spark-ai-go/sparkai/callbacks"
 	"github.com/iflytek/spark-ai-go/sparkai/llms/spark/client/sparkclient"
+	"github.com/iflytek/spark-ai-go/sparkai/retry"
 )
 
 const (
@@ -12,7 +17,8 @@ const (
 	SparkDomainEnvVarName  = "SPARKAI_DOMAIN"
 	sparkVersionEnvVarName = "SPARKAI_API_VERSION" //nolint:gosec
 	BaseURLEnvVarName      = "SPARKAI_URL"         //nolint:gosec
-	organizationEnvVarName = "SPARK_ORGANIZATION"  //nolint:gosec
+	ModelEnvVarName        = "SPARKAI_MODEL"
+	organizationEnvVarName = "SPARK_ORGANIZATION" //nolint:gosec
 )
 
 const (
@@ -32,7 +38,19 @@ type options struct {
 	apiVersion     string
 	embeddingModel string
 
+	// model is the logical model name, resolved through the sparkclient registry.
+	model     string
+	modelInfo *sparkclient.Model
+
 	callbackHandler callbacks.Handler
+	retryPolicy     *retry.Policy
+	maxConcurrency  int
+	strictOptions   bool
+	// choiceTemperatures are the temperatures of the choices when N > 1.
+	choiceTemperatures []float64
+
+	// clientOptions are passed through to sparkclient.New.
+	clientOptions []sparkclient.Option
 }
 
 type Option func(*options)
@@ -69,6 +87,17 @@ func WithAPIDomain(domain string) Option {
 	}
 }
 
+// WithModel selects a model of the sparkclient registry by its logical name,
+// e.g. spark-lite, spark-pro, spark-max or spark-4.0-ultra. The endpoint URL,
+// domain and API version are derived from it unless given explicitly, in
+// which case they must match the model. If not set, the model is read from
+// the SPARKAI_MODEL environment variable.
+func WithModel(model string) Option {
+	return func(opts *options) {
+		opts.model = model
+	}
+}
+
 // WithEmbeddingModel passes the SPARK model to the client. Required when ApiType is Azure.
 func WithEmbeddingModel(embeddingModel string) Option {
 	return func(opts *options) {
@@ -109,9 +138,103 @@ func WithHTTPClient(client sparkclient.Doer) Option {
 	}
 }
 
-//// WithCallback allows setting a custom Callback Handler.
-//func WithCallback(callbackHandler callbacks.Handler) Option {
-//	return func(opts *options) {
-//		opts.callbackHandler = callbackHandler
-//	}
-//}
+// WithHandshakeTimeout sets the maximum duration of the websocket handshake.
+func WithHandshakeTimeout(timeout time.Duration) Option {
+	return func(opts *options) {
+		opts.clientOptions = append(opts.clientOptions, sparkclient.WithHandshakeTimeout(timeout))
+	}
+}
+
+// WithReadTimeout sets the maximum idle time allowed between two frames of a
+// chat session. Zero disables the idle timeout.
+func WithReadTimeout(timeout time.Duration) Option {
+	return func(opts *options) {
+		opts.clientOptions = append(opts.clientOptions, sparkclient.WithReadTimeout(timeout))
+	}
+}
+
+// WithWriteTimeout sets the maximum duration for sending the request frame.
+func WithWriteTimeout(timeout time.Duration) Option {
+	return func(opts *options) {
+		opts.clientOptions = append(opts.clientOptions, sparkclient.WithWriteTimeout(timeout))
+	}
+}
+
+// WithDialer sets the websocket dialer used to connect to Spark.
+func WithDialer(dialer *websocket.Dialer) Option {
+	return func(opts *options) {
+		opts.clientOptions = append(opts.clientOptions, sparkclient.WithDialer(dialer))
+	}
+}
+
+// WithProxy routes the websocket connections through an HTTP or SOCKS5 proxy.
+// If not set, the proxy is read from the HTTPS_PROXY and NO_PROXY environment variables.
+func WithProxy(proxyURL string) Option {
+	return func(opts *options) {
+		opts.clientOptions = append(opts.clientOptions, sparkclient.WithProxy(proxyURL))
+	}
+}
+
+// WithTLSConfig sets the TLS configuration used for wss:// connections.
+func WithTLSConfig(config *tls.Config) Option {
+	return func(opts *options) {
+		opts.clientOptions = append(opts.clientOptions, sparkclient.WithTLSConfig(config))
+	}
+}
+
+// WithConnManager makes the client dial through a connection manager, which
+// may be shared between LLMs to bound the concurrent sessions of an app_id.
+func WithConnManager(m *sparkclient.ConnManager) Option {
+	return func(opts *options) {
+		opts.clientOptions = append(opts.clientOptions, sparkclient.WithConnManager(m))
+	}
+}
+
+// WithLimiter makes the LLM wait for the given client-side limiter before each
+// session, see sparkclient.NewLimiter. LLMs sharing an app_id should share it.
+func WithLimiter(l *sparkclient.Limiter) Option {
+	return func(opts *options) {
+		opts.clientOptions = append(opts.clientOptions, sparkclient.WithLimiter(l))
+	}
+}
+
+// WithRetryPolicy sets the policy used to retry failed chat sessions, see
+// retry.DefaultPolicy. Retries are reported to the callback handler when it
+// implements callbacks.RetryHandler.
+func WithRetryPolicy(policy retry.Policy) Option {
+	return func(opts *options) {
+		opts.retryPolicy = &policy
+	}
+}
+
+// WithCallback allows setting a custom Callback Handler.
+func WithCallback(callbackHandler callbacks.Handler) Option {
+	return func(opts *options) {
+		opts.callbackHandler = callbackHandler
+	}
+}
+
+// WithMaxConcurrency bounds the number of conversations Chat.Generate runs in
+// parallel, llms.DefaultMaxConcurrency when not set.
+func WithMaxConcurrency(n int) Option {
+	return func(opts *options) {
+		opts.maxConcurrency = n
+	}
+}
+
+// WithStrictOptions makes the calls fail with llms.ErrUnsupportedOption when
+// they set call options Spark does not support, such as TopP or Seed. By
+// default these options are ignored and logged.
+func WithStrictOptions(strict bool) Option {
+	return func(opts *options) {
+		opts.strictOptions = strict
+	}
+}
+
+// WithChoiceTemperatures varies the temperature of the choices generated when
+// llms.WithN asks for more than one: choice i uses temperatures[i%len(temperatures)].
+func WithChoiceTemperatures(temperatures ...float64) Option {
+	return func(opts *options) {
+		opts.choiceTemperatures = temperatures
+	}
+}
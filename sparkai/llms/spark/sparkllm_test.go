@@ -0,0 +1,720 @@
+package spark
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/iflytek/spark-ai-go/sparkai/callbacks"
+	"github.com/iflytek/spark-ai-go/sparkai/llms"
+	"github.com/iflytek/spark-ai-go/sparkai/llms/spark/client/sparkclient"
+	"github.com/iflytek/spark-ai-go/sparkai/messages"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSpark is a local Spark stand-in recording the request frames.
+type fakeSpark struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []map[string]any
+}
+
+// newFakeSpark starts a fake Spark server answering every session with content.
+func newFakeSpark(t *testing.T, content string) *fakeSpark {
+	t.Helper()
+	return newFakeSparkFrames(t, fmt.Sprintf(`{"header":{"code":0,"message":"Success","sid":"cht000test","status":2},`+
+		`"payload":{"choices":{"status":2,"seq":0,"text":[{"content":%q,"role":"assistant","index":0}]},`+
+		`"usage":{"text":{"prompt_tokens":5,"completion_tokens":9,"total_tokens":14}}}}`, content))
+}
+
+// newFakeSparkFrames starts a fake Spark server answering every session with frames.
+func newFakeSparkFrames(t *testing.T, frames ...string) *fakeSpark {
+	t.Helper()
+	f := &fakeSpark{}
+	upgrader := websocket.Upgrader{}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var req map[string]any
+		if err := conn.ReadJSON(&req); err != nil {
+			return
+		}
+		f.mu.Lock()
+		f.requests = append(f.requests, req)
+		f.mu.Unlock()
+		for _, frame := range frames {
+			if err := conn.WriteMessage(websocket.TextMessage, []byte(frame)); err != nil {
+				return
+			}
+		}
+		_, _, _ = conn.ReadMessage()
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+// lastRequest returns the last request frame received.
+func (f *fakeSpark) lastRequest(t *testing.T) map[string]any {
+	t.Helper()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	require.NotEmpty(t, f.requests)
+	return f.requests[len(f.requests)-1]
+}
+
+func newTestLLM(t *testing.T, f *fakeSpark, opts ...Option) *LLM {
+	t.Helper()
+	llm, err := New(append([]Option{
+		WithBaseURL("ws" + strings.TrimPrefix(f.URL, "http") + "/v3.5/chat"),
+		WithApiKey("key"),
+		WithApiSecret("secret"),
+		WithAppId("appid"),
+		WithAPIDomain("generalv3.5"),
+	}, opts...)...)
+	require.NoError(t, err)
+	return llm
+}
+
+func TestGenerateRequestParams(t *testing.T) {
+	t.Parallel()
+	f := newFakeSpark(t, "Hello")
+	llm := newTestLLM(t, f)
+
+	_, err := llm.Call(context.Background(), "hi",
+		llms.WithTemperature(0.5),
+		llms.WithTopK(3),
+		llms.WithMaxTokens(512),
+		llms.WithUserID("user-1"),
+		llms.WithChatID("chat-1"),
+		llms.WithAuditing("strict"),
+		llms.WithWebSearch(llms.WebSearch{Enable: true, ShowRefLabel: true, SearchMode: "deep"}),
+	)
+	require.NoError(t, err)
+
+	got, err := json.Marshal(f.lastRequest(t)["parameter"])
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"chat":{
+		"domain":"generalv3.5","temperature":0.5,"top_k":3,"max_tokens":512,
+		"auditing":"strict","chat_id":"chat-1",
+		"tools":[{"type":"web_search","web_search":{"enable":true,"show_ref_label":true,"search_mode":"deep"}}]
+	}}`, string(got))
+	assert.Equal(t, map[string]any{"app_id": "appid", "uid": "user-1"}, f.lastRequest(t)["header"])
+}
+
+// unsetSparkEnv clears the Spark environment variables for the duration of the test.
+func unsetSparkEnv(t *testing.T) {
+	t.Helper()
+	for _, key := range []string{
+		AppIdEnvVarName, ApiKeyEnvVarName, ApiSecretEnvVarName,
+		SparkDomainEnvVarName, BaseURLEnvVarName, ModelEnvVarName,
+	} {
+		t.Setenv(key, "")
+	}
+}
+
+func TestNewClientWithModel(t *testing.T) {
+	unsetSparkEnv(t)
+	credentials := []Option{WithApiKey("key"), WithApiSecret("secret"), WithAppId("appid")}
+
+	opts, _, err := NewClient(append(credentials, WithModel("spark-lite"))...)
+	require.NoError(t, err)
+	assert.Equal(t, "wss://spark-api.xf-yun.com/v1.1/chat", opts.baseURL)
+	assert.Equal(t, "lite", opts.domain)
+	assert.Equal(t, "v1.1", opts.apiVersion)
+
+	// 显式指定的 url 必须与模型一致
+	opts, _, err = NewClient(append(credentials, WithModel("spark-max"), WithBaseURL("ws://127.0.0.1/v3.5/chat"))...)
+	require.NoError(t, err)
+	assert.Equal(t, "ws://127.0.0.1/v3.5/chat", opts.baseURL)
+	_, _, err = NewClient(append(credentials, WithModel("spark-max"), WithBaseURL("wss://spark-api.xf-yun.com/v1.1/chat"))...)
+	require.Error(t, err)
+
+	_, _, err = NewClient(append(credentials, WithModel("spark-unknown"))...)
+	require.ErrorIs(t, err, sparkclient.ErrUnknownModel)
+
+	// 环境变量中的模型
+	t.Setenv(ModelEnvVarName, "spark-4.0-ultra")
+	t.Setenv(BaseURLEnvVarName, "wss://spark-api.xf-yun.com/v3.5/chat")
+	opts, _, err = NewClient(credentials...)
+	require.NoError(t, err)
+	assert.Equal(t, "4.0Ultra", opts.domain)
+	assert.Equal(t, "wss://spark-api.xf-yun.com/v4.0/chat", opts.baseURL)
+}
+
+func TestNewClientInfersDomain(t *testing.T) {
+	unsetSparkEnv(t)
+	credentials := []Option{WithApiKey("key"), WithApiSecret("secret"), WithAppId("appid")}
+
+	opts, _, err := NewClient(credentials...)
+	require.NoError(t, err)
+	assert.Equal(t, DefaultSparkUrl, opts.baseURL)
+	assert.Equal(t, "generalv3.5", opts.domain)
+
+	opts, _, err = NewClient(append(credentials, WithBaseURL("wss://spark-api.xf-yun.com/v3.1/chat"))...)
+	require.NoError(t, err)
+	assert.Equal(t, "generalv3", opts.domain)
+
+	_, _, err = NewClient(append(credentials, WithBaseURL("wss://example.com/custom"))...)
+	require.ErrorIs(t, err, ErrMissingDomain)
+}
+
+func TestGenerateContent(t *testing.T) {
+	t.Parallel()
+	f := newFakeSpark(t, "Hello")
+	llm := newTestLLM(t, f)
+
+	resp, err := llm.GenerateContent(context.Background(), []messages.MessageContent{
+		messages.TextParts(messages.ChatMessageTypeSystem, "You are ", "a helpful assistant."),
+		messages.TextParts(messages.ChatMessageTypeHuman, "hi"),
+		messages.TextParts(messages.ChatMessageTypeAI, "Hello, how can I help?"),
+		messages.TextParts(messages.ChatMessageTypeFunction, `{"temperature":20}`),
+		messages.TextParts(messages.ChatMessageTypeGeneric, "thanks"),
+	}, llms.WithFunctions([]messages.FunctionDefinition{{Name: "get_weather", Description: "weather"}}))
+	require.NoError(t, err)
+	require.Len(t, resp.Choices, 1)
+	assert.Equal(t, "Hello", resp.Choices[0].Content)
+	assert.Nil(t, resp.Choices[0].FuncCall)
+	assert.Equal(t, 14, resp.Choices[0].GenerationInfo["TotalTokens"])
+
+	got, err := json.Marshal(f.lastRequest(t)["payload"])
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"message":{"text":[
+			{"role":"system","content":"You are a helpful assistant."},
+			{"role":"user","content":"hi"},
+			{"role":"assistant","content":"Hello, how can I help?"},
+			{"role":"function","content":"{\"temperature\":20}"},
+			{"role":"user","content":"thanks"}
+		]},
+		"functions":{"text":[{"name":"get_weather","description":"weather","parameters":null}]}
+	}`, string(got))
+	assert.Equal(t, "generalv3.5", f.lastRequest(t)["parameter"].(map[string]any)["chat"].(map[string]any)["domain"])
+}
+
+func TestGenerateContentInfo(t *testing.T) {
+	t.Parallel()
+	f := newFakeSpark(t, "Hello")
+	llm := newTestLLM(t, f)
+
+	want := messages.GenerationInfo{
+		Usage:        messages.Usage{PromptTokens: 5, CompletionTokens: 9, TotalTokens: 14},
+		Sid:          "cht000test",
+		Status:       2,
+		FinishReason: messages.FinishReasonStop,
+	}
+	resp, err := llm.GenerateContent(context.Background(),
+		[]messages.MessageContent{messages.TextParts(messages.ChatMessageTypeHuman, "hi")})
+	require.NoError(t, err)
+	assert.Equal(t, want, resp.Choices[0].Info())
+
+	result, err := llm.Generate(context.Background(), []string{"hi"})
+	require.NoError(t, err)
+	assert.Equal(t, want, result[0].Info())
+	assert.Equal(t, &want.Usage, result[0].Message.Usage)
+}
+
+func TestGenerateContentOutputFiltered(t *testing.T) {
+	t.Parallel()
+	f := newFakeSparkFrames(t,
+		textFrame(0, 0, "Hel"),
+		`{"header":{"code":10014,"message":"output content audit failed","sid":"cht000test","status":2}}`,
+	)
+	llm := newTestLLM(t, f)
+
+	resp, err := llm.GenerateContent(context.Background(),
+		[]messages.MessageContent{messages.TextParts(messages.ChatMessageTypeHuman, "hi")})
+	require.NoError(t, err)
+	info := resp.Choices[0].Info()
+	assert.Equal(t, messages.FinishReasonContentFilter, info.FinishReason)
+	assert.Equal(t, "cht000test", info.Sid)
+	assert.Empty(t, resp.Choices[0].Content)
+}
+
+func TestGenerateContentStreaming(t *testing.T) {
+	t.Parallel()
+	f := newFakeSparkFrames(t,
+		`{"header":{"code":0,"message":"Success","sid":"cht000test","status":0},`+
+			`"payload":{"choices":{"status":0,"seq":0,"text":[{"content":"Hel","role":"assistant","index":0}]}}}`,
+		`{"header":{"code":0,"message":"Success","sid":"cht000test","status":2},`+
+			`"payload":{"choices":{"status":2,"seq":1,"text":[{"content":"lo","role":"assistant","index":0}]},`+
+			`"usage":{"text":{"prompt_tokens":5,"completion_tokens":9,"total_tokens":14}}}}`,
+	)
+	llm := newTestLLM(t, f)
+
+	var chunks []string
+	resp, err := llm.GenerateContent(context.Background(),
+		[]messages.MessageContent{messages.TextParts(messages.ChatMessageTypeHuman, "hi")},
+		llms.WithStreamingFunc(func(_ context.Context, chunk []byte) error {
+			chunks = append(chunks, string(chunk))
+			return nil
+		}))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Hel", "lo"}, chunks)
+	assert.Equal(t, "Hello", resp.Choices[0].Content)
+}
+
+func TestGenerateContentFunctionCall(t *testing.T) {
+	t.Parallel()
+	f := newFakeSparkFrames(t, `{"header":{"code":0,"message":"Success","sid":"cht000test","status":2},`+
+		`"payload":{"choices":{"status":2,"seq":0,"text":[{"content":"","role":"assistant","index":0,`+
+		`"function_call":{"name":"get_weather","arguments":"{\"location\":\"合肥\"}"}}]},`+
+		`"usage":{"text":{"prompt_tokens":5,"completion_tokens":9,"total_tokens":14}}}}`)
+	llm := newTestLLM(t, f)
+
+	var streamed int
+	resp, err := llm.GenerateContent(context.Background(),
+		[]messages.MessageContent{messages.TextParts(messages.ChatMessageTypeHuman, "合肥天气怎么样")},
+		llms.WithFunctions([]messages.FunctionDefinition{{Name: "get_weather"}}),
+		llms.WithStreamingFunc(func(context.Context, []byte) error {
+			streamed++
+			return nil
+		}))
+	require.NoError(t, err)
+	require.NotNil(t, resp.Choices[0].FuncCall)
+	assert.Equal(t, "get_weather", resp.Choices[0].FuncCall.Name)
+	assert.Equal(t, `{"location":"合肥"}`, resp.Choices[0].FuncCall.Arguments)
+	assert.Zero(t, streamed)
+}
+
+func TestGenerateContentUnsupported(t *testing.T) {
+	t.Parallel()
+	f := newFakeSpark(t, "Hello")
+	llm := newTestLLM(t, f)
+
+	_, err := llm.GenerateContent(context.Background(), []messages.MessageContent{
+		{Role: messages.ChatMessageTypeHuman, Parts: []messages.ContentPart{messages.ImageURLPart("https://example.com/a.png")}},
+	})
+	require.ErrorIs(t, err, ErrImagesNotSupported)
+	_, err = llm.GenerateContent(context.Background(), []messages.MessageContent{
+		messages.TextParts("robot", "hi"),
+	})
+	require.Error(t, err)
+}
+
+func TestGenerateContentToolCallRoundTrip(t *testing.T) {
+	t.Parallel()
+	call := newFakeSparkFrames(t, `{"header":{"code":0,"message":"Success","sid":"cht000call","status":2},`+
+		`"payload":{"choices":{"status":2,"seq":0,"text":[{"content":"","role":"assistant","index":0,`+
+		`"function_call":{"name":"get_weather","arguments":"{\"location\":\"合肥\"}"}}]},`+
+		`"usage":{"text":{"prompt_tokens":5,"completion_tokens":9,"total_tokens":14}}}}`)
+	history := []messages.MessageContent{messages.TextParts(messages.ChatMessageTypeHuman, "合肥天气怎么样")}
+
+	resp, err := newTestLLM(t, call).GenerateContent(context.Background(), history,
+		llms.WithFunctions([]messages.FunctionDefinition{{Name: "get_weather"}}))
+	require.NoError(t, err)
+	require.Len(t, resp.Choices[0].ToolCalls, 1)
+	toolCall := resp.Choices[0].ToolCalls[0]
+	assert.Equal(t, "call_cht000call", toolCall.ID)
+	assert.Equal(t, resp.Choices[0].FuncCall, toolCall.FunctionCall)
+
+	// 调用 -> 结果 -> 回答
+	history = append(history,
+		messages.MessageContent{Role: messages.ChatMessageTypeAI, Parts: []messages.ContentPart{toolCall}},
+		messages.MessageContent{Role: messages.ChatMessageTypeTool, Parts: []messages.ContentPart{
+			messages.ToolCallResponse{ToolCallID: toolCall.ID, Name: "get_weather", Content: "晴"},
+		}},
+	)
+	answer := newFakeSpark(t, "合肥今天晴")
+	llm := newTestLLM(t, answer)
+	resp, err = llm.GenerateContent(context.Background(), history)
+	require.NoError(t, err)
+	assert.Equal(t, "合肥今天晴", resp.Choices[0].Content)
+
+	expected := `{"text":[
+		{"role":"user","content":"合肥天气怎么样"},
+		{"role":"assistant","content":"","function_call":{"name":"get_weather","arguments":"{\"location\":\"合肥\"}"}},
+		{"role":"function","content":"晴","name":"get_weather"}
+	]}`
+	got, err := json.Marshal(answer.lastRequest(t)["payload"].(map[string]any)["message"])
+	require.NoError(t, err)
+	assert.JSONEq(t, expected, string(got))
+
+	// 函数角色的文本结果以最近调用的函数命名
+	history[2] = messages.TextParts(messages.ChatMessageTypeFunction, "晴")
+	_, err = llm.GenerateContent(context.Background(), history)
+	require.NoError(t, err)
+	got, err = json.Marshal(answer.lastRequest(t)["payload"].(map[string]any)["message"])
+	require.NoError(t, err)
+	assert.JSONEq(t, expected, string(got))
+
+	_, err = llm.GenerateContent(context.Background(), []messages.MessageContent{
+		{Role: messages.ChatMessageTypeAI, Parts: []messages.ContentPart{toolCall, toolCall}},
+	})
+	require.ErrorContains(t, err, "one function")
+}
+
+func TestChatCall(t *testing.T) {
+	t.Parallel()
+	f := newFakeSpark(t, "合肥今天晴")
+	chat, err := NewChat(
+		WithBaseURL("ws"+strings.TrimPrefix(f.URL, "http")+"/v3.5/chat"),
+		WithApiKey("key"), WithApiSecret("secret"), WithAppId("appid"), WithAPIDomain("generalv3.5"),
+	)
+	require.NoError(t, err)
+
+	msg, err := chat.Call(context.Background(), []messages.ChatMessage{
+		messages.SystemChatMessage{Content: "你是天气助手"},
+		messages.HumanChatMessage{Content: "合肥天气怎么样"},
+		messages.AIChatMessage{FunctionCall: &messages.FunctionCall{Name: "get_weather", Arguments: `{"location":"合肥"}`}},
+		messages.FunctionChatMessage{Name: "get_weather", Content: `{"weather":"晴"}`},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "合肥今天晴", msg.Content)
+	assert.Equal(t, &messages.Usage{PromptTokens: 5, CompletionTokens: 9, TotalTokens: 14}, msg.Usage)
+
+	got, err := json.Marshal(f.lastRequest(t)["payload"].(map[string]any)["message"])
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"text":[
+		{"role":"system","content":"你是天气助手"},
+		{"role":"user","content":"合肥天气怎么样"},
+		{"role":"assistant","content":"","function_call":{"name":"get_weather","arguments":"{\"location\":\"合肥\"}"}},
+		{"role":"function","content":"{\"weather\":\"晴\"}","name":"get_weather"}
+	]}`, string(got))
+}
+
+func TestChatGenerate(t *testing.T) {
+	t.Parallel()
+	f := newFakeSpark(t, "Hello")
+	chat, err := NewChat(
+		WithBaseURL("ws"+strings.TrimPrefix(f.URL, "http")+"/v3.5/chat"),
+		WithApiKey("key"), WithApiSecret("secret"), WithAppId("appid"), WithAPIDomain("generalv3.5"),
+		WithMaxConcurrency(2),
+	)
+	require.NoError(t, err)
+
+	conversations := make([][]messages.ChatMessage, 5)
+	for i := range conversations {
+		conversations[i] = []messages.ChatMessage{messages.HumanChatMessage{Content: fmt.Sprint(i)}}
+	}
+	generations, err := chat.Generate(context.Background(), conversations)
+	require.NoError(t, err)
+	require.Len(t, generations, 5)
+	for _, g := range generations {
+		assert.Equal(t, "Hello", g.Text)
+		assert.Equal(t, 14, g.GenerationInfo["TotalTokens"])
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	assert.Len(t, f.requests, 5)
+}
+
+// endRecorder records the results of the HandleLLMEnd callbacks.
+type endRecorder struct {
+	callbacks.Handler
+	mu      sync.Mutex
+	results []llms.LLMResult
+}
+
+func (r *endRecorder) HandleLLMStart(context.Context, []string) {}
+
+func (r *endRecorder) HandleLLMEnd(_ context.Context, output llms.LLMResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.results = append(r.results, output)
+}
+
+func TestChatGenerateCallbackUsage(t *testing.T) {
+	t.Parallel()
+	f := newFakeSpark(t, "Hello")
+	handler := &endRecorder{}
+	opts := []Option{
+		WithBaseURL("ws" + strings.TrimPrefix(f.URL, "http") + "/v3.5/chat"),
+		WithApiKey("key"), WithApiSecret("secret"), WithAppId("appid"), WithAPIDomain("generalv3.5"),
+		WithCallback(handler),
+	}
+	chat, err := NewChat(opts...)
+	require.NoError(t, err)
+	llm, err := New(opts...)
+	require.NoError(t, err)
+
+	_, err = chat.Generate(context.Background(), [][]messages.ChatMessage{
+		{messages.HumanChatMessage{Content: "a"}},
+		{messages.HumanChatMessage{Content: "b"}},
+	})
+	require.NoError(t, err)
+	_, err = llm.Generate(context.Background(), []string{"a", "b"})
+	require.NoError(t, err)
+
+	// Chat 与 LLM 报告相同的用量
+	require.Len(t, handler.results, 2)
+	want := &messages.Usage{PromptTokens: 10, CompletionTokens: 18, TotalTokens: 28}
+	for _, result := range handler.results {
+		assert.Equal(t, want, result.LLMOutput["TokenUsage"])
+	}
+}
+
+func testPNG(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewGray(image.Rect(0, 0, 2, 2))))
+	return buf.Bytes()
+}
+
+func newTestImageLLM(t *testing.T, f *fakeSpark, opts ...Option) *LLM {
+	t.Helper()
+	llm, err := New(append([]Option{
+		WithBaseURL("ws" + strings.TrimPrefix(f.URL, "http") + "/v2.1/image"),
+		WithApiKey("key"),
+		WithApiSecret("secret"),
+		WithAppId("appid"),
+	}, opts...)...)
+	require.NoError(t, err)
+	return llm
+}
+
+func TestGenerateContentImage(t *testing.T) {
+	t.Parallel()
+	data := testPNG(t)
+	images := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/cat.png" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write(data)
+	}))
+	t.Cleanup(images.Close)
+	f := newFakeSpark(t, "一只猫")
+	llm := newTestImageLLM(t, f, WithHTTPClient(images.Client()))
+
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for _, part := range []messages.ContentPart{
+		messages.BinaryPart("image/png", data),
+		messages.ImageURLPart(images.URL + "/cat.png"),
+		messages.ImageURLPart("data:image/png;base64," + encoded),
+	} {
+		resp, err := llm.GenerateContent(context.Background(), []messages.MessageContent{
+			{Role: messages.ChatMessageTypeHuman, Parts: []messages.ContentPart{part, messages.TextPart("图片里有什么")}},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "一只猫", resp.Choices[0].Content)
+
+		got, err := json.Marshal(f.lastRequest(t)["payload"])
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"message":{"text":[
+			{"role":"user","content":"`+encoded+`","content_type":"image"},
+			{"role":"user","content":"图片里有什么"}
+		]}}`, string(got))
+		assert.Equal(t, "image", f.lastRequest(t)["parameter"].(map[string]any)["chat"].(map[string]any)["domain"])
+	}
+
+	_, err := llm.GenerateContent(context.Background(), []messages.MessageContent{
+		{Role: messages.ChatMessageTypeHuman, Parts: []messages.ContentPart{messages.ImageURLPart(images.URL + "/missing.png")}},
+	})
+	require.Error(t, err)
+}
+
+func TestGenerateContentImageLimits(t *testing.T) {
+	t.Parallel()
+	f := newFakeSpark(t, "一只猫")
+	llm := newTestImageLLM(t, f)
+
+	_, err := llm.GenerateContent(context.Background(), []messages.MessageContent{
+		{Role: messages.ChatMessageTypeHuman, Parts: []messages.ContentPart{messages.BinaryPart("image/gif", []byte("GIF89a......"))}},
+	})
+	require.ErrorIs(t, err, sparkclient.ErrImageFormat)
+
+	big := append(testPNG(t), make([]byte, sparkclient.MaxImageSize)...)
+	_, err = llm.GenerateContent(context.Background(), []messages.MessageContent{
+		{Role: messages.ChatMessageTypeHuman, Parts: []messages.ContentPart{messages.BinaryPart("image/png", big)}},
+	})
+	require.ErrorIs(t, err, sparkclient.ErrImageTooLarge)
+
+	// 图片必须位于第一条消息
+	_, err = llm.GenerateContent(context.Background(), []messages.MessageContent{
+		messages.TextParts(messages.ChatMessageTypeHuman, "你好"),
+		{Role: messages.ChatMessageTypeHuman, Parts: []messages.ContentPart{messages.BinaryPart("image/png", testPNG(t))}},
+	})
+	require.Error(t, err)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	assert.Empty(t, f.requests)
+}
+
+// textFrame returns a response frame carrying content.
+func textFrame(status, seq int, content string) string {
+	usage := ""
+	if status == 2 {
+		usage = `,"usage":{"text":{"prompt_tokens":5,"completion_tokens":9,"total_tokens":14}}`
+	}
+	return fmt.Sprintf(`{"header":{"code":0,"message":"Success","sid":"cht000test","status":%d},`+
+		`"payload":{"choices":{"status":%d,"seq":%d,"text":[{"content":%q,"role":"assistant","index":0}]}%s}}`,
+		status, status, seq, content, usage)
+}
+
+func TestGenerateStopWords(t *testing.T) {
+	t.Parallel()
+	f := newFakeSparkFrames(t,
+		textFrame(0, 0, "思考: 查询合肥天气\n观"),
+		textFrame(1, 1, "察: 晴\n"),
+		textFrame(2, 2, "最终答案: 晴"),
+	)
+	llm := newTestLLM(t, f)
+
+	var chunks []string
+	text, err := llm.Call(context.Background(), "合肥天气怎么样",
+		llms.WithStopWords([]string{"观察:"}),
+		llms.WithStreamingFunc(func(_ context.Context, chunk []byte) error {
+			chunks = append(chunks, string(chunk))
+			return nil
+		}))
+	require.NoError(t, err)
+	assert.Equal(t, "思考: 查询合肥天气\n", text)
+	assert.Equal(t, []string{"思考: 查询合肥天气\n"}, chunks)
+
+	result, err := llm.Generate(context.Background(), []string{"合肥天气怎么样"}, llms.WithStopWords([]string{"观察:"}))
+	require.NoError(t, err)
+	assert.Equal(t, messages.FinishReasonStop, result[0].StopReason)
+
+	// 未命中停止词时返回完整结果
+	text, err = llm.Call(context.Background(), "合肥天气怎么样", llms.WithStopWords([]string{"Observation:"}))
+	require.NoError(t, err)
+	assert.Equal(t, "思考: 查询合肥天气\n观察: 晴\n最终答案: 晴", text)
+}
+
+func TestStrictOptions(t *testing.T) {
+	t.Parallel()
+	f := newFakeSpark(t, "Hello")
+
+	_, err := newTestLLM(t, f).Call(context.Background(), "hi", llms.WithTopP(0.9), llms.WithSeed(42))
+	require.NoError(t, err)
+
+	strict := newTestLLM(t, f, WithStrictOptions(true))
+	_, err = strict.Call(context.Background(), "hi", llms.WithTopP(0.9), llms.WithSeed(42))
+	require.ErrorIs(t, err, llms.ErrUnsupportedOption)
+	assert.Contains(t, err.Error(), "top_p, seed")
+	_, err = strict.Call(context.Background(), "hi", llms.WithTemperature(0.5), llms.WithStopWords([]string{"\n"}))
+	require.NoError(t, err)
+}
+
+func TestGenerateContentN(t *testing.T) {
+	t.Parallel()
+	f := newFakeSpark(t, "Hello")
+	llm := newTestLLM(t, f, WithChoiceTemperatures(0.2, 0.9))
+
+	var streamed atomic.Int32
+	resp, err := llm.GenerateContent(context.Background(),
+		[]messages.MessageContent{messages.TextParts(messages.ChatMessageTypeHuman, "hi")},
+		llms.WithN(3),
+		llms.WithStreamingFunc(func(context.Context, []byte) error {
+			streamed.Add(1)
+			return nil
+		}))
+	require.NoError(t, err)
+	require.Len(t, resp.Choices, 3)
+	for _, c := range resp.Choices {
+		assert.Equal(t, "Hello", c.Content)
+		assert.Equal(t, 14, c.GenerationInfo["TotalTokens"])
+	}
+	assert.Equal(t, &messages.Usage{PromptTokens: 15, CompletionTokens: 27, TotalTokens: 42}, resp.Usage)
+	assert.Equal(t, int32(1), streamed.Load())
+
+	f.mu.Lock()
+	var temperatures []float64
+	for _, req := range f.requests {
+		temperatures = append(temperatures, req["parameter"].(map[string]any)["chat"].(map[string]any)["temperature"].(float64))
+	}
+	f.mu.Unlock()
+	assert.ElementsMatch(t, []float64{0.2, 0.9, 0.2}, temperatures)
+
+	generations, err := llm.Generate(context.Background(), []string{"hi", "hello"}, llms.WithN(2))
+	require.NoError(t, err)
+	assert.Len(t, generations, 4)
+	assert.Equal(t, &messages.Usage{PromptTokens: 5, CompletionTokens: 9, TotalTokens: 14}, generations[0].Message.Usage)
+}
+
+func TestGenerateContentNCancel(t *testing.T) {
+	t.Parallel()
+	var sessions atomic.Int32
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+		if sessions.Add(1) == 1 {
+			_ = conn.WriteMessage(websocket.TextMessage,
+				[]byte(`{"header":{"code":10013,"message":"input content audit failed","sid":"cht000test","status":2}}`))
+		}
+		// 其余会话不返回, 直到客户端关闭连接
+		_, _, _ = conn.ReadMessage()
+	}))
+	t.Cleanup(srv.Close)
+	llm := newTestLLM(t, &fakeSpark{Server: srv})
+
+	start := time.Now()
+	_, err := llm.GenerateContent(context.Background(),
+		[]messages.MessageContent{messages.TextParts(messages.ChatMessageTypeHuman, "hi")},
+		llms.WithN(3))
+	var apiErr *sparkclient.APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, 10013, apiErr.Code)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestGenerateJSONResponseFormat(t *testing.T) {
+	t.Parallel()
+	f := newFakeSpark(t, "好的:\n```json\n{\"city\":\"合肥\",\"days\":3}\n```")
+	llm := newTestLLM(t, f, WithStrictOptions(true))
+
+	schema := &llms.Schema{
+		Type:       "object",
+		Properties: map[string]*llms.Schema{"city": {Type: "string"}, "days": {Type: "integer"}},
+		Required:   []string{"city"},
+	}
+	got, err := llms.GenerateJSON[struct {
+		City string `json:"city"`
+		Days int    `json:"days"`
+	}](context.Background(), llm, []messages.MessageContent{
+		messages.TextParts(messages.ChatMessageTypeSystem, "你是天气助手."),
+		messages.TextParts(messages.ChatMessageTypeHuman, "合肥未来三天天气"),
+	}, 0, llms.WithJSONSchema("weather", schema))
+	require.NoError(t, err)
+	assert.Equal(t, "合肥", got.City)
+	assert.Equal(t, 3, got.Days)
+
+	text := f.lastRequest(t)["payload"].(map[string]any)["message"].(map[string]any)["text"].([]any)
+	require.Len(t, text, 2)
+	system := text[0].(map[string]any)
+	assert.Equal(t, "system", system["role"])
+	assert.Equal(t, "你是天气助手.\n"+jsonInstruction+"\nJSON 必须符合以下 JSON Schema:\n"+
+		`{"type":"object","properties":{"city":{"type":"string"},"days":{"type":"integer"}},"required":["city"]}`,
+		system["content"])
+}
+
+func TestModelContextSize(t *testing.T) {
+	t.Parallel()
+	// 注册表中的每个模型都可以按名称, 别名和 domain 查到上下文长度
+	for _, name := range sparkclient.Models() {
+		m, ok := sparkclient.LookupModel(name)
+		require.True(t, ok, name)
+		names := append([]string{m.Name, m.Domain}, m.Aliases...)
+		for _, n := range names {
+			assert.Equal(t, m.ContextWindow, llms.GetModelContextSize(n), n)
+		}
+	}
+	assert.Equal(t, 8192, llms.GetModelContextSize("4.0Ultra"))
+	assert.Equal(t, 2048, llms.GetModelContextSize("spark-pro-256k"))
+}
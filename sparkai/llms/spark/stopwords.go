@@ -0,0 +1,79 @@
+package spark
+
+import (
+	"errors"
+	"strings"
+)
+
+// errStopWord aborts the session once a stop word has been generated.
+var errStopWord = errors.New("stop word reached")
+
+// stopWordFilter emulates stop words, which Spark does not support, on the
+// streamed text. Text that may be the beginning of a stop word is held back
+// until the next delta tells whether it is.
+type stopWordFilter struct {
+	stopWords []string
+	// text is the text let through so far.
+	text    strings.Builder
+	pending string
+	stopped bool
+}
+
+func newStopWordFilter(stopWords []string) *stopWordFilter {
+	f := &stopWordFilter{}
+	for _, w := range stopWords {
+		if w != "" {
+			f.stopWords = append(f.stopWords, w)
+		}
+	}
+	return f
+}
+
+// write adds a delta and returns the text that can be let through. stop is
+// true when a stop word was found, the text after it is dropped.
+func (f *stopWordFilter) write(delta string) (text string, stop bool) {
+	if f.stopped {
+		return "", true
+	}
+	buf := f.pending + delta
+	cut := -1
+	for _, w := range f.stopWords {
+		if i := strings.Index(buf, w); i >= 0 && (cut < 0 || i < cut) {
+			cut = i
+		}
+	}
+	if cut >= 0 {
+		f.stopped = true
+		f.pending = ""
+		f.text.WriteString(buf[:cut])
+		return buf[:cut], true
+	}
+	keep := f.partialStopWord(buf)
+	f.pending = buf[len(buf)-keep:]
+	text = buf[:len(buf)-keep]
+	f.text.WriteString(text)
+	return text, false
+}
+
+// flush returns the held back text at the end of the stream.
+func (f *stopWordFilter) flush() string {
+	text := f.pending
+	f.pending = ""
+	f.text.WriteString(text)
+	return text
+}
+
+// partialStopWord returns the length of the longest suffix of s that is a
+// prefix of a stop word.
+func (f *stopWordFilter) partialStopWord(s string) int {
+	longest := 0
+	for _, w := range f.stopWords {
+		for n := len(w) - 1; n > longest; n-- {
+			if n <= len(s) && strings.HasSuffix(s, w[:n]) {
+				longest = n
+				break
+			}
+		}
+	}
+	return longest
+}
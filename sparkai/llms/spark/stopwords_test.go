@@ -0,0 +1,74 @@
+package spark
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStopWordFilter(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name      string
+		stopWords []string
+		deltas    []string
+		want      []string
+		text      string
+	}{
+		{
+			name:      "no stop word",
+			stopWords: []string{"END"},
+			deltas:    []string{"Hello ", "world"},
+			want:      []string{"Hello ", "world"},
+			text:      "Hello world",
+		},
+		{
+			name:      "stop word in a delta",
+			stopWords: []string{"END"},
+			deltas:    []string{"Hello ", "worldEND!", "ignored"},
+			want:      []string{"Hello ", "world"},
+			text:      "Hello world",
+		},
+		{
+			name:      "stop word across deltas",
+			stopWords: []string{"观察:"},
+			deltas:    []string{"思考: 查询天气\n观", "察: 晴"},
+			want:      []string{"思考: 查询天气\n", ""},
+			text:      "思考: 查询天气\n",
+		},
+		{
+			name:      "held back prefix released",
+			stopWords: []string{"END."},
+			deltas:    []string{"THE E", "ND", "S"},
+			want:      []string{"THE ", "", "ENDS"},
+			text:      "THE ENDS",
+		},
+		{
+			name:      "earliest stop word wins",
+			stopWords: []string{"B", "A"},
+			deltas:    []string{"xxAyyB"},
+			want:      []string{"xx"},
+			text:      "xx",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			f := newStopWordFilter(tt.stopWords)
+			var got []string
+			for _, d := range tt.deltas {
+				text, stop := f.write(d)
+				got = append(got, text)
+				if stop {
+					break
+				}
+			}
+			if !f.stopped {
+				got[len(got)-1] += f.flush()
+			}
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.text, f.text.String())
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package llms
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// OptionName names a field of CallOptions, after its JSON name.
+type OptionName string
+
+const (
+	OptionModel                OptionName = "model"
+	OptionMaxTokens            OptionName = "max_tokens"
+	OptionTemperature          OptionName = "temperature"
+	OptionStopWords            OptionName = "stop_words"
+	OptionStreamingFunc        OptionName = "streaming_func"
+	OptionTopK                 OptionName = "top_k"
+	OptionTopP                 OptionName = "top_p"
+	OptionSeed                 OptionName = "seed"
+	OptionMinLength            OptionName = "min_length"
+	OptionMaxLength            OptionName = "max_length"
+	OptionN                    OptionName = "n"
+	OptionRepetitionPenalty    OptionName = "repetition_penalty"
+	OptionFrequencyPenalty     OptionName = "frequency_penalty"
+	OptionPresencePenalty      OptionName = "presence_penalty"
+	OptionFunctions            OptionName = "functions"
+	OptionFunctionCallBehavior OptionName = "function_call"
+	OptionTools                OptionName = "tools"
+	OptionToolChoice           OptionName = "tool_choice"
+	OptionUserID               OptionName = "user_id"
+	OptionChatID               OptionName = "chat_id"
+	OptionAuditing             OptionName = "auditing"
+	OptionWebSearch            OptionName = "web_search"
+	OptionResponseFormat       OptionName = "response_format"
+)
+
+// ErrUnsupportedOption is wrapped by the errors of CheckOptions.
+var ErrUnsupportedOption = errors.New("unsupported call option")
+
+// OptionSupporter is implemented by the models declaring the call options
+// they honor.
+type OptionSupporter interface {
+	SupportedOptions() []OptionName
+}
+
+// SetOptions returns the names of the options set to a non-zero value. N is
+// only reported above 1, a single completion being the default of every model.
+func (o CallOptions) SetOptions() []OptionName {
+	set := []struct {
+		name OptionName
+		ok   bool
+	}{
+		{OptionModel, o.Model != ""},
+		{OptionMaxTokens, o.MaxTokens != 0},
+		{OptionTemperature, o.Temperature != 0},
+		{OptionStopWords, len(o.StopWords) > 0},
+		{OptionStreamingFunc, o.StreamingFunc != nil},
+		{OptionTopK, o.TopK != 0},
+		{OptionTopP, o.TopP != 0},
+		{OptionSeed, o.Seed != 0},
+		{OptionMinLength, o.MinLength != 0},
+		{OptionMaxLength, o.MaxLength != 0},
+		{OptionN, o.N > 1},
+		{OptionRepetitionPenalty, o.RepetitionPenalty != 0},
+		{OptionFrequencyPenalty, o.FrequencyPenalty != 0},
+		{OptionPresencePenalty, o.PresencePenalty != 0},
+		{OptionFunctions, len(o.Functions) > 0},
+		{OptionFunctionCallBehavior, o.FunctionCallBehavior != ""},
+		{OptionTools, len(o.Tools) > 0},
+		{OptionToolChoice, o.ToolChoice != nil},
+		{OptionUserID, o.UserID != ""},
+		{OptionChatID, o.ChatID != ""},
+		{OptionAuditing, o.Auditing != ""},
+		{OptionWebSearch, o.WebSearch != nil},
+		{OptionResponseFormat, o.ResponseFormat != nil},
+	}
+	names := make([]OptionName, 0, len(set))
+	for _, s := range set {
+		if s.ok {
+			names = append(names, s.name)
+		}
+	}
+	return names
+}
+
+// CheckOptions returns an error wrapping ErrUnsupportedOption and listing the
+// options set in opts that are missing from supported.
+func CheckOptions(provider string, opts CallOptions, supported []OptionName) error {
+	var unsupported []string
+	for _, name := range opts.SetOptions() {
+		if !containsOption(supported, name) {
+			unsupported = append(unsupported, string(name))
+		}
+	}
+	if len(unsupported) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %s does not support %s", ErrUnsupportedOption, provider, strings.Join(unsupported, ", "))
+}
+
+func containsOption(names []OptionName, name OptionName) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,26 @@
+package llms
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckOptions(t *testing.T) {
+	t.Parallel()
+	supported := []OptionName{OptionTemperature, OptionStopWords}
+
+	opts := CallOptions{}
+	for _, opt := range []CallOption{WithTemperature(0.5), WithStopWords([]string{"\n"}), WithN(1)} {
+		opt(&opts)
+	}
+	assert.Equal(t, []OptionName{OptionTemperature, OptionStopWords}, opts.SetOptions())
+	require.NoError(t, CheckOptions("test", opts, supported))
+
+	WithTopP(0.9)(&opts)
+	WithSeed(42)(&opts)
+	err := CheckOptions("test", opts, supported)
+	require.ErrorIs(t, err, ErrUnsupportedOption)
+	assert.Contains(t, err.Error(), "test does not support top_p, seed")
+}
@@ -1,13 +1,11 @@
 package memory
 
-import "autospark-go/types"
-
 // ConversationBufferOption is a function for creating new buffer
 // with other than the default values.
 type ConversationBufferOption func(b *ConversationBuffer)
 
 // WithChatHistory is an option for providing the chat history store.
-func WithChatHistory(chatHistory types.ChatMessageHistory) ConversationBufferOption {
+func WithChatHistory(chatHistory ChatMessageHistory) ConversationBufferOption {
 	return func(b *ConversationBuffer) {
 		b.ChatHistory = chatHistory
 	}
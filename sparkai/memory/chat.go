@@ -1,52 +1,64 @@
 package memory
 
 import (
-	"autospark-go/llms"
-	"autospark-go/types"
 	"context"
+	"sync"
+
+	"github.com/iflytek/spark-ai-go/sparkai/messages"
 )
 
-// ChatMessageHistory is a struct that stores chat messages.
-type ChatMessageHistory struct {
-	messages []llms.ChatMessage
+// InMemoryChatMessageHistory is a ChatMessageHistory storing the chat messages
+// in memory. It is safe for concurrent use.
+type InMemoryChatMessageHistory struct {
+	mu       sync.Mutex
+	messages []messages.ChatMessage
 }
 
-// Statically assert that ChatMessageHistory implement the chat message history interface.
-var _ types.ChatMessageHistory = &ChatMessageHistory{}
+// Statically assert that InMemoryChatMessageHistory implement the chat message history interface.
+var _ ChatMessageHistory = &InMemoryChatMessageHistory{}
 
-// NewChatMessageHistory creates a new ChatMessageHistory using chat message options.
-func NewChatMessageHistory(options ...ChatMessageHistoryOption) *ChatMessageHistory {
+// NewChatMessageHistory creates a new InMemoryChatMessageHistory using chat message options.
+func NewChatMessageHistory(options ...ChatMessageHistoryOption) *InMemoryChatMessageHistory {
 	return applyChatOptions(options...)
 }
 
 // Messages returns all messages stored.
-func (h *ChatMessageHistory) Messages(_ context.Context) ([]llms.ChatMessage, error) {
-	return h.messages, nil
+func (h *InMemoryChatMessageHistory) Messages(_ context.Context) ([]messages.ChatMessage, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]messages.ChatMessage{}, h.messages...), nil
 }
 
 // AddAIMessage adds an AIMessage to the chat message history.
-func (h *ChatMessageHistory) AddAIMessage(_ context.Context, text string) error {
-	h.messages = append(h.messages, llms.AIChatMessage{Content: text})
-	return nil
+func (h *InMemoryChatMessageHistory) AddAIMessage(ctx context.Context, text string) error {
+	return h.AddMessage(ctx, messages.AIChatMessage{Content: text})
 }
 
 // AddUserMessage adds a user to the chat message history.
-func (h *ChatMessageHistory) AddUserMessage(_ context.Context, text string) error {
-	h.messages = append(h.messages, llms.HumanChatMessage{Content: text})
-	return nil
+func (h *InMemoryChatMessageHistory) AddUserMessage(ctx context.Context, text string) error {
+	return h.AddMessage(ctx, messages.HumanChatMessage{Content: text})
 }
 
-func (h *ChatMessageHistory) Clear(_ context.Context) error {
-	h.messages = make([]llms.ChatMessage, 0)
+// Clear removes all the messages.
+func (h *InMemoryChatMessageHistory) Clear(_ context.Context) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.messages = make([]messages.ChatMessage, 0)
 	return nil
 }
 
-func (h *ChatMessageHistory) AddMessage(_ context.Context, message llms.ChatMessage) error {
+// AddMessage adds a message to the chat message history.
+func (h *InMemoryChatMessageHistory) AddMessage(_ context.Context, message messages.ChatMessage) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
 	h.messages = append(h.messages, message)
 	return nil
 }
 
-func (h *ChatMessageHistory) SetMessages(_ context.Context, messages []llms.ChatMessage) error {
-	h.messages = messages
+// SetMessages replaces the messages of the chat message history.
+func (h *InMemoryChatMessageHistory) SetMessages(_ context.Context, msgs []messages.ChatMessage) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.messages = append(make([]messages.ChatMessage, 0, len(msgs)), msgs...)
 	return nil
 }
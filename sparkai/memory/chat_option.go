@@ -1,42 +1,48 @@
 package memory
 
 import (
-	"autospark-go/llms"
-	"fmt"
+	"context"
+
+	"github.com/iflytek/spark-ai-go/log"
+	"github.com/iflytek/spark-ai-go/sparkai/memory/file_memory"
+	"github.com/iflytek/spark-ai-go/sparkai/messages"
 )
 
 // ChatMessageHistoryOption is a function for creating new chat message history
 // with other than the default values.
-type ChatMessageHistoryOption func(m *ChatMessageHistory)
+type ChatMessageHistoryOption func(m *InMemoryChatMessageHistory)
 
 // WithPreviousMessages is an option for NewChatMessageHistory for adding
 // previous messages to the history.
-func WithPreviousMessages(previousMessages []llms.ChatMessage) ChatMessageHistoryOption {
-	return func(m *ChatMessageHistory) {
+func WithPreviousMessages(previousMessages []messages.ChatMessage) ChatMessageHistoryOption {
+	return func(m *InMemoryChatMessageHistory) {
 		m.messages = append(m.messages, previousMessages...)
 	}
 }
 
+// WithPreviousMessagesByLog is an option for NewChatMessageHistory for loading
+// the previous messages from a JSONL history file, see
+// file_memory.FileChatMessageHistory. Read errors are logged and leave the
+// history empty.
 func WithPreviousMessagesByLog(logFile string) ChatMessageHistoryOption {
-
-	return func(m *ChatMessageHistory) {
-		storage, err := file_memory.NewChatHistoryFileStorage(logFile)
+	return func(m *InMemoryChatMessageHistory) {
+		storage, err := file_memory.NewFileChatMessageHistory(logFile)
 		if err != nil {
+			log.Logger.Warn(err.Error())
 			return
 		}
-		his, err := storage.Read()
+		his, err := storage.Messages(context.Background())
 		if err != nil {
-			fmt.Println(err.Error())
+			log.Logger.Warn(err.Error())
 			return
 		}
 		m.messages = his
 	}
-
 }
 
-func applyChatOptions(options ...ChatMessageHistoryOption) *ChatMessageHistory {
-	h := &ChatMessageHistory{
-		messages: make([]llms.ChatMessage, 0),
+func applyChatOptions(options ...ChatMessageHistoryOption) *InMemoryChatMessageHistory {
+	h := &InMemoryChatMessageHistory{
+		messages: make([]messages.ChatMessage, 0),
 	}
 
 	for _, option := range options {
@@ -0,0 +1,56 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/iflytek/spark-ai-go/sparkai/messages"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChatMessageHistory(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	h := NewChatMessageHistory()
+
+	msgs := []messages.ChatMessage{messages.SystemChatMessage{Content: "You are a helpful assistant."}}
+	require.NoError(t, h.SetMessages(ctx, msgs))
+	msgs[0] = messages.HumanChatMessage{Content: "changed"}
+	require.NoError(t, h.AddMessage(ctx, messages.FunctionChatMessage{Name: "get_weather", Content: "sunny"}))
+
+	got, err := h.Messages(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []messages.ChatMessage{
+		messages.SystemChatMessage{Content: "You are a helpful assistant."},
+		messages.FunctionChatMessage{Name: "get_weather", Content: "sunny"},
+	}, got)
+
+	require.NoError(t, h.Clear(ctx))
+	got, err = h.Messages(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, got)
+}
+
+func TestChatMessageHistoryConcurrent(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	h := NewChatMessageHistory()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, h.AddUserMessage(ctx, "hi"))
+			assert.NoError(t, h.AddAIMessage(ctx, "hello"))
+			_, err := h.Messages(ctx)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	got, err := h.Messages(ctx)
+	require.NoError(t, err)
+	assert.Len(t, got, 20)
+}
@@ -1,11 +1,11 @@
 package memory
 
 import (
-	"autospark-go/llms"
-	"autospark-go/types"
 	"context"
 	"errors"
 	"fmt"
+
+	"github.com/iflytek/spark-ai-go/sparkai/messages"
 )
 
 // ErrInvalidInputValues is returned when input values given to a memory in save context are invalid.
@@ -13,7 +13,7 @@ var ErrInvalidInputValues = errors.New("invalid input values")
 
 // ConversationBuffer is a simple form of memory that remembers previous conversational back and forth directly.
 type ConversationBuffer struct {
-	ChatHistory types.ChatMessageHistory
+	ChatHistory ChatMessageHistory
 
 	ReturnMessages bool
 	InputKey       string
@@ -43,18 +43,23 @@ func (m *ConversationBuffer) MemoryVariables(context.Context) []string {
 func (m *ConversationBuffer) LoadMemoryVariables(
 	ctx context.Context, _ map[string]any,
 ) (map[string]any, error) {
-	messages, err := m.ChatHistory.Messages(ctx)
+	msgs, err := m.ChatHistory.Messages(ctx)
 	if err != nil {
 		return nil, err
 	}
+	return m.memoryVariables(msgs)
+}
 
+// memoryVariables returns msgs under the memory key, as messages or as a
+// buffer string depending on ReturnMessages.
+func (m *ConversationBuffer) memoryVariables(msgs []messages.ChatMessage) (map[string]any, error) {
 	if m.ReturnMessages {
 		return map[string]any{
-			m.MemoryKey: messages,
+			m.MemoryKey: msgs,
 		}, nil
 	}
 
-	bufferString, err := llms.GetBufferString(messages, m.HumanPrefix, m.AIPrefix)
+	bufferString, err := messages.GetBufferString(msgs, m.HumanPrefix, m.AIPrefix)
 	if err != nil {
 		return nil, err
 	}
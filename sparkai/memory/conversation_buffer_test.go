@@ -1,11 +1,10 @@
 package memory
 
 import (
-	"autospark-go/llms"
-	"autospark-go/types"
 	"context"
 	"testing"
 
+	"github.com/iflytek/spark-ai-go/sparkai/messages"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -34,7 +33,7 @@ func TestBufferMemoryReturnMessage(t *testing.T) {
 
 	m := NewConversationBuffer()
 	m.ReturnMessages = true
-	expected1 := map[string]any{"history": []llms.ChatMessage{}}
+	expected1 := map[string]any{"history": []messages.ChatMessage{}}
 	result1, err := m.LoadMemoryVariables(context.Background(), map[string]any{})
 	require.NoError(t, err)
 	assert.Equal(t, expected1, result1)
@@ -46,15 +45,15 @@ func TestBufferMemoryReturnMessage(t *testing.T) {
 	require.NoError(t, err)
 
 	expectedChatHistory := NewChatMessageHistory(
-		WithPreviousMessages([]llms.ChatMessage{
-			llms.HumanChatMessage{Content: "bar"},
-			llms.AIChatMessage{Content: "foo"},
+		WithPreviousMessages([]messages.ChatMessage{
+			messages.HumanChatMessage{Content: "bar"},
+			messages.AIChatMessage{Content: "foo"},
 		}),
 	)
 
-	messages, err := expectedChatHistory.Messages(context.Background())
+	msgs, err := expectedChatHistory.Messages(context.Background())
 	require.NoError(t, err)
-	expected2 := map[string]any{"history": messages}
+	expected2 := map[string]any{"history": msgs}
 	assert.Equal(t, expected2, result2)
 }
 
@@ -62,9 +61,9 @@ func TestBufferMemoryWithPreLoadedHistory(t *testing.T) {
 	t.Parallel()
 
 	m := NewConversationBuffer(WithChatHistory(NewChatMessageHistory(
-		WithPreviousMessages([]llms.ChatMessage{
-			llms.HumanChatMessage{Content: "bar"},
-			llms.AIChatMessage{Content: "foo"},
+		WithPreviousMessages([]messages.ChatMessage{
+			messages.HumanChatMessage{Content: "bar"},
+			messages.AIChatMessage{Content: "foo"},
 		}),
 	)))
 
@@ -89,7 +88,7 @@ func TestBufferMemoryFileWithPreLoadedHistory(t *testing.T) {
 
 type testChatMessageHistory struct{}
 
-var _ types.ChatMessageHistory = testChatMessageHistory{}
+var _ ChatMessageHistory = testChatMessageHistory{}
 
 func (t testChatMessageHistory) AddUserMessage(context.Context, string) error {
 	return nil
@@ -99,7 +98,7 @@ func (t testChatMessageHistory) AddAIMessage(context.Context, string) error {
 	return nil
 }
 
-func (t testChatMessageHistory) AddMessage(context.Context, llms.ChatMessage) error {
+func (t testChatMessageHistory) AddMessage(context.Context, messages.ChatMessage) error {
 	return nil
 }
 
@@ -107,14 +106,14 @@ func (t testChatMessageHistory) Clear(context.Context) error {
 	return nil
 }
 
-func (t testChatMessageHistory) SetMessages(context.Context, []llms.ChatMessage) error {
+func (t testChatMessageHistory) SetMessages(context.Context, []messages.ChatMessage) error {
 	return nil
 }
 
-func (t testChatMessageHistory) Messages(context.Context) ([]llms.ChatMessage, error) {
-	return []llms.ChatMessage{
-		llms.HumanChatMessage{Content: "user message test"},
-		llms.AIChatMessage{Content: "ai message test"},
+func (t testChatMessageHistory) Messages(context.Context) ([]messages.ChatMessage, error) {
+	return []messages.ChatMessage{
+		messages.HumanChatMessage{Content: "user message test"},
+		messages.AIChatMessage{Content: "ai message test"},
 	}, nil
 }
 
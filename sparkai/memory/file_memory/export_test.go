@@ -0,0 +1,4 @@
+package file_memory
+
+// FileLocking reports whether the history files are locked in this build.
+const FileLocking = fileLocking
@@ -0,0 +1,302 @@
+package file_memory
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/iflytek/spark-ai-go/sparkai/messages"
+)
+
+var (
+	// ErrCorruptHistory is wrapped by the errors reporting an unreadable line
+	// of a history file, see CorruptLineError.
+	ErrCorruptHistory = errors.New("corrupt chat history")
+	// ErrUnsupportedVersion is reported for a line written by a newer release.
+	ErrUnsupportedVersion = errors.New("unsupported chat history version")
+	// ErrInvalidSessionID is returned for a session id that is not a plain
+	// file name.
+	ErrInvalidSessionID = errors.New("invalid session id")
+)
+
+// CorruptLineError reports an unreadable line of a history file.
+type CorruptLineError struct {
+	// Path is the path of the history file.
+	Path string
+	// Line is the number of the line, starting at 1.
+	Line int
+	// Err describes the problem.
+	Err error
+}
+
+func (e *CorruptLineError) Error() string {
+	return fmt.Sprintf("%s:%d: %s: %s", e.Path, e.Line, ErrCorruptHistory, e.Err)
+}
+
+func (e *CorruptLineError) Unwrap() []error {
+	return []error{ErrCorruptHistory, e.Err}
+}
+
+// FileChatMessageHistory is a chat message history stored in a JSONL file,
+// one message per line. Every line records the type of its message, which is
+// read back with its concrete type. Where flock is available the file is
+// locked while it is read or written, so several processes may share it. The
+// file is created by the first message added.
+type FileChatMessageHistory struct {
+	mu           sync.Mutex
+	path         string
+	sync         bool
+	atomicAppend bool
+}
+
+// FileOption is a function for creating a new file chat message history with
+// other than the default values.
+type FileOption func(h *FileChatMessageHistory)
+
+// WithSync makes every write to the file flushed to the disk before it
+// returns.
+func WithSync() FileOption {
+	return func(h *FileChatMessageHistory) {
+		h.sync = true
+	}
+}
+
+// WithAtomicAppend makes AddMessage write a copy of the file renamed over it
+// instead of appending to it: a crash never leaves a partial line, at the cost
+// of copying the file for every message.
+func WithAtomicAppend() FileOption {
+	return func(h *FileChatMessageHistory) {
+		h.atomicAppend = true
+	}
+}
+
+// NewFileChatMessageHistory creates a new FileChatMessageHistory stored in
+// the file at path.
+func NewFileChatMessageHistory(path string, options ...FileOption) (*FileChatMessageHistory, error) {
+	if path == "" {
+		return nil, errors.New("empty chat history path") //nolint:goerr113
+	}
+	h := &FileChatMessageHistory{path: path}
+	for _, option := range options {
+		option(h)
+	}
+	return h, nil
+}
+
+// NewSessionChatMessageHistory creates a new FileChatMessageHistory for the
+// session sessionID, stored in the file sessionID.jsonl of dir. The directory
+// is created if needed.
+func NewSessionChatMessageHistory(dir string, sessionID string, options ...FileOption) (*FileChatMessageHistory, error) { //nolint:lll
+	if sessionID == "" || sessionID == "." || sessionID == ".." || strings.ContainsAny(sessionID, `/\`) {
+		return nil, fmt.Errorf("%w %q", ErrInvalidSessionID, sessionID)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return NewFileChatMessageHistory(filepath.Join(dir, sessionID+".jsonl"), options...)
+}
+
+// Path returns the path of the history file.
+func (h *FileChatMessageHistory) Path() string {
+	return h.path
+}
+
+// Messages returns all messages stored, a *CorruptLineError when a line cannot
+// be read.
+func (h *FileChatMessageHistory) Messages(_ context.Context) ([]messages.ChatMessage, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	f, err := h.open(os.O_RDONLY, false)
+	if errors.Is(err, os.ErrNotExist) {
+		return make([]messages.ChatMessage, 0), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return h.read(f)
+}
+
+// AddAIMessage adds an AIMessage to the chat message history.
+func (h *FileChatMessageHistory) AddAIMessage(ctx context.Context, text string) error {
+	return h.AddMessage(ctx, messages.AIChatMessage{Content: text})
+}
+
+// AddUserMessage adds a user to the chat message history.
+func (h *FileChatMessageHistory) AddUserMessage(ctx context.Context, text string) error {
+	return h.AddMessage(ctx, messages.HumanChatMessage{Content: text})
+}
+
+// AddMessage adds a message to the chat message history.
+func (h *FileChatMessageHistory) AddMessage(_ context.Context, message messages.ChatMessage) error {
+	line, err := encodeMessage(message)
+	if err != nil {
+		return err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.atomicAppend {
+		return h.rewrite(func(old []byte) []byte { return append(old, line...) })
+	}
+
+	f, err := h.open(os.O_WRONLY|os.O_APPEND|os.O_CREATE, true)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	// 一次写入整行, 并发追加的行不会交错
+	if _, err := f.Write(line); err != nil {
+		return err
+	}
+	if h.sync {
+		return f.Sync()
+	}
+	return nil
+}
+
+// Clear removes all the messages.
+func (h *FileChatMessageHistory) Clear(ctx context.Context) error {
+	return h.SetMessages(ctx, nil)
+}
+
+// SetMessages replaces the messages of the chat message history. The file is
+// replaced atomically.
+func (h *FileChatMessageHistory) SetMessages(_ context.Context, msgs []messages.ChatMessage) error {
+	var data []byte
+	for _, msg := range msgs {
+		line, err := encodeMessage(msg)
+		if err != nil {
+			return err
+		}
+		data = append(data, line...)
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.rewrite(func([]byte) []byte { return data })
+}
+
+// open opens and locks the history file. The file may be replaced by another
+// writer while waiting for the lock, it is then opened again.
+func (h *FileChatMessageHistory) open(flag int, exclusive bool) (*os.File, error) {
+	for {
+		f, err := os.OpenFile(h.path, flag, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		if err := lockFile(f, exclusive); err != nil {
+			f.Close()
+			return nil, err
+		}
+		locked, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		current, err := os.Stat(h.path)
+		if err == nil && os.SameFile(locked, current) {
+			return f, nil
+		}
+		f.Close()
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+}
+
+// read decodes the lines of the history file, the blank lines are skipped.
+func (h *FileChatMessageHistory) read(r io.Reader) ([]messages.ChatMessage, error) {
+	msgs := make([]messages.ChatMessage, 0)
+	br := bufio.NewReader(r)
+	for n := 1; ; n++ {
+		line, err := br.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 {
+			msg, decodeErr := decodeMessage(trimmed)
+			if decodeErr != nil {
+				return nil, &CorruptLineError{Path: h.path, Line: n, Err: decodeErr}
+			}
+			msgs = append(msgs, msg)
+		}
+		if err != nil {
+			return msgs, nil
+		}
+	}
+}
+
+// rewrite replaces the content of the history file with update(content),
+// through a temporary file renamed over it.
+func (h *FileChatMessageHistory) rewrite(update func(content []byte) []byte) error {
+	f, err := h.open(os.O_RDWR|os.O_CREATE, true)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if f != nil {
+			f.Close()
+		}
+	}()
+	content, err := io.ReadAll(f)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(h.path)
+	tmp, err := os.CreateTemp(dir, filepath.Base(h.path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(update(content)); err != nil {
+		tmp.Close()
+		return err
+	}
+	// 重命名前必须落盘, 否则崩溃后可能得到空文件
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()); err != nil {
+		return err
+	}
+	if !fileLocking {
+		// 没有文件锁时原文件无需保持打开, 而 Windows 不能重命名覆盖打开的文件
+		f.Close()
+		f = nil
+	}
+	if err := os.Rename(tmp.Name(), h.path); err != nil {
+		return err
+	}
+	if h.sync {
+		return syncDir(dir)
+	}
+	return nil
+}
+
+// syncDir flushes the entries of dir to the disk, making a rename durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil && !errors.Is(err, os.ErrInvalid) {
+		return err
+	}
+	return nil
+}
@@ -1,55 +1,35 @@
 package file_memory
 
 import (
+	"context"
+
 	"github.com/iflytek/spark-ai-go/sparkai/messages"
-	"os"
-)
-import (
-	"encoding/json"
 )
 
+// ChatHistoryFileStorage appends chat messages to a JSONL file.
+//
+// Deprecated: use FileChatMessageHistory, which implements the chat message
+// history interface of the memory package.
 type ChatHistoryFileStorage struct {
-	file *os.File
+	history *FileChatMessageHistory
 }
 
 func NewChatHistoryFileStorage(filename string) (*ChatHistoryFileStorage, error) {
-	file, err := os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	history, err := NewFileChatMessageHistory(filename)
 	if err != nil {
 		return nil, err
 	}
-	return &ChatHistoryFileStorage{file: file}, nil
+	return &ChatHistoryFileStorage{history: history}, nil
 }
 
 func (ls *ChatHistoryFileStorage) Append(log messages.ChatMessage) error {
-	logData, err := json.Marshal(log)
-	if err != nil {
-		return err
-	}
-	logData = append(logData, '\n') // 每条日志以换行符结尾
-	_, err = ls.file.Write(logData)
-	if err != nil {
-		return err
-	}
-	return nil
+	return ls.history.AddMessage(context.Background(), log)
 }
 
 func (ls *ChatHistoryFileStorage) Read() ([]messages.ChatMessage, error) {
-	var logs []messages.ChatMessage
-	file, err := os.Open(ls.file.Name())
-	if err != nil {
-		return nil, err
-	}
-	decoder := json.NewDecoder(file)
-	for {
-		var log messages.GenericChatMessage
-		if err := decoder.Decode(&log); err != nil {
-			break // 读取完所有日志或者发生错误退出循环
-		}
-		logs = append(logs, log)
-	}
-	return logs, nil
+	return ls.history.Messages(context.Background())
 }
 
 func (ls *ChatHistoryFileStorage) Close() error {
-	return ls.file.Close()
+	return nil
 }
@@ -1,43 +1,31 @@
 package file_memory
 
 import (
-	"autospark-go/llms"
-	"fmt"
+	"path/filepath"
 	"testing"
+
+	"github.com/iflytek/spark-ai-go/sparkai/messages"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func Test_Write(t *testing.T) {
+	t.Parallel()
 	// 创建日志存储对象
-	logStorage, err := NewChatHistoryFileStorage("logs.jsonl")
-	if err != nil {
-		fmt.Println("创建日志存储对象失败:", err)
-		return
-	}
+	logStorage, err := NewChatHistoryFileStorage(filepath.Join(t.TempDir(), "logs.jsonl"))
+	require.NoError(t, err)
 	defer logStorage.Close()
 
 	// 添加日志
-	log1 := llms.GenericChatMessage{Role: "human", Content: "This is log 2"}
-	err = logStorage.Append(log1)
-	if err != nil {
-		fmt.Println("添加日志失败:", err)
-		return
-	}
-
-	log2 := llms.GenericChatMessage{Role: "ai", Content: "This is log 1"}
-	err = logStorage.Append(log2)
-	if err != nil {
-		fmt.Println("添加日志失败:", err)
-		return
-	}
+	require.NoError(t, logStorage.Append(messages.GenericChatMessage{Role: "human", Content: "This is log 2"}))
+	require.NoError(t, logStorage.Append(messages.GenericChatMessage{Role: "ai", Content: "This is log 1"}))
 
 	// 读取日志
 	logs, err := logStorage.Read()
-	if err != nil {
-		fmt.Println("读取日志失败:", err)
-		return
-	}
-	fmt.Println("读取到的日志:")
-	for _, log := range logs {
-		fmt.Println(log.GetType(), log.GetContent())
-	}
+	require.NoError(t, err)
+	require.Len(t, logs, 2)
+	assert.Equal(t, messages.ChatMessageTypeHuman, logs[0].GetType())
+	assert.Equal(t, "This is log 2", logs[0].GetContent())
+	assert.Equal(t, messages.ChatMessageTypeAI, logs[1].GetType())
+	assert.Equal(t, "This is log 1", logs[1].GetContent())
 }
@@ -0,0 +1,44 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly || illumos) || nofilelock
+
+package file_memory
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/iflytek/spark-ai-go/sparkai/messages"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestFileChatMessageHistoryWithoutLocking covers the rewrites of the builds
+// without file locking, where the file is closed before being replaced.
+func TestFileChatMessageHistoryWithoutLocking(t *testing.T) {
+	t.Parallel()
+	require.False(t, fileLocking)
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	h, err := NewFileChatMessageHistory(path, WithAtomicAppend(), WithSync())
+	require.NoError(t, err)
+
+	require.NoError(t, h.AddUserMessage(ctx, "你好"))
+	require.NoError(t, h.AddAIMessage(ctx, "你好, 有什么可以帮你?"))
+	require.NoError(t, h.SetMessages(ctx, []messages.ChatMessage{messages.SystemChatMessage{Content: "你是天气助手"}}))
+	require.NoError(t, h.AddUserMessage(ctx, "合肥天气怎么样"))
+	msgs, err := h.Messages(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []messages.ChatMessage{
+		messages.SystemChatMessage{Content: "你是天气助手"},
+		messages.HumanChatMessage{Content: "合肥天气怎么样"},
+	}, msgs)
+
+	require.NoError(t, h.Clear(ctx))
+	msgs, err = h.Messages(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, msgs)
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
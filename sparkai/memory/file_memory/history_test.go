@@ -0,0 +1,206 @@
+package file_memory_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/iflytek/spark-ai-go/sparkai/memory"
+	"github.com/iflytek/spark-ai-go/sparkai/memory/file_memory"
+	"github.com/iflytek/spark-ai-go/sparkai/messages"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Statically assert that FileChatMessageHistory implement the chat message history interface.
+var _ memory.ChatMessageHistory = &file_memory.FileChatMessageHistory{}
+
+func conversation() []messages.ChatMessage {
+	return []messages.ChatMessage{
+		messages.SystemChatMessage{Content: "你是天气助手"},
+		messages.HumanChatMessage{Content: "合肥天气怎么样"},
+		messages.AIChatMessage{FunctionCall: &messages.FunctionCall{Name: "get_weather", Arguments: `{"location":"合肥"}`}},
+		messages.FunctionChatMessage{Name: "get_weather", Content: "晴"},
+		messages.AIChatMessage{ToolCalls: []messages.ToolCall{{
+			ID: "call_1", Type: messages.ToolTypeFunction,
+			FunctionCall: &messages.FunctionCall{Name: "get_weather", Arguments: `{"location":"北京"}`},
+		}}},
+		messages.ToolChatMessage{ID: "call_1", Content: "多云"},
+		messages.AIChatMessage{Content: "合肥今天晴, 北京多云", Usage: &messages.Usage{PromptTokens: 10, CompletionTokens: 8, TotalTokens: 18}},
+		messages.GenericChatMessage{Role: "critic", Name: "bob", Content: "好"},
+	}
+}
+
+func TestFileChatMessageHistory(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	h, err := file_memory.NewFileChatMessageHistory(path)
+	require.NoError(t, err)
+
+	msgs, err := h.Messages(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, msgs)
+	assert.NoFileExists(t, path)
+
+	for _, msg := range conversation() {
+		require.NoError(t, h.AddMessage(ctx, msg))
+	}
+	// 另一个实例读取到相同类型的消息
+	other, err := file_memory.NewFileChatMessageHistory(path)
+	require.NoError(t, err)
+	msgs, err = other.Messages(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, conversation(), msgs)
+
+	require.NoError(t, h.SetMessages(ctx, conversation()[:2]))
+	require.NoError(t, h.AddAIMessage(ctx, "晴"))
+	msgs, err = other.Messages(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, append(conversation()[:2], messages.AIChatMessage{Content: "晴"}), msgs)
+
+	require.NoError(t, other.Clear(ctx))
+	msgs, err = h.Messages(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, msgs)
+}
+
+func TestFileChatMessageHistoryAtomicAppend(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	h, err := file_memory.NewFileChatMessageHistory(path, file_memory.WithAtomicAppend(), file_memory.WithSync())
+	require.NoError(t, err)
+
+	for _, msg := range conversation() {
+		require.NoError(t, h.AddMessage(ctx, msg))
+	}
+	msgs, err := h.Messages(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, conversation(), msgs)
+
+	// 临时文件不会残留
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestFileChatMessageHistoryCorrupt(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	cases := map[string]struct {
+		content string
+		line    int
+		err     error
+	}{
+		"invalid JSON": {
+			content: `{"v":1,"type":"human","content":"你好"}` + "\n\n" + `{"v":1,"type":"ai","cont`,
+			line:    3,
+		},
+		"unknown type": {
+			content: `{"v":1,"type":"human","content":"你好"}` + "\n" + `{"v":1,"type":"robot","content":"你好"}` + "\n",
+			line:    2,
+			err:     messages.ErrUnexpectedChatMessageType,
+		},
+		"newer version": {
+			content: `{"v":2,"type":"human","content":"你好"}` + "\n",
+			line:    1,
+			err:     file_memory.ErrUnsupportedVersion,
+		},
+	}
+	for name, tc := range cases {
+		path := filepath.Join(dir, name+".jsonl")
+		require.NoError(t, os.WriteFile(path, []byte(tc.content), 0o600))
+		h, err := file_memory.NewFileChatMessageHistory(path)
+		require.NoError(t, err)
+
+		_, err = h.Messages(ctx)
+		require.ErrorIs(t, err, file_memory.ErrCorruptHistory, name)
+		var lineErr *file_memory.CorruptLineError
+		require.ErrorAs(t, err, &lineErr, name)
+		assert.Equal(t, path, lineErr.Path, name)
+		assert.Equal(t, tc.line, lineErr.Line, name)
+		assert.Contains(t, err.Error(), fmt.Sprintf("%s:%d: ", path, tc.line), name)
+		if tc.err != nil {
+			assert.ErrorIs(t, err, tc.err, name)
+		}
+	}
+}
+
+func TestFileChatMessageHistoryLegacy(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	legacy := `{"Content":"bar","Role":"Human","Name":""}` + "\n" + `{"content":"foo","role":"ai","name":""}` + "\n"
+	require.NoError(t, os.WriteFile(path, []byte(legacy), 0o600))
+	h, err := file_memory.NewFileChatMessageHistory(path)
+	require.NoError(t, err)
+
+	msgs, err := h.Messages(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []messages.ChatMessage{
+		messages.GenericChatMessage{Role: "Human", Content: "bar"},
+		messages.GenericChatMessage{Role: "ai", Content: "foo"},
+	}, msgs)
+}
+
+func TestSessionChatMessageHistory(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	dir := filepath.Join(t.TempDir(), "sessions")
+
+	alice, err := file_memory.NewSessionChatMessageHistory(dir, "alice")
+	require.NoError(t, err)
+	bob, err := file_memory.NewSessionChatMessageHistory(dir, "bob")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "alice.jsonl"), alice.Path())
+
+	require.NoError(t, alice.AddUserMessage(ctx, "你好"))
+	msgs, err := bob.Messages(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, msgs)
+
+	for _, id := range []string{"", ".", "..", "../alice", `a\b`} {
+		_, err := file_memory.NewSessionChatMessageHistory(dir, id)
+		assert.ErrorIs(t, err, file_memory.ErrInvalidSessionID, id)
+	}
+}
+
+func TestFileChatMessageHistoryConcurrent(t *testing.T) {
+	t.Parallel()
+	if !file_memory.FileLocking {
+		t.Skip("the history files are not locked in this build")
+	}
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+
+	const writers, perWriter = 4, 25
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		// 每个写入者使用独立的实例, 如同独立的进程
+		h, err := file_memory.NewFileChatMessageHistory(path, file_memory.WithAtomicAppend())
+		require.NoError(t, err)
+		if w%2 == 0 {
+			h, err = file_memory.NewFileChatMessageHistory(path)
+			require.NoError(t, err)
+		}
+		wg.Add(1)
+		go func(w int, h *file_memory.FileChatMessageHistory) {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				assert.NoError(t, h.AddUserMessage(ctx, fmt.Sprintf("%d-%d", w, i)))
+			}
+		}(w, h)
+	}
+	wg.Wait()
+
+	h, err := file_memory.NewFileChatMessageHistory(path)
+	require.NoError(t, err)
+	msgs, err := h.Messages(ctx)
+	require.NoError(t, err)
+	assert.Len(t, msgs, writers*perWriter)
+}
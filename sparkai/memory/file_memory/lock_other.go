@@ -0,0 +1,15 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly || illumos) || nofilelock
+
+package file_memory
+
+import "os"
+
+// fileLocking reports whether lockFile locks the files.
+const fileLocking = false
+
+// lockFile does nothing on this platform, or when built with the nofilelock
+// tag: the history is only protected against the writers of the same
+// FileChatMessageHistory.
+func lockFile(*os.File, bool) error {
+	return nil
+}
@@ -0,0 +1,27 @@
+//go:build (linux || darwin || freebsd || netbsd || openbsd || dragonfly || illumos) && !nofilelock
+
+package file_memory
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// fileLocking reports whether lockFile locks the files.
+const fileLocking = true
+
+// lockFile locks f until it is closed, shared by readers and exclusive for
+// writers. The lock is advisory, it only excludes the other users of lockFile.
+func lockFile(f *os.File, exclusive bool) error {
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+	for {
+		err := syscall.Flock(int(f.Fd()), how)
+		if !errors.Is(err, syscall.EINTR) {
+			return err
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package file_memory
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/iflytek/spark-ai-go/sparkai/messages"
+)
+
+// Version is the version of the records written by FileChatMessageHistory.
+// Lines without a version were written by earlier releases, they are read
+// back as GenericChatMessages.
+const Version = 1
+
+// record is a line of a history file, the envelope of the message with the
+// version of the format.
+type record struct {
+	Version int `json:"v"`
+	messages.Envelope
+}
+
+func encodeMessage(msg messages.ChatMessage) ([]byte, error) {
+	e, err := messages.NewEnvelope(msg)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(record{Version: Version, Envelope: e})
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+func decodeMessage(line []byte) (messages.ChatMessage, error) {
+	var r record
+	if err := json.Unmarshal(line, &r); err != nil {
+		return nil, err
+	}
+	switch {
+	case r.Version == 0 && r.Type == "":
+		// 早期版本直接保存消息本身
+		return messages.GenericChatMessage{Role: r.Role, Name: r.Name, Content: r.Content}, nil
+	case r.Version < 1 || r.Version > Version:
+		return nil, fmt.Errorf("%w %d", ErrUnsupportedVersion, r.Version)
+	}
+	return r.Message()
+}
@@ -1,6 +1,10 @@
 package memory
 
-import "context"
+import (
+	"context"
+
+	"github.com/iflytek/spark-ai-go/sparkai/messages"
+)
 
 // Memory is a Large Language Model.
 type Memory interface {
@@ -16,3 +20,21 @@ type Memory interface {
 	// Clear memory contents.
 	Clear(ctx context.Context) error
 }
+
+// ChatMessageHistory is the interface for chat history in memory/store.
+type ChatMessageHistory interface {
+	// AddMessage adds a message to the store.
+	AddMessage(ctx context.Context, message messages.ChatMessage) error
+	// AddUserMessage is a convenience method for adding a human message string
+	// to the store.
+	AddUserMessage(ctx context.Context, message string) error
+	// AddAIMessage is a convenience method for adding an AI message string to
+	// the store.
+	AddAIMessage(ctx context.Context, message string) error
+	// Clear removes all messages from the store.
+	Clear(ctx context.Context) error
+	// Messages retrieves all messages from the store.
+	Messages(ctx context.Context) ([]messages.ChatMessage, error)
+	// SetMessages replaces existing messages in the store.
+	SetMessages(ctx context.Context, messages []messages.ChatMessage) error
+}
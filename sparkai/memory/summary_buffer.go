@@ -0,0 +1,195 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/iflytek/spark-ai-go/sparkai/llms"
+	"github.com/iflytek/spark-ai-go/sparkai/messages"
+)
+
+// DefaultSummaryPrompt is the default prompt of a ConversationSummaryBuffer,
+// {summary} is replaced with the current summary and {new_lines} with the
+// messages to add to it.
+const DefaultSummaryPrompt = `逐步总结对话内容: 在已有摘要的基础上加入新的对话内容, 返回新的摘要, 不要输出其他内容.
+
+已有摘要:
+{summary}
+
+新的对话:
+{new_lines}
+
+新的摘要:`
+
+// DefaultSummaryPrefix starts the system message holding the summary.
+const DefaultSummaryPrefix = "以下是之前对话的摘要:\n"
+
+// ConversationSummaryBuffer is a ConversationBuffer compressing the oldest
+// messages once the history exceeds MaxTokenLimit tokens: they are removed
+// from the history and summarized by LLM into a running summary, stored as
+// a system message after the other system messages. A function or tool call
+// is never separated from its results. The messages added to the history
+// while a summary is written are kept.
+type ConversationSummaryBuffer struct {
+	ConversationBuffer
+	// mu serializes SaveContext and Prune.
+	mu sync.Mutex
+	// LLM writes the summaries.
+	LLM llms.Model
+	// Model is the model the tokens are counted for.
+	Model string
+	// MaxTokenLimit is the size of the history above which the oldest
+	// messages are summarized, the system messages included.
+	MaxTokenLimit int
+	// CountTokens counts the tokens of a text, llms.CountTokens for Model by
+	// default.
+	CountTokens func(text string) int
+	// Prompt is the summarization prompt, see DefaultSummaryPrompt.
+	Prompt string
+	// SummaryPrefix starts the system message holding the summary, it tells
+	// the summary from the other system messages.
+	SummaryPrefix string
+}
+
+// Statically assert that ConversationSummaryBuffer implement the memory interface.
+var _ Memory = &ConversationSummaryBuffer{}
+
+// NewConversationSummaryBuffer creates a new summary buffer memory using llm
+// to summarize the history of model. When maxTokenLimit is zero the limit is
+// half the context size of the model.
+func NewConversationSummaryBuffer(llm llms.Model, model string, maxTokenLimit int, options ...ConversationBufferOption) *ConversationSummaryBuffer { //nolint:lll
+	if maxTokenLimit <= 0 {
+		maxTokenLimit = llms.GetModelContextSize(model) / 2
+	}
+	return &ConversationSummaryBuffer{
+		ConversationBuffer: *applyBufferOptions(options...),
+		LLM:                llm,
+		Model:              model,
+		MaxTokenLimit:      maxTokenLimit,
+		Prompt:             DefaultSummaryPrompt,
+		SummaryPrefix:      DefaultSummaryPrefix,
+	}
+}
+
+// SaveContext saves the messages like ConversationBuffer.SaveContext, then
+// summarizes the oldest messages if needed. When the summarization fails the
+// error is returned, the messages are saved and kept in the history.
+func (m *ConversationSummaryBuffer) SaveContext(ctx context.Context, inputValues map[string]any, outputValues map[string]any) error { //nolint:lll
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.ConversationBuffer.SaveContext(ctx, inputValues, outputValues); err != nil {
+		return err
+	}
+	return m.prune(ctx)
+}
+
+// Prune summarizes the oldest messages while the history exceeds
+// MaxTokenLimit tokens. Only the summarized messages are replaced, the ones
+// added by other writers meanwhile are kept. If the summarized messages are
+// no longer at the start of the history, it is left unchanged.
+func (m *ConversationSummaryBuffer) Prune(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.prune(ctx)
+}
+
+func (m *ConversationSummaryBuffer) prune(ctx context.Context) error {
+	msgs, err := m.ChatHistory.Messages(ctx)
+	if err != nil {
+		return err
+	}
+	total, err := m.countTokens(msgs)
+	if err != nil || total <= m.MaxTokenLimit {
+		return err
+	}
+
+	var system, rest []messages.ChatMessage
+	summary := ""
+	for _, msg := range msgs {
+		switch {
+		case msg.GetType() != messages.ChatMessageTypeSystem:
+			rest = append(rest, msg)
+		case summary == "" && strings.HasPrefix(msg.GetContent(), m.SummaryPrefix):
+			summary = strings.TrimPrefix(msg.GetContent(), m.SummaryPrefix)
+		default:
+			system = append(system, msg)
+		}
+	}
+	systemTokens, err := m.countTokens(system)
+	if err != nil {
+		return err
+	}
+	summaryTokens, err := m.countTokens([]messages.ChatMessage{messages.SystemChatMessage{Content: m.SummaryPrefix + summary}})
+	if err != nil {
+		return err
+	}
+	budget := m.MaxTokenLimit - systemTokens - summaryTokens
+
+	// 从最早的消息开始移出, 直到剩余消息不超过预算
+	groups := messageGroups(rest)
+	tokens := 0
+	for _, g := range groups {
+		n, err := m.countTokens(g.messages)
+		if err != nil {
+			return err
+		}
+		tokens += n
+	}
+	var pruned []messages.ChatMessage
+	for len(groups) > 0 && tokens > budget {
+		n, err := m.countTokens(groups[0].messages)
+		if err != nil {
+			return err
+		}
+		tokens -= n
+		pruned = append(pruned, groups[0].messages...)
+		groups = groups[1:]
+	}
+	if len(pruned) == 0 {
+		return nil
+	}
+
+	summary, err = m.summarize(ctx, summary, pruned)
+	if err != nil {
+		return err
+	}
+
+	// 总结期间其他写入者可能追加了消息, 只替换已总结的前缀
+	current, err := m.ChatHistory.Messages(ctx)
+	if err != nil {
+		return err
+	}
+	if len(current) < len(msgs) || !reflect.DeepEqual(current[:len(msgs)], msgs) {
+		return nil
+	}
+	added := current[len(msgs):]
+	kept := make([]messages.ChatMessage, 0, len(system)+1+len(rest)+len(added))
+	kept = append(kept, system...)
+	kept = append(kept, messages.SystemChatMessage{Content: m.SummaryPrefix + summary})
+	for _, g := range groups {
+		kept = append(kept, g.messages...)
+	}
+	kept = append(kept, added...)
+	return m.ChatHistory.SetMessages(ctx, kept)
+}
+
+// summarize adds msgs to summary.
+func (m *ConversationSummaryBuffer) summarize(ctx context.Context, summary string, msgs []messages.ChatMessage) (string, error) { //nolint:lll
+	newLines, err := messages.GetBufferString(msgs, m.HumanPrefix, m.AIPrefix)
+	if err != nil {
+		return "", err
+	}
+	prompt := strings.NewReplacer("{summary}", summary, "{new_lines}", newLines).Replace(m.Prompt)
+	result, err := llms.GenerateFromSinglePrompt(ctx, m.LLM, prompt)
+	if err != nil {
+		return "", fmt.Errorf("summarize conversation: %w", err)
+	}
+	return strings.TrimSpace(result), nil
+}
+
+func (m *ConversationSummaryBuffer) countTokens(msgs []messages.ChatMessage) (int, error) {
+	return countTokens(&m.ConversationBuffer, m.Model, m.CountTokens, msgs)
+}
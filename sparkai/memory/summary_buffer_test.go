@@ -0,0 +1,131 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/iflytek/spark-ai-go/sparkai/llms"
+	"github.com/iflytek/spark-ai-go/sparkai/messages"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeLLM answers every prompt with a numbered summary.
+type fakeLLM struct {
+	prompts []string
+	err     error
+	// generate is called for every prompt before it is answered.
+	generate func()
+}
+
+var _ llms.Model = (*fakeLLM)(nil)
+
+func (l *fakeLLM) GenerateContent(_ context.Context, msgs []messages.MessageContent, _ ...llms.CallOption) (*messages.ContentResponse, error) { //nolint:lll
+	if l.generate != nil {
+		l.generate()
+	}
+	if l.err != nil {
+		return nil, l.err
+	}
+	l.prompts = append(l.prompts, msgs[0].Parts[0].(messages.TextContent).Text)
+	return &messages.ContentResponse{Choices: []*messages.ContentChoice{
+		{Content: fmt.Sprintf(" 摘要%d \n", len(l.prompts))},
+	}}, nil
+}
+
+func newTestSummaryBuffer(llm llms.Model, limit int, msgs []messages.ChatMessage) *ConversationSummaryBuffer {
+	m := NewConversationSummaryBuffer(llm, "generalv3.5", limit,
+		WithChatHistory(NewChatMessageHistory(WithPreviousMessages(msgs))), WithReturnMessages(true))
+	m.CountTokens = countRunes
+	m.Prompt = "{summary}|{new_lines}"
+	return m
+}
+
+func TestConversationSummaryBuffer(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	llm := &fakeLLM{}
+	msgs := weatherConversation()
+	// 按字符计数, 58 个字符只够保留系统消息, 摘要和最后一轮对话
+	m := newTestSummaryBuffer(llm, 58, msgs[:1])
+
+	require.NoError(t, m.SaveContext(ctx, map[string]any{"input": "你好"}, map[string]any{"output": "你好, 有什么可以帮你?"}))
+	assert.Empty(t, llm.prompts)
+
+	require.NoError(t, m.ChatHistory.AddUserMessage(ctx, "合肥天气怎么样"))
+	for _, msg := range msgs[4:7] {
+		require.NoError(t, m.ChatHistory.AddMessage(ctx, msg))
+	}
+	require.NoError(t, m.SaveContext(ctx, map[string]any{"input": "谢谢"}, map[string]any{"output": "不客气"}))
+
+	// 最早的两轮对话被总结, 函数调用与结果一起移出
+	require.Len(t, llm.prompts, 1)
+	assert.Equal(t, "|Human: 你好\nAI: 你好, 有什么可以帮你?\nHuman: 合肥天气怎么样\n"+
+		`AI:  {"name":"get_weather","arguments":"{\"location\":\"合肥\"}"}`+"\nFunction: 晴\nAI: 合肥今天晴", llm.prompts[0])
+	got := loadMessages(t, m)
+	assert.Equal(t, []messages.ChatMessage{
+		msgs[0],
+		messages.SystemChatMessage{Content: DefaultSummaryPrefix + "摘要1"},
+		messages.HumanChatMessage{Content: "谢谢"},
+		messages.AIChatMessage{Content: "不客气"},
+	}, got)
+
+	// 已有摘要参与下一次总结
+	require.NoError(t, m.SaveContext(ctx, map[string]any{"input": "明天呢"}, map[string]any{"output": "多云"}))
+	require.Len(t, llm.prompts, 2)
+	assert.Equal(t, "摘要1|Human: 谢谢\nAI: 不客气", llm.prompts[1])
+	assert.Equal(t, []messages.ChatMessage{
+		msgs[0],
+		messages.SystemChatMessage{Content: DefaultSummaryPrefix + "摘要2"},
+		messages.HumanChatMessage{Content: "明天呢"},
+		messages.AIChatMessage{Content: "多云"},
+	}, loadMessages(t, m))
+}
+
+func TestConversationSummaryBufferError(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	errFailed := errors.New("failed")
+	m := newTestSummaryBuffer(&fakeLLM{err: errFailed}, 10, nil)
+
+	err := m.SaveContext(ctx, map[string]any{"input": "合肥天气怎么样"}, map[string]any{"output": "合肥今天晴"})
+	require.ErrorIs(t, err, errFailed)
+	// 总结失败时保留原消息
+	assert.Len(t, loadMessages(t, m), 2)
+}
+
+func TestConversationSummaryBufferConcurrentWriter(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	llm := &fakeLLM{}
+	m := newTestSummaryBuffer(llm, 10, nil)
+	// 另一个写入者在总结期间追加消息
+	added := messages.HumanChatMessage{Content: "明天呢"}
+	llm.generate = func() {
+		require.NoError(t, m.ChatHistory.AddMessage(ctx, added))
+	}
+
+	require.NoError(t, m.SaveContext(ctx, map[string]any{"input": "合肥天气怎么样"}, map[string]any{"output": "合肥今天晴"}))
+	require.Len(t, llm.prompts, 1)
+	assert.Equal(t, []messages.ChatMessage{
+		messages.SystemChatMessage{Content: DefaultSummaryPrefix + "摘要1"},
+		added,
+	}, loadMessages(t, m))
+}
+
+func TestConversationSummaryBufferHistoryReplaced(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	llm := &fakeLLM{}
+	m := newTestSummaryBuffer(llm, 10, nil)
+	replaced := []messages.ChatMessage{messages.HumanChatMessage{Content: "你好"}}
+	llm.generate = func() {
+		require.NoError(t, m.ChatHistory.SetMessages(ctx, replaced))
+	}
+
+	// 已总结的消息不再是历史的前缀时, 历史保持不变
+	require.NoError(t, m.SaveContext(ctx, map[string]any{"input": "合肥天气怎么样"}, map[string]any{"output": "合肥今天晴"}))
+	assert.Equal(t, replaced, loadMessages(t, m))
+}
@@ -0,0 +1,91 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/iflytek/spark-ai-go/sparkai/llms"
+	"github.com/iflytek/spark-ai-go/sparkai/messages"
+)
+
+// ConversationTokenBuffer is a ConversationBuffer returning the most recent
+// messages fitting in a token budget. The system messages are always
+// returned and a function or tool call is never separated from its results.
+// The chat history itself is left whole.
+type ConversationTokenBuffer struct {
+	ConversationBuffer
+	// Model is the model the tokens are counted for.
+	Model string
+	// MaxTokenLimit is the token budget of the returned messages.
+	MaxTokenLimit int
+	// CountTokens counts the tokens of a text, llms.CountTokens for Model by
+	// default.
+	CountTokens func(text string) int
+}
+
+// Statically assert that ConversationTokenBuffer implement the memory interface.
+var _ Memory = &ConversationTokenBuffer{}
+
+// NewConversationTokenBuffer creates a new token buffer memory for model. When
+// maxTokenLimit is zero the budget is half the context size of the model, the
+// other half being left to the question and the answer.
+func NewConversationTokenBuffer(model string, maxTokenLimit int, options ...ConversationBufferOption) *ConversationTokenBuffer { //nolint:lll
+	if maxTokenLimit <= 0 {
+		maxTokenLimit = llms.GetModelContextSize(model) / 2
+	}
+	return &ConversationTokenBuffer{
+		ConversationBuffer: *applyBufferOptions(options...),
+		Model:              model,
+		MaxTokenLimit:      maxTokenLimit,
+	}
+}
+
+// LoadMemoryVariables returns the most recent messages fitting in
+// MaxTokenLimit tokens, see ConversationBuffer.LoadMemoryVariables. The
+// tokens of a message are counted on its buffer string line.
+func (m *ConversationTokenBuffer) LoadMemoryVariables(ctx context.Context, _ map[string]any) (map[string]any, error) {
+	msgs, err := m.ChatHistory.Messages(ctx)
+	if err != nil {
+		return nil, err
+	}
+	groups := messageGroups(msgs)
+	tokens := make([]int, len(groups))
+	budget := m.MaxTokenLimit
+	for i, g := range groups {
+		if tokens[i], err = m.countTokens(g.messages); err != nil {
+			return nil, err
+		}
+		if g.system {
+			budget -= tokens[i]
+		}
+	}
+
+	keep := make([]bool, len(groups))
+	for i := len(groups) - 1; i >= 0; i-- {
+		if groups[i].system {
+			continue
+		}
+		if tokens[i] > budget {
+			break
+		}
+		budget -= tokens[i]
+		keep[i] = true
+	}
+	return m.memoryVariables(keptMessages(groups, keep))
+}
+
+func (m *ConversationTokenBuffer) countTokens(msgs []messages.ChatMessage) (int, error) {
+	return countTokens(&m.ConversationBuffer, m.Model, m.CountTokens, msgs)
+}
+
+// countTokens counts the tokens of the buffer string of msgs with count, or
+// with llms.CountTokens for model when count is nil.
+func countTokens(b *ConversationBuffer, model string, count func(string) int, msgs []messages.ChatMessage) (int, error) { //nolint:lll
+	text, err := messages.GetBufferString(msgs, b.HumanPrefix, b.AIPrefix)
+	if err != nil {
+		return 0, err
+	}
+	if count != nil {
+		return count(text), nil
+	}
+	return llms.CountTokens(model, text), nil
+}
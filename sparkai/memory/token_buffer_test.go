@@ -0,0 +1,66 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"unicode/utf8"
+
+	// 注册 Spark 模型的上下文长度
+	_ "github.com/iflytek/spark-ai-go/sparkai/llms/spark"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countRunes counts a token per rune.
+func countRunes(text string) int {
+	return utf8.RuneCountInString(text)
+}
+
+func TestConversationTokenBuffer(t *testing.T) {
+	t.Parallel()
+	msgs := weatherConversation()
+	history := NewChatMessageHistory(WithPreviousMessages(msgs))
+
+	counter := &ConversationTokenBuffer{ConversationBuffer: *applyBufferOptions(), CountTokens: countRunes}
+	// tokens counts the tokens of the messages from n on
+	tokens := func(n int) int {
+		total := 0
+		for _, g := range messageGroups(msgs[n:]) {
+			c, err := counter.countTokens(g.messages)
+			require.NoError(t, err)
+			total += c
+		}
+		return total
+	}
+	system := tokens(0) - tokens(1)
+
+	tests := []struct {
+		name  string
+		limit int
+		from  int
+	}{
+		{"everything", tokens(0), 1},
+		{"last turn", system + tokens(7), 7},
+		// 函数调用与结果不会被拆开
+		{"function result", system + tokens(5), 6},
+		{"function call", system + tokens(4), 4},
+		{"system only", system, len(msgs)},
+		{"over budget", 1, len(msgs)},
+	}
+	for _, tt := range tests {
+		m := NewConversationTokenBuffer("generalv3.5", tt.limit, WithChatHistory(history), WithReturnMessages(true))
+		m.CountTokens = countRunes
+		assert.Equal(t, append(msgs[:1:1], msgs[tt.from:]...), loadMessages(t, m), tt.name)
+	}
+}
+
+func TestConversationTokenBufferDefaults(t *testing.T) {
+	t.Parallel()
+	m := NewConversationTokenBuffer("generalv3.5", 0)
+	assert.Equal(t, 4096, m.MaxTokenLimit)
+
+	require.NoError(t, m.SaveContext(context.Background(), map[string]any{"input": "你好"}, map[string]any{"output": "你好!"}))
+	vars, err := m.LoadMemoryVariables(context.Background(), map[string]any{})
+	require.NoError(t, err)
+	assert.Equal(t, "Human: 你好\nAI: 你好!", vars["history"])
+}
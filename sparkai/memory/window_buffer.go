@@ -0,0 +1,108 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/iflytek/spark-ai-go/sparkai/messages"
+)
+
+// DefaultConversationWindowSize is the number of turns kept by a
+// ConversationWindowBuffer created with a window size of zero.
+const DefaultConversationWindowSize = 5
+
+// ConversationWindowBuffer is a ConversationBuffer returning only the last
+// turns of the conversation, a turn starting with a human message. The
+// system messages are always returned and a function or tool call is never
+// separated from its results. The chat history itself is left whole.
+type ConversationWindowBuffer struct {
+	ConversationBuffer
+	// WindowSize is the number of turns returned.
+	WindowSize int
+}
+
+// Statically assert that ConversationWindowBuffer implement the memory interface.
+var _ Memory = &ConversationWindowBuffer{}
+
+// NewConversationWindowBuffer creates a new window buffer memory keeping the
+// last windowSize turns, DefaultConversationWindowSize when windowSize is zero.
+func NewConversationWindowBuffer(windowSize int, options ...ConversationBufferOption) *ConversationWindowBuffer {
+	if windowSize <= 0 {
+		windowSize = DefaultConversationWindowSize
+	}
+	return &ConversationWindowBuffer{
+		ConversationBuffer: *applyBufferOptions(options...),
+		WindowSize:         windowSize,
+	}
+}
+
+// LoadMemoryVariables returns the last WindowSize turns, see
+// ConversationBuffer.LoadMemoryVariables.
+func (m *ConversationWindowBuffer) LoadMemoryVariables(ctx context.Context, _ map[string]any) (map[string]any, error) {
+	msgs, err := m.ChatHistory.Messages(ctx)
+	if err != nil {
+		return nil, err
+	}
+	groups := messageGroups(msgs)
+	keep := make([]bool, len(groups))
+	turns := 0
+	for i := len(groups) - 1; i >= 0 && turns < m.WindowSize; i-- {
+		keep[i] = true
+		if groups[i].messages[0].GetType() == messages.ChatMessageTypeHuman {
+			turns++
+		}
+	}
+	return m.memoryVariables(keptMessages(groups, keep))
+}
+
+// messageGroup is a run of messages that are kept or dropped together.
+type messageGroup struct {
+	messages []messages.ChatMessage
+	// system is set for a system message, which is always kept.
+	system bool
+}
+
+// messageGroups splits msgs in groups: a message calling functions or tools
+// is grouped with the results following it, every other message is a group
+// of its own.
+func messageGroups(msgs []messages.ChatMessage) []messageGroup {
+	var groups []messageGroup
+	for _, m := range msgs {
+		// 调用组只包含调用及紧随其后的结果
+		if n := len(groups); n > 0 && isCallResult(m) && isCall(groups[n-1].messages[0]) {
+			groups[n-1].messages = append(groups[n-1].messages, m)
+			continue
+		}
+		groups = append(groups, messageGroup{
+			messages: []messages.ChatMessage{m},
+			system:   m.GetType() == messages.ChatMessageTypeSystem,
+		})
+	}
+	return groups
+}
+
+// keptMessages returns the messages of the system groups and of the groups
+// marked in keep, in their original order.
+func keptMessages(groups []messageGroup, keep []bool) []messages.ChatMessage {
+	msgs := make([]messages.ChatMessage, 0)
+	for i, g := range groups {
+		if g.system || keep[i] {
+			msgs = append(msgs, g.messages...)
+		}
+	}
+	return msgs
+}
+
+// isCall reports whether m is an AI message calling functions or tools.
+func isCall(m messages.ChatMessage) bool {
+	if fc, ok := m.(interface{ GetFunctionCall() *messages.FunctionCall }); ok && fc.GetFunctionCall() != nil {
+		return true
+	}
+	tc, ok := m.(interface{ GetToolCalls() []messages.ToolCall })
+	return ok && len(tc.GetToolCalls()) > 0
+}
+
+// isCallResult reports whether m is the result of a function or tool call.
+func isCallResult(m messages.ChatMessage) bool {
+	t := m.GetType()
+	return t == messages.ChatMessageTypeFunction || t == messages.ChatMessageTypeTool
+}
@@ -0,0 +1,87 @@
+package memory
+
+import (
+	"context"
+	"testing"
+
+	"github.com/iflytek/spark-ai-go/sparkai/messages"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// weatherConversation is a conversation of three turns, the second one
+// calling a function.
+func weatherConversation() []messages.ChatMessage {
+	return []messages.ChatMessage{
+		messages.SystemChatMessage{Content: "你是天气助手"},
+		messages.HumanChatMessage{Content: "你好"},
+		messages.AIChatMessage{Content: "你好, 有什么可以帮你?"},
+		messages.HumanChatMessage{Content: "合肥天气怎么样"},
+		messages.AIChatMessage{FunctionCall: &messages.FunctionCall{Name: "get_weather", Arguments: `{"location":"合肥"}`}},
+		messages.FunctionChatMessage{Name: "get_weather", Content: "晴"},
+		messages.AIChatMessage{Content: "合肥今天晴"},
+		messages.HumanChatMessage{Content: "谢谢"},
+		messages.AIChatMessage{Content: "不客气"},
+	}
+}
+
+func loadMessages(t *testing.T, m Memory) []messages.ChatMessage {
+	t.Helper()
+	vars, err := m.LoadMemoryVariables(context.Background(), map[string]any{})
+	require.NoError(t, err)
+	msgs, ok := vars["history"].([]messages.ChatMessage)
+	require.True(t, ok)
+	return msgs
+}
+
+func TestConversationWindowBuffer(t *testing.T) {
+	t.Parallel()
+	msgs := weatherConversation()
+	history := NewChatMessageHistory(WithPreviousMessages(msgs))
+
+	m := NewConversationWindowBuffer(2, WithChatHistory(history), WithReturnMessages(true))
+	assert.Equal(t, append(msgs[:1:1], msgs[3:]...), loadMessages(t, m))
+
+	m = NewConversationWindowBuffer(1, WithChatHistory(history), WithReturnMessages(true))
+	assert.Equal(t, append(msgs[:1:1], msgs[7:]...), loadMessages(t, m))
+
+	m = NewConversationWindowBuffer(10, WithChatHistory(history), WithReturnMessages(true))
+	assert.Equal(t, msgs, loadMessages(t, m))
+
+	// 历史本身不被截断
+	all, err := history.Messages(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, msgs, all)
+}
+
+func TestConversationWindowBufferString(t *testing.T) {
+	t.Parallel()
+	m := NewConversationWindowBuffer(0)
+	assert.Equal(t, DefaultConversationWindowSize, m.WindowSize)
+	for _, text := range []string{"1", "2", "3", "4", "5", "6"} {
+		require.NoError(t, m.SaveContext(context.Background(), map[string]any{"input": text}, map[string]any{"output": text}))
+	}
+
+	vars, err := m.LoadMemoryVariables(context.Background(), map[string]any{})
+	require.NoError(t, err)
+	assert.Equal(t, "Human: 2\nAI: 2\nHuman: 3\nAI: 3\nHuman: 4\nAI: 4\nHuman: 5\nAI: 5\nHuman: 6\nAI: 6", vars["history"])
+}
+
+func TestMessageGroups(t *testing.T) {
+	t.Parallel()
+	msgs := []messages.ChatMessage{
+		messages.HumanChatMessage{Content: "合肥和北京天气怎么样"},
+		messages.AIChatMessage{ToolCalls: []messages.ToolCall{
+			{ID: "call_hf", Type: messages.ToolTypeFunction, FunctionCall: &messages.FunctionCall{Name: "get_weather"}},
+			{ID: "call_bj", Type: messages.ToolTypeFunction, FunctionCall: &messages.FunctionCall{Name: "get_weather"}},
+		}},
+		messages.ToolChatMessage{ID: "call_hf", Content: "晴"},
+		messages.ToolChatMessage{ID: "call_bj", Content: "雨"},
+		messages.AIChatMessage{Content: "合肥晴, 北京雨"},
+		messages.FunctionChatMessage{Name: "orphan", Content: "结果"},
+	}
+	groups := messageGroups(msgs)
+	require.Len(t, groups, 4)
+	assert.Equal(t, msgs[1:4], groups[1].messages)
+	assert.Equal(t, msgs[5:], groups[3].messages)
+}
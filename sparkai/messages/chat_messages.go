@@ -24,6 +24,8 @@ const (
 	ChatMessageTypeGeneric ChatMessageType = "generic"
 	// ChatMessageTypeFunction is a message sent by a function.
 	ChatMessageTypeFunction ChatMessageType = "function"
+	// ChatMessageTypeTool is a message sent by a tool.
+	ChatMessageTypeTool ChatMessageType = "tool"
 )
 
 // ChatMessage represents a message in a chat.
@@ -48,6 +50,7 @@ var (
 	_ ChatMessage = SystemChatMessage{}
 	_ ChatMessage = GenericChatMessage{}
 	_ ChatMessage = FunctionChatMessage{}
+	_ ChatMessage = ToolChatMessage{}
 )
 
 // AIChatMessage is a message sent by an AI.
@@ -57,6 +60,19 @@ type AIChatMessage struct {
 
 	// FunctionCall represents the model choosing to call a function.
 	FunctionCall *FunctionCall `json:"function_call,omitempty"`
+
+	// ToolCalls are the tools the model chose to call, possibly several at once.
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+
+	// Usage is the token usage of the generation, set by the chat models.
+	Usage *Usage `json:"usage,omitempty"`
+}
+
+// Usage is the token usage of a generation.
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
 }
 
 func (m AIChatMessage) UpdateContent(msg string) {
@@ -69,10 +85,11 @@ func (m AIChatMessage) GetContent() string {
 	return m.Content
 }
 func (m AIChatMessage) GetFunctionCall() *FunctionCall { return m.FunctionCall }
+func (m AIChatMessage) GetToolCalls() []ToolCall       { return m.ToolCalls }
 
 // HumanChatMessage is a message sent by a human.
 type HumanChatMessage struct {
-	Content string
+	Content string `json:"content"`
 }
 
 func (m HumanChatMessage) UpdateContent(msg string) {
@@ -86,7 +103,7 @@ func (m HumanChatMessage) GetContent() string       { return m.Content }
 
 // SystemChatMessage is a chat message representing information that should be instructions to the AI system.
 type SystemChatMessage struct {
-	Content string
+	Content string `json:"content"`
 }
 
 func (m SystemChatMessage) UpdateContent(msg string) {
@@ -129,6 +146,21 @@ func (m FunctionChatMessage) UpdateContent(msg string) {
 
 }
 
+// ToolChatMessage is a chat message representing the result of a tool call.
+type ToolChatMessage struct {
+	// ID is the id of the tool call answered, see ToolCall.ID.
+	ID      string `json:"tool_call_id"`
+	Content string `json:"content"`
+}
+
+func (m ToolChatMessage) UpdateContent(msg string) {
+	m.Content = msg
+}
+
+func (m ToolChatMessage) GetType() ChatMessageType { return ChatMessageTypeTool }
+func (m ToolChatMessage) GetContent() string       { return m.Content }
+func (m ToolChatMessage) GetToolCallID() string    { return m.ID }
+
 // FunctionCall is the name and arguments of a function call.
 type FunctionCall struct {
 	Name      string `json:"name"`
@@ -163,6 +195,13 @@ func GetBufferString(messages []ChatMessage, humanPrefix string, aiPrefix string
 			}
 			msg = fmt.Sprintf("%s %s", msg, string(j))
 		}
+		if m, ok := m.(AIChatMessage); ok && len(m.ToolCalls) > 0 {
+			j, err := json.Marshal(m.ToolCalls)
+			if err != nil {
+				return "", err
+			}
+			msg = fmt.Sprintf("%s %s", msg, string(j))
+		}
 		result = append(result, msg)
 	}
 	return strings.Join(result, "\n"), nil
@@ -185,6 +224,8 @@ func getMessageRole(m ChatMessage, humanPrefix, aiPrefix string) (string, error)
 		role = cgm.Role
 	case ChatMessageTypeFunction:
 		role = "Function"
+	case ChatMessageTypeTool:
+		role = "Tool"
 	default:
 		return "", ErrUnexpectedChatMessageType
 	}
@@ -7,15 +7,21 @@ type Choice struct {
 }
 
 type CompletionUsage struct {
-	CompletionTokens float64
-	PromptTokens     float64
-	TotalTokens      float64
+	CompletionTokens float64 `json:"completion_tokens"`
+	PromptTokens     float64 `json:"prompt_tokens"`
+	TotalTokens      float64 `json:"total_tokens"`
+}
+
+// SparkUsage is the usage block of the last Spark frame, the token counts are
+// nested under the "text" key.
+type SparkUsage struct {
+	Text CompletionUsage `json:"text"`
 }
 
 type ChatCompletionMessage struct {
 	Id      string
 	Choices SparkChoices
-	Usage   CompletionUsage
+	Usage   SparkUsage `json:"usage"`
 }
 type SparkHeader struct {
 	Code    int
@@ -23,6 +29,10 @@ type SparkHeader struct {
 	Sid     string
 	Message string
 }
+
+// SparkResponse is a Spark response frame.
+//
+// Deprecated: use the typed frames of the sparkclient/protocol package.
 type SparkResponse struct {
 	Header  SparkHeader
 	Payload ChatCompletionMessage
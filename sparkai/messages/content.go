@@ -84,6 +84,9 @@ func (BinaryContent) isPart() {}
 // It can potentially return multiple content choices.
 type ContentResponse struct {
 	Choices []*ContentChoice
+
+	// Usage is the token usage of all the choices, if reported by the model.
+	Usage *Usage
 }
 
 // ContentChoice is one of the response choices returned by GenerateContent
@@ -100,6 +103,82 @@ type ContentChoice struct {
 
 	// FuncCall is non-nil when the model asks to invoke a function/tool.
 	FuncCall *FunctionCall
+
+	// ToolCalls are the tools the model asks to call, FuncCall is then the
+	// function of the first call.
+	ToolCalls []ToolCall
+}
+
+// Normalized finish reasons, see ContentChoice.StopReason.
+const (
+	// FinishReasonStop is set when the answer is complete or a stop word was reached.
+	FinishReasonStop = "stop"
+	// FinishReasonLength is set when the max_tokens limit was reached.
+	FinishReasonLength = "length"
+	// FinishReasonFunctionCall is set when the model asks to call a function.
+	FinishReasonFunctionCall = "function_call"
+	// FinishReasonToolCalls is set when the model asks to call tools.
+	FinishReasonToolCalls = "tool_calls"
+	// FinishReasonContentFilter is set when the answer was blocked by the content audit.
+	FinishReasonContentFilter = "content_filter"
+)
+
+// Keys of the generation info maps set by the models.
+const (
+	InfoPromptTokens     = "PromptTokens"
+	InfoCompletionTokens = "CompletionTokens"
+	InfoTotalTokens      = "TotalTokens"
+	// InfoSid is the Spark session id.
+	InfoSid = "Sid"
+	// InfoStatus is the Spark status of the last frame.
+	InfoStatus = "Status"
+)
+
+// GenerationInfo is the typed view of a generation info map.
+type GenerationInfo struct {
+	Usage Usage
+	// Sid is the Spark session id, empty for the other models.
+	Sid string
+	// Status is the Spark status of the last frame.
+	Status int
+	// FinishReason is the normalized reason the generation stopped.
+	FinishReason string
+}
+
+// ParseGenerationInfo reads the well-known keys of a generation info map,
+// missing keys are left to their zero value.
+func ParseGenerationInfo(info map[string]any) GenerationInfo {
+	sid, _ := info[InfoSid].(string)
+	return GenerationInfo{
+		Usage: Usage{
+			PromptTokens:     intValue(info[InfoPromptTokens]),
+			CompletionTokens: intValue(info[InfoCompletionTokens]),
+			TotalTokens:      intValue(info[InfoTotalTokens]),
+		},
+		Sid:    sid,
+		Status: intValue(info[InfoStatus]),
+	}
+}
+
+// Info returns the typed generation info of the choice.
+func (c *ContentChoice) Info() GenerationInfo {
+	info := ParseGenerationInfo(c.GenerationInfo)
+	info.FinishReason = c.StopReason
+	return info
+}
+
+// intValue converts the numbers found in generation info maps.
+func intValue(v any) int {
+	switch n := v.(type) {
+	case int:
+		return n
+	case int64:
+		return int(n)
+	case float64:
+		return int(n)
+	default:
+		return 0
+	}
 }
 
 // TextParts is a helper function to create a MessageContent with a role and a
@@ -0,0 +1,220 @@
+package messages
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Envelope is the stable JSON form of a ChatMessage or of a MessageContent.
+// Type tells the concrete type of the message, so that a decoded message has
+// the type of the encoded one.
+type Envelope struct {
+	// Type is the type of the message, ChatMessageTypeGeneric for a
+	// GenericChatMessage and the role for a MessageContent.
+	Type ChatMessageType `json:"type"`
+	// Role is the role of a GenericChatMessage, kept as is.
+	Role string `json:"role,omitempty"`
+	// Name is the name of a function or generic message.
+	Name    string `json:"name,omitempty"`
+	Content string `json:"content"`
+	// Parts is the multi-part content of a MessageContent.
+	Parts        Parts         `json:"parts,omitempty"`
+	FunctionCall *FunctionCall `json:"function_call,omitempty"`
+	ToolCalls    []ToolCall    `json:"tool_calls,omitempty"`
+	ToolCallID   string        `json:"tool_call_id,omitempty"`
+	Usage        *Usage        `json:"usage,omitempty"`
+}
+
+// NewEnvelope returns the envelope of msg. Messages of other types than the
+// ones of this package are enveloped by type, they are decoded as the
+// message of this package of the same type.
+func NewEnvelope(msg ChatMessage) (Envelope, error) {
+	e := Envelope{Type: msg.GetType(), Content: msg.GetContent()}
+	switch m := msg.(type) {
+	case AIChatMessage:
+		e.FunctionCall, e.ToolCalls, e.Usage = m.FunctionCall, m.ToolCalls, m.Usage
+	case *AIChatMessage:
+		e.FunctionCall, e.ToolCalls, e.Usage = m.FunctionCall, m.ToolCalls, m.Usage
+	case HumanChatMessage, *HumanChatMessage, SystemChatMessage, *SystemChatMessage:
+	case GenericChatMessage:
+		e.Type, e.Role, e.Name = ChatMessageTypeGeneric, m.Role, m.Name
+	case *GenericChatMessage:
+		e.Type, e.Role, e.Name = ChatMessageTypeGeneric, m.Role, m.Name
+	case FunctionChatMessage:
+		e.Name = m.Name
+	case *FunctionChatMessage:
+		e.Name = m.Name
+	case ToolChatMessage:
+		e.ToolCallID = m.ID
+	case *ToolChatMessage:
+		e.ToolCallID = m.ID
+	default:
+		if _, err := e.Message(); err != nil {
+			return Envelope{}, err
+		}
+		if n, ok := msg.(Named); ok {
+			e.Name = n.GetName()
+		}
+		if fc, ok := msg.(interface{ GetFunctionCall() *FunctionCall }); ok {
+			e.FunctionCall = fc.GetFunctionCall()
+		}
+	}
+	return e, nil
+}
+
+// Message returns the message of the envelope.
+func (e Envelope) Message() (ChatMessage, error) {
+	switch e.Type {
+	case ChatMessageTypeAI:
+		return AIChatMessage{Content: e.Content, FunctionCall: e.FunctionCall, ToolCalls: e.ToolCalls, Usage: e.Usage}, nil
+	case ChatMessageTypeHuman:
+		return HumanChatMessage{Content: e.Content}, nil
+	case ChatMessageTypeSystem:
+		return SystemChatMessage{Content: e.Content}, nil
+	case ChatMessageTypeGeneric:
+		return GenericChatMessage{Role: e.Role, Name: e.Name, Content: e.Content}, nil
+	case ChatMessageTypeFunction:
+		return FunctionChatMessage{Name: e.Name, Content: e.Content}, nil
+	case ChatMessageTypeTool:
+		return ToolChatMessage{ID: e.ToolCallID, Content: e.Content}, nil
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnexpectedChatMessageType, e.Type)
+	}
+}
+
+// MarshalMessages encodes msgs as a JSON array of envelopes, see Envelope.
+func MarshalMessages(msgs []ChatMessage) ([]byte, error) {
+	envelopes := make([]Envelope, 0, len(msgs))
+	for _, msg := range msgs {
+		e, err := NewEnvelope(msg)
+		if err != nil {
+			return nil, err
+		}
+		envelopes = append(envelopes, e)
+	}
+	return json.Marshal(envelopes)
+}
+
+// UnmarshalMessages decodes messages encoded by MarshalMessages, with their
+// original types.
+func UnmarshalMessages(data []byte) ([]ChatMessage, error) {
+	var envelopes []Envelope
+	if err := json.Unmarshal(data, &envelopes); err != nil {
+		return nil, err
+	}
+	msgs := make([]ChatMessage, 0, len(envelopes))
+	for i, e := range envelopes {
+		msg, err := e.Message()
+		if err != nil {
+			return nil, fmt.Errorf("message %d: %w", i, err)
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
+
+// MarshalMessageContents encodes msgs as a JSON array of envelopes holding
+// their parts.
+func MarshalMessageContents(msgs []MessageContent) ([]byte, error) {
+	envelopes := make([]Envelope, 0, len(msgs))
+	for _, msg := range msgs {
+		envelopes = append(envelopes, Envelope{Type: msg.Role, Parts: msg.Parts})
+	}
+	return json.Marshal(envelopes)
+}
+
+// UnmarshalMessageContents decodes message contents encoded by
+// MarshalMessageContents. The envelopes of chat messages are accepted too,
+// their content becomes a text part.
+func UnmarshalMessageContents(data []byte) ([]MessageContent, error) {
+	var envelopes []Envelope
+	if err := json.Unmarshal(data, &envelopes); err != nil {
+		return nil, err
+	}
+	msgs := make([]MessageContent, 0, len(envelopes))
+	for _, e := range envelopes {
+		parts := []ContentPart(e.Parts)
+		if parts == nil && e.Content != "" {
+			parts = []ContentPart{TextPart(e.Content)}
+		}
+		msgs = append(msgs, MessageContent{Role: e.Type, Parts: parts})
+	}
+	return msgs, nil
+}
+
+// Parts is a multi-part content. Every part is encoded as an object with a
+// type: text, image_url, binary (the data in base64), tool_call or
+// tool_call_response.
+type Parts []ContentPart
+
+// part is the JSON form of a content part.
+type part struct {
+	Type             string            `json:"type"`
+	Text             *string           `json:"text,omitempty"`
+	ImageURL         *imageURL         `json:"image_url,omitempty"`
+	MIMEType         string            `json:"mime_type,omitempty"`
+	Data             []byte            `json:"data,omitempty"`
+	ToolCall         *ToolCall         `json:"tool_call,omitempty"`
+	ToolCallResponse *ToolCallResponse `json:"tool_call_response,omitempty"`
+}
+
+type imageURL struct {
+	URL string `json:"url"`
+}
+
+// Content part types, see Parts.
+const (
+	partTypeText             = "text"
+	partTypeImageURL         = "image_url"
+	partTypeBinary           = "binary"
+	partTypeToolCall         = "tool_call"
+	partTypeToolCallResponse = "tool_call_response"
+)
+
+func (p Parts) MarshalJSON() ([]byte, error) {
+	parts := make([]part, 0, len(p))
+	for _, cp := range p {
+		switch cp := cp.(type) {
+		case TextContent:
+			parts = append(parts, part{Type: partTypeText, Text: &cp.Text})
+		case ImageURLContent:
+			parts = append(parts, part{Type: partTypeImageURL, ImageURL: &imageURL{URL: cp.URL}})
+		case BinaryContent:
+			parts = append(parts, part{Type: partTypeBinary, MIMEType: cp.MIMEType, Data: cp.Data})
+		case ToolCall:
+			parts = append(parts, part{Type: partTypeToolCall, ToolCall: &cp})
+		case ToolCallResponse:
+			parts = append(parts, part{Type: partTypeToolCallResponse, ToolCallResponse: &cp})
+		default:
+			return nil, fmt.Errorf("unexpected content part %T", cp) //nolint:goerr113
+		}
+	}
+	return json.Marshal(parts)
+}
+
+func (p *Parts) UnmarshalJSON(data []byte) error {
+	var parts []part
+	if err := json.Unmarshal(data, &parts); err != nil {
+		return err
+	}
+	*p = make(Parts, 0, len(parts))
+	for i, pt := range parts {
+		var cp ContentPart
+		switch {
+		case pt.Type == partTypeText && pt.Text != nil:
+			cp = TextContent{Text: *pt.Text}
+		case pt.Type == partTypeImageURL && pt.ImageURL != nil:
+			cp = ImageURLContent{URL: pt.ImageURL.URL}
+		case pt.Type == partTypeBinary:
+			cp = BinaryContent{MIMEType: pt.MIMEType, Data: pt.Data}
+		case pt.Type == partTypeToolCall && pt.ToolCall != nil:
+			cp = *pt.ToolCall
+		case pt.Type == partTypeToolCallResponse && pt.ToolCallResponse != nil:
+			cp = *pt.ToolCallResponse
+		default:
+			return fmt.Errorf("part %d: unexpected content part type %q", i, pt.Type) //nolint:goerr113
+		}
+		*p = append(*p, cp)
+	}
+	return nil
+}
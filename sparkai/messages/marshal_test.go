@@ -0,0 +1,82 @@
+package messages
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarshalMessages(t *testing.T) {
+	t.Parallel()
+	msgs := []ChatMessage{
+		SystemChatMessage{Content: "你是天气助手"},
+		HumanChatMessage{Content: "合肥天气怎么样"},
+		AIChatMessage{FunctionCall: &FunctionCall{Name: "get_weather", Arguments: `{"location":"合肥"}`}},
+		FunctionChatMessage{Name: "get_weather", Content: "晴"},
+		AIChatMessage{ToolCalls: []ToolCall{{
+			ID: "call_1", Type: ToolTypeFunction,
+			FunctionCall: &FunctionCall{Name: "get_weather", Arguments: `{"location":"北京"}`},
+		}}},
+		ToolChatMessage{ID: "call_1", Content: "多云"},
+		AIChatMessage{Content: "合肥今天晴", Usage: &Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}},
+		GenericChatMessage{Role: "Critic", Name: "bob", Content: "好"},
+	}
+
+	data, err := MarshalMessages(msgs)
+	require.NoError(t, err)
+	got, err := UnmarshalMessages(data)
+	require.NoError(t, err)
+	assert.Equal(t, msgs, got)
+
+	// 指针与值解码为相同的消息
+	data, err = MarshalMessages([]ChatMessage{&GenericChatMessage{Role: "user", Content: "你好"}, &AIChatMessage{Content: "你好"}})
+	require.NoError(t, err)
+	assert.JSONEq(t, `[
+		{"type":"generic","role":"user","content":"你好"},
+		{"type":"ai","content":"你好"}
+	]`, string(data))
+	got, err = UnmarshalMessages(data)
+	require.NoError(t, err)
+	assert.Equal(t, []ChatMessage{GenericChatMessage{Role: "user", Content: "你好"}, AIChatMessage{Content: "你好"}}, got)
+}
+
+func TestUnmarshalMessagesUnknownType(t *testing.T) {
+	t.Parallel()
+	_, err := UnmarshalMessages([]byte(`[{"type":"human","content":"你好"},{"type":"robot","content":"你好"}]`))
+	require.ErrorIs(t, err, ErrUnexpectedChatMessageType)
+	assert.Contains(t, err.Error(), "message 1")
+}
+
+func TestMarshalMessageContents(t *testing.T) {
+	t.Parallel()
+	msgs := []MessageContent{
+		{Role: ChatMessageTypeHuman, Parts: []ContentPart{
+			TextPart("这是什么?"),
+			TextPart(""),
+			ImageURLPart("https://example.com/cat.png"),
+			BinaryPart("image/png", []byte{0x89, 'P', 'N', 'G'}),
+		}},
+		{Role: ChatMessageTypeAI, Parts: []ContentPart{ToolCall{
+			ID: "call_1", Type: ToolTypeFunction,
+			FunctionCall: &FunctionCall{Name: "describe", Arguments: "{}"},
+		}}},
+		{Role: ChatMessageTypeTool, Parts: []ContentPart{ToolCallResponse{ToolCallID: "call_1", Name: "describe", Content: "一只猫"}}},
+	}
+
+	data, err := MarshalMessageContents(msgs)
+	require.NoError(t, err)
+	got, err := UnmarshalMessageContents(data)
+	require.NoError(t, err)
+	assert.Equal(t, msgs, got)
+
+	// 聊天消息的内容解码为文本
+	data, err = MarshalMessages([]ChatMessage{HumanChatMessage{Content: "你好"}})
+	require.NoError(t, err)
+	got, err = UnmarshalMessageContents(data)
+	require.NoError(t, err)
+	assert.Equal(t, []MessageContent{TextParts(ChatMessageTypeHuman, "你好")}, got)
+
+	_, err = UnmarshalMessageContents([]byte(`[{"type":"human","parts":[{"type":"audio"}]}]`))
+	require.Error(t, err)
+}
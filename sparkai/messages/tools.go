@@ -0,0 +1,62 @@
+package messages
+
+// ToolTypeFunction is the type of the function tools, the only type of tools
+// defined by the caller.
+const ToolTypeFunction = "function"
+
+// Tool is a tool the model may call.
+type Tool struct {
+	// Type is the type of the tool, ToolTypeFunction.
+	Type string `json:"type"`
+	// Function is the definition of the function.
+	Function *FunctionDefinition `json:"function,omitempty"`
+}
+
+// ToolCall is a call to a tool made by the model. In a MessageContent of the
+// AI role it is a part of the message.
+type ToolCall struct {
+	// ID identifies the call, the ToolCallResponse refers to it.
+	ID string `json:"id"`
+	// Type is the type of the tool called.
+	Type string `json:"type"`
+	// FunctionCall is the function called and its arguments.
+	FunctionCall *FunctionCall `json:"function,omitempty"`
+}
+
+func (ToolCall) isPart() {}
+
+// ToolCallResponse is the result of a tool call, the part of a MessageContent
+// of the tool role.
+type ToolCallResponse struct {
+	// ToolCallID is the id of the tool call answered.
+	ToolCallID string `json:"tool_call_id"`
+	// Name is the name of the function called.
+	Name string `json:"name"`
+	// Content is the result of the call.
+	Content string `json:"content"`
+}
+
+func (ToolCallResponse) isPart() {}
+
+// Tool choices, besides a ToolChoice naming the function to call.
+const (
+	// ToolChoiceNone will not call any tools.
+	ToolChoiceNone = "none"
+	// ToolChoiceAuto lets the model choose whether to call tools.
+	ToolChoiceAuto = "auto"
+	// ToolChoiceRequired makes the model call at least one tool.
+	ToolChoiceRequired = "required"
+)
+
+// ToolChoice forces the model to call a specific function.
+type ToolChoice struct {
+	// Type is the type of the tool, ToolTypeFunction.
+	Type string `json:"type"`
+	// Function names the function to call.
+	Function *FunctionReference `json:"function,omitempty"`
+}
+
+// FunctionReference names a function.
+type FunctionReference struct {
+	Name string `json:"name"`
+}
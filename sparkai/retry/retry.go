@@ -0,0 +1,179 @@
+// Package retry implements the retry policy shared by the LLM clients.
+package retry
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultMaxAttempts    = 3
+	defaultInitialBackoff = 500 * time.Millisecond
+	defaultMaxBackoff     = 10 * time.Second
+	defaultMultiplier     = 2
+	defaultJitter         = 0.2
+)
+
+// Policy configures how failed calls are retried. The zero value performs a
+// single attempt.
+type Policy struct {
+	// MaxAttempts is the maximum number of attempts, including the first one.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the computed backoff. It does not cap a Retry-After hint.
+	MaxBackoff time.Duration
+	// Multiplier is the factor applied to the backoff after each attempt.
+	Multiplier float64
+	// Jitter is the fraction of the backoff, between 0 and 1, that is randomized.
+	Jitter float64
+	// Retryable classifies errors. If nil, IsRetryable is used.
+	Retryable func(err error) bool
+	// OnRetry is called before waiting for the next attempt.
+	OnRetry func(ctx context.Context, attempt int, delay time.Duration, err error)
+}
+
+// DefaultPolicy returns a policy with 3 attempts and exponential backoff
+// starting at 500ms.
+func DefaultPolicy() Policy {
+	return Policy{
+		MaxAttempts:    defaultMaxAttempts,
+		InitialBackoff: defaultInitialBackoff,
+		MaxBackoff:     defaultMaxBackoff,
+		Multiplier:     defaultMultiplier,
+		Jitter:         defaultJitter,
+	}
+}
+
+// RetryableError is implemented by errors which know whether the failed call
+// is worth retrying.
+type RetryableError interface {
+	error
+	Retryable() bool
+}
+
+// RetryAfterError is implemented by errors carrying a server hint of how long
+// to wait before retrying.
+type RetryAfterError interface {
+	error
+	RetryAfter() time.Duration
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps err so that it is never retried.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsRetryable is the default error classification: errors implementing
+// RetryableError decide for themselves, network errors are retried,
+// context errors and everything else are not.
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var retryable RetryableError
+	if errors.As(err, &retryable) {
+		return retryable.Retryable()
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// Do calls fn until it succeeds, returns an error that is not retryable,
+// the attempts are exhausted or ctx is done. attempt starts at 1.
+func Do(ctx context.Context, p Policy, fn func(attempt int) error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn(attempt)
+		if err == nil {
+			return nil
+		}
+		var permanent *permanentError
+		if errors.As(err, &permanent) {
+			return permanent.err
+		}
+		if attempt >= p.MaxAttempts || !p.retryable(err) {
+			return err
+		}
+
+		delay := p.Backoff(attempt)
+		var retryAfter RetryAfterError
+		if errors.As(err, &retryAfter) && retryAfter.RetryAfter() > delay {
+			delay = retryAfter.RetryAfter()
+		}
+		// 等待时间超过 ctx 截止时间时不再重试
+		if d, ok := ctx.Deadline(); ok && time.Now().Add(delay).After(d) {
+			return err
+		}
+		if p.OnRetry != nil {
+			p.OnRetry(ctx, attempt, delay, err)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (p Policy) retryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return IsRetryable(err)
+}
+
+// Backoff returns the delay to wait after the given failed attempt.
+func (p Policy) Backoff(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	backoff := float64(p.InitialBackoff) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxBackoff > 0 && backoff > float64(p.MaxBackoff) {
+		backoff = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		jitter := math.Min(p.Jitter, 1)
+		backoff -= backoff * jitter * rand.Float64() //nolint:gosec
+	}
+	return time.Duration(backoff)
+}
+
+// ParseRetryAfter parses a Retry-After header given either in seconds or as
+// an HTTP date. It returns zero when the header is missing, invalid or past.
+func ParseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
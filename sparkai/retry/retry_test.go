@@ -0,0 +1,126 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testError struct {
+	retryable  bool
+	retryAfter time.Duration
+}
+
+func (e testError) Error() string             { return "test error" }
+func (e testError) Retryable() bool           { return e.retryable }
+func (e testError) RetryAfter() time.Duration { return e.retryAfter }
+
+func TestDo(t *testing.T) {
+	t.Parallel()
+	p := Policy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+
+	var retries []int
+	p.OnRetry = func(_ context.Context, attempt int, _ time.Duration, _ error) {
+		retries = append(retries, attempt)
+	}
+	calls := 0
+	err := Do(context.Background(), p, func(int) error {
+		calls++
+		if calls < 3 {
+			return testError{retryable: true}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, []int{1, 2}, retries)
+}
+
+func TestDoStops(t *testing.T) {
+	t.Parallel()
+	p := Policy{MaxAttempts: 5, InitialBackoff: time.Millisecond}
+
+	calls := 0
+	err := Do(context.Background(), p, func(int) error {
+		calls++
+		return testError{retryable: false}
+	})
+	require.ErrorAs(t, err, &testError{})
+	assert.Equal(t, 1, calls)
+
+	calls = 0
+	errStop := errors.New("stop")
+	err = Do(context.Background(), p, func(int) error {
+		calls++
+		return Permanent(errStop)
+	})
+	require.Equal(t, errStop, err)
+	assert.Equal(t, 1, calls)
+
+	calls = 0
+	err = Do(context.Background(), Policy{}, func(int) error {
+		calls++
+		return testError{retryable: true}
+	})
+	require.Error(t, err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestDoRetryAfter(t *testing.T) {
+	t.Parallel()
+	p := Policy{MaxAttempts: 2, InitialBackoff: time.Millisecond}
+
+	var delay time.Duration
+	p.OnRetry = func(_ context.Context, _ int, d time.Duration, _ error) { delay = d }
+	_ = Do(context.Background(), p, func(int) error {
+		return testError{retryable: true, retryAfter: 20 * time.Millisecond}
+	})
+	assert.Equal(t, 20*time.Millisecond, delay)
+
+	// Retry-After 超过 ctx 截止时间时直接返回
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	calls := 0
+	err := Do(ctx, p, func(int) error {
+		calls++
+		return testError{retryable: true, retryAfter: time.Second}
+	})
+	require.ErrorAs(t, err, &testError{})
+	assert.Equal(t, 1, calls)
+}
+
+func TestBackoff(t *testing.T) {
+	t.Parallel()
+	p := Policy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Multiplier: 2}
+	assert.Equal(t, 100*time.Millisecond, p.Backoff(1))
+	assert.Equal(t, 400*time.Millisecond, p.Backoff(3))
+	assert.Equal(t, time.Second, p.Backoff(10))
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		d := p.Backoff(1)
+		assert.GreaterOrEqual(t, d, 50*time.Millisecond)
+		assert.LessOrEqual(t, d, 100*time.Millisecond)
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	t.Parallel()
+	assert.False(t, IsRetryable(context.Canceled))
+	assert.False(t, IsRetryable(errors.New("boom")))
+	assert.True(t, IsRetryable(testError{retryable: true}))
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, 3*time.Second, ParseRetryAfter("3"))
+	assert.Equal(t, time.Duration(0), ParseRetryAfter(""))
+	assert.Equal(t, time.Duration(0), ParseRetryAfter("soon"))
+	d := ParseRetryAfter(time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+	assert.Greater(t, d, 50*time.Second)
+}